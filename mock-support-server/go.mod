@@ -1,20 +1,25 @@
 module github.com/spotify/confidence-resolver-rust/mock-support-server
 
-go 1.24
+go 1.24.0
 
 toolchain go1.24.4
 
 require (
 	github.com/golang-jwt/jwt/v5 v5.2.2
 	github.com/grpc-ecosystem/grpc-gateway/v2 v2.22.0
-	golang.org/x/net v0.41.0
-	google.golang.org/genproto/googleapis/api v0.0.0-20250707201910-8d1bb00bc6a7
+	github.com/spotify/confidence-resolver/openfeature-provider/go v0.0.0
+	golang.org/x/net v0.44.0
+	google.golang.org/genproto/googleapis/api v0.0.0-20251029180050-ab9386a59fda
 	google.golang.org/grpc v1.75.1
 	google.golang.org/protobuf v1.36.10
 )
 
 require (
-	golang.org/x/sys v0.33.0 // indirect
-	golang.org/x/text v0.26.0 // indirect
-	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
+	golang.org/x/sys v0.36.0 // indirect
+	golang.org/x/text v0.29.0 // indirect
+	google.golang.org/genproto v0.0.0-20251029180050-ab9386a59fda // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20251014184007-4626949a642f // indirect
 )
+
+// The resolver state protos are generated in the Go provider module
+replace github.com/spotify/confidence-resolver/openfeature-provider/go => ../openfeature-provider/go
@@ -4,49 +4,82 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	mrand "math/rand/v2"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	pb "github.com/spotify/confidence-resolver-rust/mock-support-server/genproto/mock"
+	adminv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/admin/v1"
+	eventsv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverevents"
+	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/resolver"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
 
 type config struct {
-	Port              int
-	AccountID         string
+	Port      int
+	AccountID string
+	// Resolver state file, binary proto or, with a .json extension, protojson of a ResolverState
 	ResolverStatePath string
+	// Directory of state files named by the sha256 hex of their client secret, served instead of ResolverStatePath
+	ResolverStateDir string
 	// used to mock the correct state url
-	ClientSecret    string
-	RequestLogging    bool
+	ClientSecret   string
+	RequestLogging bool
 	// Artificial per-request latency in milliseconds for both HTTP and gRPC
 	LatencyMs int
 	// Bandwidth cap for HTTP responses in kilobytes per second (0 disables throttling)
 	BandwidthKbps int
+	// Canned ResolveFlagsResponse, binary proto or, with a .json extension, protojson, served for
+	// REST resolves on resolver.confidence.dev (disabled when empty)
+	ResolveResponsePath string
+	// Fraction of WriteFlagLogs calls, 0.0-1.0, failed with WriteFailCode
+	WriteFailRate float64
+	// gRPC status code of injected WriteFlagLogs failures, by name (e.g. UNAVAILABLE) or number
+	WriteFailCode codes.Code
+	// How long to wait for in-flight requests on SIGINT/SIGTERM before exiting
+	ShutdownTimeout time.Duration
+	// Register gRPC server reflection so tools like grpcurl work without the .proto files
+	GRPCReflection bool
 }
 
 func readEnv() config {
 	cfg := config{
-		Port:              getenvInt("PORT", 8081),
-		AccountID:         getenv("ACCOUNT_ID", "confidence-test"),
-		ResolverStatePath: getenv("RESOLVER_STATE_PB", ""),
-		ClientSecret:    	 getenv("CLIENT_SECRET", "secret"),
-		RequestLogging:    getenvBool("REQUEST_LOGGING", false),
-		LatencyMs:         getenvInt("LATENCY_MS", 0),
-		BandwidthKbps:     getenvInt("BANDWIDTH_KBPS", 0),
+		Port:                getenvInt("PORT", 8081),
+		AccountID:           getenv("ACCOUNT_ID", "confidence-test"),
+		ResolverStatePath:   getenv("RESOLVER_STATE_PB", ""),
+		ResolverStateDir:    getenv("RESOLVER_STATE_DIR", ""),
+		ClientSecret:        getenv("CLIENT_SECRET", "secret"),
+		RequestLogging:      getenvBool("REQUEST_LOGGING", false),
+		LatencyMs:           getenvInt("LATENCY_MS", 0),
+		BandwidthKbps:       getenvInt("BANDWIDTH_KBPS", 0),
+		GRPCReflection:      getenvBool("GRPC_REFLECTION", false),
+		ShutdownTimeout:     time.Duration(getenvInt("SHUTDOWN_TIMEOUT_MS", 10000)) * time.Millisecond,
+		ResolveResponsePath: getenv("RESOLVE_RESPONSE_PATH", ""),
+		WriteFailRate:       getenvFloat("WRITE_FAIL_RATE", 0),
+		WriteFailCode:       getenvCode("WRITE_FAIL_CODE", codes.Unavailable),
 	}
 	return cfg
 }
@@ -54,9 +87,15 @@ func readEnv() config {
 type internalFlagLoggerService struct {
 	pb.UnimplementedInternalFlagLoggerServiceServer
 	clientSecret string
+	failRate     float64
+	failCode     codes.Code
 	bytesIn      atomic.Int64
 	appliedCount atomic.Int64
 	requestCount atomic.Int64
+
+	// Applied flags by flag name, e.g. "flags/my-flag"
+	flagCountsMu sync.Mutex
+	flagCounts   map[string]int64
 }
 
 func (s *internalFlagLoggerService) ClientWriteFlagLogs(ctx context.Context, req *pb.WriteFlagLogsRequest) (*pb.WriteFlagLogsResponse, error) {
@@ -69,44 +108,94 @@ func (s *internalFlagLoggerService) ClientWriteFlagLogs(ctx context.Context, req
 	} else {
 		return nil, status.Error(codes.Unauthenticated, "missing authorization")
 	}
+	if s.failRate > 0 && mrand.Float64() < s.failRate {
+		return nil, status.Error(s.failCode, "injected failure")
+	}
+	// Decode all assignments before counting so a malformed request counts nothing
+	var applied []string
+	for i, b := range req.FlagAssigned {
+		assigned := &eventsv1.FlagAssigned{}
+		if err := proto.Unmarshal(b, assigned); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "flag_assigned[%d] is not a FlagAssigned: %v", i, err)
+		}
+		for _, flag := range assigned.GetFlags() {
+			applied = append(applied, flag.GetFlag())
+		}
+	}
 	s.bytesIn.Add(int64(proto.Size(req)))
 	s.appliedCount.Add(int64(len(req.FlagAssigned)))
 	s.requestCount.Add(1)
+	s.flagCountsMu.Lock()
+	for _, flag := range applied {
+		s.flagCounts[flag]++
+	}
+	s.flagCountsMu.Unlock()
 	return &pb.WriteFlagLogsResponse{}, nil
 }
 
+// appliedFlagCounts returns a copy of the applied counts by flag name
+func (s *internalFlagLoggerService) appliedFlagCounts() map[string]int64 {
+	s.flagCountsMu.Lock()
+	defer s.flagCountsMu.Unlock()
+	counts := make(map[string]int64, len(s.flagCounts))
+	for flag, n := range s.flagCounts {
+		counts[flag] = n
+	}
+	return counts
+}
+
 func main() {
 	cfg := readEnv()
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	run(cfg, lis)
+}
+
+// run serves on lis until SIGINT/SIGTERM, then drains in-flight requests and logs the final
+// metrics
+func run(cfg config, lis net.Listener) {
+	// gRPC is served through ServeHTTP, which doesn't support GracefulStop, so in-flight
+	// handlers are tracked to wait for them on shutdown
+	inFlight := &grpcInFlight{}
 	var grpcServer *grpc.Server
 	{
-		var unaryInterceptors []grpc.UnaryServerInterceptor
+		unaryInterceptors := []grpc.UnaryServerInterceptor{inFlight.unaryInterceptor}
+		if cfg.LatencyMs > 0 {
+			unaryInterceptors = append(unaryInterceptors, unaryLatencyInterceptor(time.Duration(cfg.LatencyMs)*time.Millisecond))
+		}
 		if cfg.RequestLogging {
 			unaryInterceptors = append(unaryInterceptors, unaryLoggingInterceptor)
 		}
-		if len(unaryInterceptors) > 0 {
-			grpcServer = grpc.NewServer(
-				grpc.ChainUnaryInterceptor(unaryInterceptors...),
-			)
-		} else {
-			grpcServer = grpc.NewServer()
-		}
+		grpcServer = grpc.NewServer(
+			grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		)
 	}
 
 	// Shared implementation for both gRPC and HTTP (grpc-gateway)
 
 	internalFlagLoggerServiceImpl := &internalFlagLoggerService{
 		clientSecret: cfg.ClientSecret,
+		failRate:     cfg.WriteFailRate,
+		failCode:     cfg.WriteFailCode,
+		flagCounts:   make(map[string]int64),
+	}
+	if cfg.WriteFailRate > 0 {
+		log.Printf("failing %.0f%% of flag log writes with %s", 100*cfg.WriteFailRate, cfg.WriteFailCode)
 	}
 	pb.RegisterInternalFlagLoggerServiceServer(grpcServer, internalFlagLoggerServiceImpl)
+	if cfg.GRPCReflection {
+		reflection.Register(grpcServer)
+		log.Printf("gRPC reflection enabled")
+	}
 
 	// Periodic metrics log (once per second) for the lifetime of the server
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 	go func() {
-		ticker := time.NewTicker(time.Second)
 		for range ticker.C {
-			b := internalFlagLoggerServiceImpl.bytesIn.Load()
-			a := internalFlagLoggerServiceImpl.appliedCount.Load()
-			r := internalFlagLoggerServiceImpl.requestCount.Load()
-			log.Printf("metrics bytes_total=%d applied_total=%d req_total=%d", b, a, r)
+			internalFlagLoggerServiceImpl.logMetrics("metrics")
 		}
 	}()
 
@@ -122,47 +211,61 @@ func main() {
 
 	// Cdn server mock
 	cdn := http.NewServeMux()
-	// Serve state at path /<sha256hex of cfg.ClientSecret>
-	stateHash := fmt.Sprintf("%x", sha256.Sum256([]byte(cfg.ClientSecret)))
-
-	var stateBytes []byte
-	if cfg.ResolverStatePath == "" {
-		stateBytes = readStateFromUrl(stateHash)
+	// Serve state at path /<sha256hex of client secret>
+	states := map[string]*servedState{}
+	if cfg.ResolverStateDir != "" {
+		states = readStatesFromDir(cfg.ResolverStateDir, cfg.AccountID)
 	} else {
-		stateBytes = readStateFromDisk(cfg.ResolverStatePath, cfg.AccountID)
+		stateHash := fmt.Sprintf("%x", sha256.Sum256([]byte(cfg.ClientSecret)))
+		if cfg.ResolverStatePath == "" {
+			states[stateHash] = newServedState(readStateFromUrl(stateHash))
+		} else {
+			states[stateHash] = newServedState(readStateFromDisk(cfg.ResolverStatePath, cfg.AccountID))
+		}
 	}
-	var stateETag string // random ETag set on first successful response
-	cdn.HandleFunc("/"+stateHash, func(w http.ResponseWriter, r *http.Request) {
-		if len(stateBytes) == 0 {
+	cdn.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		state, ok := states[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if len(state.bytes) == 0 {
 			http.Error(w, "resolver state not configured", http.StatusNotFound)
 			return
 		}
-		// Return 304 if client's ETag matches our current one
-		if stateETag != "" && r.Header.Get("If-None-Match") == stateETag {
+		// Return 304 if client's ETag matches the one of this state
+		if r.Header.Get("If-None-Match") == state.etag {
 			w.WriteHeader(http.StatusNotModified)
 			return
 		}
-		// Initialize random ETag on first response
-		if stateETag == "" {
-			buf := make([]byte, 16)
-			if _, err := rand.Read(buf); err == nil {
-				stateETag = fmt.Sprintf("\"%x\"", buf)
-			} else {
-				stateETag = fmt.Sprintf("\"%x-%x\"", time.Now().UnixNano(), len(stateBytes))
-			}
-		}
-		w.Header().Set("ETag", stateETag)
+		w.Header().Set("ETag", state.etag)
 		w.Header().Set("Content-Type", "application/octet-stream")
-		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(stateBytes)))
-		if _, err := w.Write(stateBytes); err != nil {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(state.bytes)))
+		if _, err := w.Write(state.bytes); err != nil {
 			log.Printf("/state write error: %v", err)
 		}
 	})
 
 	// Gateway mux serves resolver HTTP JSON/gRPC-gateway endpoints (mounted directly)
 
+	// Canned REST resolves, answered ahead of the gateway on the resolver host
+	var resolveResponse *resolverv1.ResolveFlagsResponse
+	if cfg.ResolveResponsePath != "" {
+		resolveResponse = readResolveResponse(cfg.ResolveResponsePath)
+		log.Printf("serving %d canned resolved flags from %s", len(resolveResponse.ResolvedFlags), cfg.ResolveResponsePath)
+	}
+
 	// Unified handler that routes gRPC (h2c) vs REST
 	base := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Health probes and test assertions don't set the forwarded host the other routes need
+		switch r.URL.Path {
+		case "/healthz":
+			serveHealth(w, states, internalFlagLoggerServiceImpl)
+			return
+		case "/metrics/flags":
+			serveFlagCounts(w, internalFlagLoggerServiceImpl)
+			return
+		}
 		forwarded := r.Header.Get("x-forwarded-host")
 		if forwarded == "" {
 			// using authority which is the common way to set forwarding for gRPC
@@ -183,6 +286,9 @@ func main() {
 			// Route CDN traffic to REST mux (e.g., /state)
 			cdn.ServeHTTP(w, r)
 			return
+		case strings.EqualFold(forwarded, "resolver.confidence.dev") && resolveResponse != nil && r.URL.Path == "/v1/flags:resolve":
+			serveResolve(w, r, cfg.ClientSecret, resolveResponse)
+			return
 		case strings.EqualFold(forwarded, "resolver.confidence.dev"):
 			// Route resolver host(s) to gRPC or grpc-gateway
 			gw.ServeHTTP(w, r)
@@ -197,25 +303,93 @@ func main() {
 		handler = withHTTPBandwidthLimit(handler, 1024*cfg.BandwidthKbps)
 	}
 	if cfg.LatencyMs > 0 {
-		handler = withHTTPLatency(handler, time.Duration(cfg.LatencyMs)*time.Millisecond)
+		// gRPC latency is added by its interceptor, inside the tracked handler
+		handler = withHTTPLatencySkipGRPC(handler, time.Duration(cfg.LatencyMs)*time.Millisecond)
 	}
 	if cfg.RequestLogging {
 		handler = withHTTPLoggingSkipGRPC(handler)
 	}
 
-	httpAddr := fmt.Sprintf(":%d", cfg.Port)
-	log.Printf("HTTP+h2c (REST+gRPC) listening on %s", httpAddr)
-	srv := &http.Server{Addr: httpAddr, Handler: h2c.NewHandler(handler, &http2.Server{})}
-	if err := srv.ListenAndServe(); err != nil {
+	log.Printf("HTTP+h2c (REST+gRPC) listening on %s", lis.Addr())
+	srv := &http.Server{Handler: h2c.NewHandler(handler, &http2.Server{})}
+
+	// Drain in-flight requests on SIGINT/SIGTERM so the final metrics line counts every write
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(lis) }()
+	select {
+	case err := <-serveErr:
 		log.Fatalf("http serve error: %v", err)
+	case <-sigCtx.Done():
+	}
+	log.Printf("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("http shutdown error: %v", err)
+	}
+	// gRPC is served over h2c connections the HTTP server no longer tracks, so wait for its
+	// handlers separately and cut them off once the timeout is up
+	if err := inFlight.drain(shutdownCtx); err != nil {
+		log.Printf("grpc drain timed out")
+		grpcServer.Stop()
+	}
+	internalFlagLoggerServiceImpl.logMetrics("final metrics")
+}
+
+// grpcInFlight tracks running gRPC handlers. Once draining, new calls are rejected with
+// UNAVAILABLE so every acknowledged write is in the final metrics.
+type grpcInFlight struct {
+	mu       sync.Mutex
+	draining bool
+	wg       sync.WaitGroup
+}
+
+func (f *grpcInFlight) unaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	f.mu.Lock()
+	if f.draining {
+		f.mu.Unlock()
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
 	}
+	f.wg.Add(1)
+	f.mu.Unlock()
+	defer f.wg.Done()
+	return handler(ctx, req)
+}
+
+// drain rejects new calls and waits for the running ones until ctx is done
+func (f *grpcInFlight) drain(ctx context.Context) error {
+	f.mu.Lock()
+	f.draining = true
+	f.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// logMetrics logs the flag log counters prefixed by label
+func (s *internalFlagLoggerService) logMetrics(label string) {
+	log.Printf("%s bytes_total=%d applied_total=%d req_total=%d",
+		label, s.bytesIn.Load(), s.appliedCount.Load(), s.requestCount.Load())
+}
 
+func isGRPCRequest(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
 }
 
 // withHTTPLoggingSkipGRPC logs only non-gRPC HTTP requests.
 func withHTTPLoggingSkipGRPC(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		if isGRPCRequest(r) {
 			// Bypass HTTP logging for gRPC; gRPC interceptor will log
 			next.ServeHTTP(w, r)
 			return
@@ -230,13 +404,15 @@ func withHTTPLoggingSkipGRPC(next http.Handler) http.Handler {
 	})
 }
 
-// withHTTPLatency sleeps for the provided duration before serving the request.
-func withHTTPLatency(next http.Handler, d time.Duration) http.Handler {
+// withHTTPLatencySkipGRPC sleeps for the provided duration before serving non-gRPC requests.
+func withHTTPLatencySkipGRPC(next http.Handler, d time.Duration) http.Handler {
 	if d <= 0 {
 		return next
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		time.Sleep(d)
+		if !isGRPCRequest(r) {
+			time.Sleep(d)
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -320,6 +496,97 @@ func (t *throttledReadCloser) Read(p []byte) (int, error) {
 
 func (t *throttledReadCloser) Close() error { return t.rc.Close() }
 
+// serveHealth reports whether any state is configured along with the flag log counters,
+// for readiness and liveness probes.
+func serveHealth(w http.ResponseWriter, states map[string]*servedState, logs *internalFlagLoggerService) {
+	stateConfigured := false
+	for _, state := range states {
+		if len(state.bytes) > 0 {
+			stateConfigured = true
+			break
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"status":          "ok",
+		"stateConfigured": stateConfigured,
+		"appliedTotal":    logs.appliedCount.Load(),
+		"bytesTotal":      logs.bytesIn.Load(),
+		"requestTotal":    logs.requestCount.Load(),
+	}); err != nil {
+		log.Printf("/healthz write error: %v", err)
+	}
+}
+
+// serveFlagCounts reports how many times each flag has been applied, as a JSON object keyed
+// by flag name, e.g. {"flags/my-flag": 3}
+func serveFlagCounts(w http.ResponseWriter, logs *internalFlagLoggerService) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(logs.appliedFlagCounts()); err != nil {
+		log.Printf("/metrics/flags write error: %v", err)
+	}
+}
+
+// serveResolve answers a ResolveFlagsRequest, JSON or protobuf as per its Content-Type, with
+// the canned response narrowed to the requested flags. The response uses the request's encoding.
+func serveResolve(w http.ResponseWriter, r *http.Request, clientSecret string, canned *resolverv1.ResolveFlagsResponse) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	isProto := strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-protobuf")
+	req := &resolverv1.ResolveFlagsRequest{}
+	if isProto {
+		err = proto.Unmarshal(body, req)
+	} else {
+		err = protojson.Unmarshal(body, req)
+	}
+	if err != nil {
+		http.Error(w, "invalid ResolveFlagsRequest: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ClientSecret != clientSecret {
+		http.Error(w, "invalid client secret", http.StatusUnauthorized)
+		return
+	}
+
+	resp := proto.Clone(canned).(*resolverv1.ResolveFlagsResponse)
+	if len(req.Flags) > 0 {
+		requested := make(map[string]bool, len(req.Flags))
+		for _, flag := range req.Flags {
+			requested[flag] = true
+		}
+		var resolved []*resolverv1.ResolvedFlag
+		for _, flag := range resp.ResolvedFlags {
+			if requested[flag.Flag] {
+				resolved = append(resolved, flag)
+			}
+		}
+		resp.ResolvedFlags = resolved
+	}
+
+	var out []byte
+	if isProto {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		out, err = proto.Marshal(resp)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		out, err = protojson.Marshal(resp)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := w.Write(out); err != nil {
+		log.Printf("/v1/flags:resolve write error: %v", err)
+	}
+}
+
 // gRPC server interceptors for rudimentary request logging.
 func unaryLoggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	start := time.Now()
@@ -329,6 +596,14 @@ func unaryLoggingInterceptor(ctx context.Context, req interface{}, info *grpc.Un
 	return resp, err
 }
 
+// unaryLatencyInterceptor sleeps for the provided duration before handling the call.
+func unaryLatencyInterceptor(d time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		time.Sleep(d)
+		return handler(ctx, req)
+	}
+}
+
 func getenv(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -345,6 +620,32 @@ func getenvInt(key string, def int) int {
 	return def
 }
 
+func getenvFloat(key string, def float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
+
+// getenvCode parses a gRPC status code by its name, e.g. UNAVAILABLE, or its number
+func getenvCode(key string, def codes.Code) codes.Code {
+	v := strings.ToUpper(strings.TrimSpace(os.Getenv(key)))
+	if v == "" {
+		return def
+	}
+	if _, err := strconv.Atoi(v); err != nil {
+		v = strconv.Quote(v)
+	}
+	var c codes.Code
+	if err := c.UnmarshalJSON([]byte(v)); err != nil {
+		log.Printf("ignoring %s: %v", key, err)
+		return def
+	}
+	return c
+}
+
 func getenvBool(key string, def bool) bool {
 	if v := strings.ToLower(strings.TrimSpace(os.Getenv(key))); v != "" {
 		switch v {
@@ -357,6 +658,52 @@ func getenvBool(key string, def bool) bool {
 	return def
 }
 
+// servedState is a state blob served by the CDN mock with its own random ETag
+type servedState struct {
+	bytes []byte
+	etag  string
+}
+
+func newServedState(b []byte) *servedState {
+	buf := make([]byte, 16)
+	etag := fmt.Sprintf("\"%x-%x\"", time.Now().UnixNano(), len(b))
+	if _, err := rand.Read(buf); err == nil {
+		etag = fmt.Sprintf("\"%x\"", buf)
+	}
+	return &servedState{bytes: b, etag: etag}
+}
+
+// readStatesFromDir loads every file in dir named by a sha256 hex hash, optionally with an
+// extension (e.g. <hash>.pb), keyed by that hash. Other files are skipped.
+func readStatesFromDir(dir string, accountId string) map[string]*servedState {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		panic(err)
+	}
+	states := make(map[string]*servedState)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		hash := strings.ToLower(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())))
+		if !isSha256Hex(hash) {
+			log.Printf("skipping %s in state dir: name is not a sha256 hex hash", entry.Name())
+			continue
+		}
+		states[hash] = newServedState(readStateFromDisk(filepath.Join(dir, entry.Name()), accountId))
+		log.Printf("serving state %s at /%s", entry.Name(), hash)
+	}
+	return states
+}
+
+func isSha256Hex(s string) bool {
+	if len(s) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
 func readStateFromUrl(path string) []byte {
 	// Blocking HTTP GET read of the provided URL path.
 	resp, err := http.Get("https://confidence-resolver-state-cdn.spotifycdn.com/" + path)
@@ -374,12 +721,20 @@ func readStateFromUrl(path string) []byte {
 	return b
 }
 
+// readStateFromDisk reads a resolver state file wrapped for the guest. Files ending in .json
+// hold the protojson of an adminv1.ResolverState, any other file the binary proto.
 func readStateFromDisk(path string, accountId string) []byte {
 	// Blocking read from local filesystem.
 	b, err := os.ReadFile(path)
 	if err != nil {
 		panic(err)
 	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		b, err = resolverStateFromJSON(b)
+		if err != nil {
+			panic(fmt.Errorf("failed to parse %s: %w", path, err))
+		}
+	}
 	msg := &pb.ClientResolverState{
 		State:   b,
 		Account: accountId,
@@ -389,4 +744,32 @@ func readStateFromDisk(path string, accountId string) []byte {
 		panic(err)
 	}
 	return out
-}
\ No newline at end of file
+}
+
+// resolverStateFromJSON converts the protojson of an adminv1.ResolverState to its binary form
+func resolverStateFromJSON(b []byte) ([]byte, error) {
+	state := &adminv1.ResolverState{}
+	if err := protojson.Unmarshal(b, state); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(state)
+}
+
+// readResolveResponse reads a ResolveFlagsResponse file, protojson if it ends in .json and
+// binary proto otherwise
+func readResolveResponse(path string) *resolverv1.ResolveFlagsResponse {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	resp := &resolverv1.ResolveFlagsResponse{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = protojson.Unmarshal(b, resp)
+	} else {
+		err = proto.Unmarshal(b, resp)
+	}
+	if err != nil {
+		panic(fmt.Errorf("failed to parse %s: %w", path, err))
+	}
+	return resp
+}
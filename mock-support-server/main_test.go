@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	pb "github.com/spotify/confidence-resolver-rust/mock-support-server/genproto/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// syncBuffer is a bytes.Buffer safe to read while the logger writes to it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestShutdownDrainsInFlightWrites(t *testing.T) {
+	var logs syncBuffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	statePath := filepath.Join(t.TempDir(), "state.pb")
+	if err := os.WriteFile(statePath, []byte("state"), 0o644); err != nil {
+		t.Fatalf("failed to write state: %v", err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	cfg := config{
+		AccountID:         "account",
+		ResolverStatePath: statePath,
+		ClientSecret:      "secret",
+		LatencyMs:         500,
+		ShutdownTimeout:   5 * time.Second,
+	}
+	stopped := make(chan struct{})
+	go func() {
+		run(cfg, lis)
+		close(stopped)
+	}()
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithAuthority("edge-grpc.spotify.com"))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	client := pb.NewInternalFlagLoggerServiceClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "ClientSecret secret")
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := client.ClientWriteFlagLogs(ctx, &pb.WriteFlagLogsRequest{})
+		written <- err
+	}()
+	// Signal once the write is held up by the artificial latency
+	time.Sleep(200 * time.Millisecond)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to send SIGTERM: %v", err)
+	}
+
+	if err := <-written; err != nil {
+		t.Errorf("expected the in-flight write to complete, got: %v", err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+	if !strings.Contains(logs.String(), "final metrics bytes_total=0 applied_total=0 req_total=1") {
+		t.Errorf("expected the final metrics to count the write, got:\n%s", logs.String())
+	}
+	if _, err := client.ClientWriteFlagLogs(ctx, &pb.WriteFlagLogsRequest{}); err == nil {
+		t.Error("expected writes after shutdown to fail")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBucketBits sets the histogram precision: each power of two is split into
+// 2^subBucketBits buckets, bounding the recorded error to about 6%
+const subBucketBits = 4
+
+const (
+	subBuckets = 1 << subBucketBits
+	numBuckets = subBuckets + (64-subBucketBits)*subBuckets
+)
+
+// histogram is a log-linear latency histogram. It is not safe for concurrent use;
+// record on one goroutine each and merge the results.
+type histogram struct {
+	counts [numBuckets]uint64
+	total  uint64
+	max    time.Duration
+}
+
+func bucketIndex(v uint64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - subBucketBits - 1
+	return subBuckets + shift*subBuckets + int(v>>shift) - subBuckets
+}
+
+// bucketUpperBound is the largest value recorded in the bucket
+func bucketUpperBound(index int) uint64 {
+	if index < subBuckets {
+		return uint64(index)
+	}
+	shift := (index - subBuckets) / subBuckets
+	mantissa := uint64(subBuckets + (index-subBuckets)%subBuckets)
+	return (mantissa+1)<<shift - 1
+}
+
+func (h *histogram) record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.counts[bucketIndex(uint64(d))]++
+	h.total++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+func (h *histogram) merge(other *histogram) {
+	for i, count := range other.counts {
+		h.counts[i] += count
+	}
+	h.total += other.total
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// percentile returns an upper bound of the latency below which q (0-1) of the recorded
+// values fall, capped at the recorded max
+func (h *histogram) percentile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	target := uint64(math.Ceil(q * float64(h.total)))
+	if target == 0 {
+		target = 1
+	}
+	var seen uint64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= target {
+			return min(time.Duration(bucketUpperBound(i)), h.max)
+		}
+	}
+	return h.max
+}
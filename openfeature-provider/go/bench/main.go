@@ -8,6 +8,8 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"runtime/pprof"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -22,15 +24,22 @@ import (
 type stats struct {
 	completed uint64
 	errors    uint64
+	mu        sync.Mutex // guards latency
+	latency   histogram
+
+	// resolves that succeeded with a variant other than -expect-variant
+	mismatches uint64
 }
 
 type transportHooks struct {
 	mockAddr string
+	netSim   netSim
 }
 
 func (t transportHooks) ModifyGRPCDial(target string, base []grpc.DialOption) (string, []grpc.DialOption) {
+	opts := append([]grpc.DialOption{}, base...)
+	opts = append(opts, t.netSim.dialOptions()...)
 	if t.mockAddr != "" {
-		opts := append([]grpc.DialOption{}, base...)
 		// Route to mock in plaintext and preserve logical authority for routing
 		opts = append(opts,
 			grpc.WithTransportCredentials(insecure.NewCredentials()),
@@ -38,7 +47,7 @@ func (t transportHooks) ModifyGRPCDial(target string, base []grpc.DialOption) (s
 		)
 		return t.mockAddr, opts
 	}
-	return target, base
+	return target, opts
 }
 
 // rtFunc adapts a function to http.RoundTripper
@@ -47,6 +56,9 @@ type rtFunc func(*http.Request) (*http.Response, error)
 func (f rtFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
 
 func (t transportHooks) WrapHTTP(base http.RoundTripper) http.RoundTripper {
+	if t.netSim.enabled() {
+		base = t.netSim.wrapHTTP(base)
+	}
 	// Send HTTP requests to mockAddr over http, preserving path and query.
 	if t.mockAddr != "" {
 		return rtFunc(func(req *http.Request) (*http.Response, error) {
@@ -72,6 +84,15 @@ func main() {
 		flagKey         string
 		clientSecret    string
 		pollInterval    int
+		warmupRetries   int
+		injectLatency   time.Duration
+		injectBandwidth int
+		warmupAllowErr  bool
+		ramp            bool
+		rampStep        time.Duration
+		cpuProfile      string
+		memProfile      string
+		expectVariant   string
 	)
 
 	flag.StringVar(&mockAddr, "mock-addr", "localhost:8081", "mock support server address host:port")
@@ -82,6 +103,15 @@ func main() {
 	flag.StringVar(&flagKey, "flag", "example-flag", "flag key (without 'flags/' prefix)")
 	flag.StringVar(&clientSecret, "client-secret", "secret", "client secret for request signing")
 	flag.IntVar(&pollInterval, "poll-interval", 10, "resolver state/log poll interval in seconds (env override)")
+	flag.IntVar(&warmupRetries, "warmup-retries", 3, "times to retry a failed warmup, with backoff, before aborting")
+	flag.DurationVar(&injectLatency, "inject-latency", 0, "artificial latency added to every HTTP request and gRPC call, e.g. 50ms")
+	flag.IntVar(&injectBandwidth, "inject-bandwidth", 0, "client-side bandwidth cap in kilobytes per second (0 disables throttling)")
+	flag.BoolVar(&warmupAllowErr, "warmup-allow-errors", false, "log errors during warmup instead of aborting; only measurement errors count")
+	flag.BoolVar(&ramp, "ramp", false, "measure at 1, 2, 4, ... threads up to -threads, each for -ramp-step, instead of -duration at -threads")
+	flag.DurationVar(&rampStep, "ramp-step", 5*time.Second, "measurement duration of each -ramp step")
+	flag.StringVar(&cpuProfile, "cpuprofile", "", "write a pprof CPU profile of the measurement phase to this file")
+	flag.StringVar(&memProfile, "memprofile", "", "write a pprof heap profile to this file after the measurement phase")
+	flag.StringVar(&expectVariant, "expect-variant", "", "check each measured resolve returns this variant (name or full flags/<flag>/variants/<name>), exiting non-zero on mismatches")
 	flag.Parse()
 
 	if gomaxprocs > 0 {
@@ -96,18 +126,28 @@ func main() {
 	if durationSeconds < 1 {
 		durationSeconds = 1
 	}
+	if rampStep <= 0 {
+		rampStep = time.Second
+	}
 
 	ctx := context.Background()
 
+	hooks := transportHooks{
+		mockAddr: mockAddr,
+		netSim:   netSim{latency: injectLatency, bandwidthKbps: injectBandwidth},
+	}
 	provider, err := confidence.NewProvider(ctx, confidence.ProviderConfig{
 		ClientSecret:   clientSecret,
-		TransportHooks: transportHooks{mockAddr: mockAddr},
+		TransportHooks: hooks,
 	})
-	provider.Init(openfeature.NewTargetlessEvaluationContext(map[string]any{}))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to create provider: %v\n", err)
 		os.Exit(1)
 	}
+	if err := provider.Init(openfeature.NewTargetlessEvaluationContext(map[string]any{})); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize provider: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Minimal evaluation context; you can extend with attributes to exercise targeting
 	evalCtx := openfeature.FlattenedContext{"targetingKey": "tutorial_visitor", "visitor_id": "tutorial_visitor"}
@@ -116,18 +156,28 @@ func main() {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
-	// Warmup (abort on first error)
+	// Warmup (each attempt stops on first error, retried with backoff, unless errors are allowed)
 	if warmupSeconds > 0 {
-		warmupCtx, cancel := context.WithTimeout(ctx, time.Duration(warmupSeconds)*time.Second)
-		var warm stats
-		runWorkers(warmupCtx, provider, flagKey, evalCtx, threads, &warm, cancel, true)
-		cancel()
-		if atomic.LoadUint64(&warm.errors) > 0 {
+		if warmupAllowErr {
+			warmupLenient(ctx, provider, flagKey, evalCtx, threads, time.Duration(warmupSeconds)*time.Second)
+		} else if !warmup(ctx, provider, flagKey, evalCtx, threads, time.Duration(warmupSeconds)*time.Second, warmupRetries, sigCh) {
 			fmt.Fprintf(os.Stderr, "aborting: error during warmup\n")
 			os.Exit(1)
 		}
 	}
 
+	// Profiles cover the measurement phase only, not provider setup or warmup
+	stopCPUProfile := startCPUProfile(cpuProfile)
+
+	if ramp {
+		mismatches := runRamp(ctx, provider, flagKey, evalCtx, threads, rampStep, expectVariant, sigCh)
+		stopCPUProfile()
+		writeHeapProfile(memProfile)
+		provider.Shutdown()
+		exitOnMismatches(expectVariant, mismatches)
+		return
+	}
+
 	// Measurement
 	measureCtx, cancelMeasure := context.WithTimeout(ctx, time.Duration(durationSeconds)*time.Second)
 	defer cancelMeasure()
@@ -143,8 +193,10 @@ func main() {
 	}()
 
 	start := time.Now()
-	runWorkers(measureCtx, provider, flagKey, evalCtx, threads, &s, cancelMeasure, true)
+	runWorkers(measureCtx, provider, flagKey, evalCtx, threads, &s, cancelMeasure, true, expectVariant)
 	elapsed := time.Since(start)
+	stopCPUProfile()
+	writeHeapProfile(memProfile)
 	provider.Shutdown()
 
 	completed := atomic.LoadUint64(&s.completed)
@@ -153,21 +205,179 @@ func main() {
 
 	fmt.Printf("flag=%s threads=%d duration=%s ops=%d errors=%d throughput=%.0f ops/s\n",
 		flagKey, threads, elapsed.Truncate(time.Millisecond), completed, errs, qps)
+	fmt.Printf("latency p50=%s p90=%s p99=%s max=%s\n",
+		s.latency.percentile(0.50), s.latency.percentile(0.90), s.latency.percentile(0.99), s.latency.max)
+	exitOnMismatches(expectVariant, atomic.LoadUint64(&s.mismatches))
+}
+
+// exitOnMismatches reports the variant mismatches when -expect-variant is set and exits
+// non-zero if there were any
+func exitOnMismatches(expectVariant string, mismatches uint64) {
+	if expectVariant == "" {
+		return
+	}
+	fmt.Printf("expect-variant=%s mismatches=%d\n", expectVariant, mismatches)
+	if mismatches > 0 {
+		os.Exit(1)
+	}
+}
+
+// variantMatches reports whether variant, as returned by the provider, is expected given
+// either by name or by its full flags/<flag>/variants/<name> form
+func variantMatches(variant, expected string) bool {
+	return variant == expected || strings.HasSuffix(variant, "/variants/"+expected)
+}
+
+// startCPUProfile starts CPU profiling to path, if set, and returns a func that stops it
+func startCPUProfile(path string) func() {
+	if path == "" {
+		return func() {}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create CPU profile: %v\n", err)
+		os.Exit(1)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start CPU profile: %v\n", err)
+		os.Exit(1)
+	}
+	return func() {
+		pprof.StopCPUProfile()
+		if err := f.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write CPU profile: %v\n", err)
+		}
+	}
+}
+
+// writeHeapProfile writes a heap profile to path, if set, after a GC so it reflects live memory
+func writeHeapProfile(path string) {
+	if path == "" {
+		return
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create heap profile: %v\n", err)
+		return
+	}
+	defer f.Close()
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write heap profile: %v\n", err)
+	}
+}
+
+// warmup runs the workers for the warmup duration. An attempt that hits an error is
+// retried after an exponential backoff, up to retries times, so a slow first state
+// fetch doesn't fail the run. Returns false if all attempts failed or on signal.
+func warmup(ctx context.Context, provider *confidence.LocalResolverProvider, flagKey string, evalCtx openfeature.FlattenedContext, threads int, duration time.Duration, retries int, sigCh <-chan os.Signal) bool {
+	backoff := 500 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		warmupCtx, cancel := context.WithTimeout(ctx, duration)
+		var warm stats
+		runWorkers(warmupCtx, provider, flagKey, evalCtx, threads, &warm, cancel, true, "")
+		cancel()
+		if atomic.LoadUint64(&warm.errors) == 0 {
+			return true
+		}
+		if attempt >= retries {
+			return false
+		}
+		fmt.Fprintf(os.Stderr, "error during warmup, retrying in %s (%d/%d)\n", backoff, attempt+1, retries)
+		select {
+		case <-time.After(backoff):
+		case <-sigCh:
+			return false
+		}
+		backoff *= 2
+	}
+}
+
+// warmupLenient runs the workers for the warmup duration without stopping on errors,
+// logging how many evaluations failed
+func warmupLenient(ctx context.Context, provider *confidence.LocalResolverProvider, flagKey string, evalCtx openfeature.FlattenedContext, threads int, duration time.Duration) {
+	warmupCtx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	var warm stats
+	runWorkers(warmupCtx, provider, flagKey, evalCtx, threads, &warm, nil, false, "")
+	if errs := atomic.LoadUint64(&warm.errors); errs > 0 {
+		fmt.Fprintf(os.Stderr, "warmup: %d of %d evaluations failed, continuing\n", errs, atomic.LoadUint64(&warm.completed))
+	}
 }
 
-func runWorkers(ctx context.Context, provider *confidence.LocalResolverProvider, flagKey string, evalCtx openfeature.FlattenedContext, threads int, s *stats, cancel context.CancelFunc, abortOnError bool) {
+// runRamp measures at doubling thread counts up to maxThreads for step each, printing a row of
+// throughput, error rate and latency per step to locate the saturation point. Errors don't
+// abort a step, they count towards its error rate. A signal stops the ramp after the
+// current step. Returns the variant mismatches over all steps.
+func runRamp(ctx context.Context, provider *confidence.LocalResolverProvider, flagKey string, evalCtx openfeature.FlattenedContext, maxThreads int, step time.Duration, expectVariant string, sigCh <-chan os.Signal) (mismatches uint64) {
+	var counts []int
+	for n := 1; n < maxThreads; n *= 2 {
+		counts = append(counts, n)
+	}
+	counts = append(counts, maxThreads)
+
+	fmt.Printf("flag=%s ramp-step=%s\n", flagKey, step)
+	fmt.Printf("%8s %12s %10s %10s %14s %8s %12s %12s\n", "threads", "ops", "errors", "mismatch", "ops/s", "err%", "p50", "p99")
+	for _, n := range counts {
+		stepCtx, cancel := context.WithTimeout(ctx, step)
+		var s stats
+		var stopped atomic.Bool
+		go func() {
+			select {
+			case <-sigCh:
+				stopped.Store(true)
+				cancel()
+			case <-stepCtx.Done():
+			}
+		}()
+		start := time.Now()
+		runWorkers(stepCtx, provider, flagKey, evalCtx, n, &s, nil, false, expectVariant)
+		elapsed := time.Since(start)
+		cancel()
+
+		completed := atomic.LoadUint64(&s.completed)
+		errs := atomic.LoadUint64(&s.errors)
+		errRate := 0.0
+		if completed > 0 {
+			errRate = 100 * float64(errs) / float64(completed)
+		}
+		mismatches += atomic.LoadUint64(&s.mismatches)
+		fmt.Printf("%8d %12d %10d %10d %14.0f %7.2f%% %12s %12s\n",
+			n, completed, errs, atomic.LoadUint64(&s.mismatches), float64(completed)/elapsed.Seconds(), errRate,
+			s.latency.percentile(0.50), s.latency.percentile(0.99))
+		if stopped.Load() {
+			return mismatches
+		}
+	}
+	return mismatches
+}
+
+// runWorkers evaluates flagKey on threads goroutines until ctx is done, recording into s.
+// With expectVariant set, successful resolves returning another variant count as mismatches.
+func runWorkers(ctx context.Context, provider *confidence.LocalResolverProvider, flagKey string, evalCtx openfeature.FlattenedContext, threads int, s *stats, cancel context.CancelFunc, abortOnError bool, expectVariant string) {
 	wg := sync.WaitGroup{}
 	wg.Add(threads)
 	for i := 0; i < threads; i++ {
 		go func() {
 			defer wg.Done()
+			// Record latencies per worker and merge once done to avoid contention
+			latency := &histogram{}
+			if s != nil {
+				defer func() {
+					s.mu.Lock()
+					s.latency.merge(latency)
+					s.mu.Unlock()
+				}()
+			}
 			for {
 				select {
 				case <-ctx.Done():
 					return
 				default:
+					start := time.Now()
 					res := provider.ObjectEvaluation(context.Background(), flagKey, nil, evalCtx)
 					if s != nil {
+						latency.record(time.Since(start))
 						atomic.AddUint64(&s.completed, 1)
 						// fmt.Printf("reason %s", res.Reason)
 						if res.Reason == openfeature.ErrorReason {
@@ -176,6 +386,8 @@ func runWorkers(ctx context.Context, provider *confidence.LocalResolverProvider,
 								cancel()
 								return
 							}
+						} else if expectVariant != "" && !variantMatches(res.Variant, expectVariant) {
+							atomic.AddUint64(&s.mismatches, 1)
 						}
 					}
 				}
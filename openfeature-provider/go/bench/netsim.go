@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// netSim simulates a degraded link on the client side: latency is added before every
+// HTTP request and gRPC call, and reads and writes are throttled to bandwidthKbps
+type netSim struct {
+	latency       time.Duration
+	bandwidthKbps int
+}
+
+func (n netSim) enabled() bool {
+	return n.latency > 0 || n.bandwidthKbps > 0
+}
+
+// byteDuration is the time budget per byte, zero when bandwidth is not limited
+func (n netSim) byteDuration() time.Duration {
+	if n.bandwidthKbps <= 0 {
+		return 0
+	}
+	return time.Second / time.Duration(1024*n.bandwidthKbps)
+}
+
+// dialOptions returns gRPC dial options throttling the connection and delaying each call
+func (n netSim) dialOptions() []grpc.DialOption {
+	var opts []grpc.DialOption
+	if byteDuration := n.byteDuration(); byteDuration > 0 {
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return nil, err
+			}
+			return &throttledConn{Conn: conn, byteDuration: byteDuration}, nil
+		}))
+	}
+	if n.latency > 0 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+			time.Sleep(n.latency)
+			return invoker(ctx, method, req, reply, cc, callOpts...)
+		}))
+	}
+	return opts
+}
+
+// wrapHTTP delays each request and throttles request and response bodies
+func (n netSim) wrapHTTP(base http.RoundTripper) http.RoundTripper {
+	byteDuration := n.byteDuration()
+	return rtFunc(func(req *http.Request) (*http.Response, error) {
+		time.Sleep(n.latency)
+		if byteDuration > 0 && req.Body != nil {
+			req = req.Clone(req.Context())
+			req.Body = &throttledReadCloser{rc: req.Body, byteDuration: byteDuration}
+		}
+		resp, err := base.RoundTrip(req)
+		if err == nil && byteDuration > 0 {
+			resp.Body = &throttledReadCloser{rc: resp.Body, byteDuration: byteDuration}
+		}
+		return resp, err
+	})
+}
+
+// throttledReadCloser limits Read throughput by sleeping between chunks.
+type throttledReadCloser struct {
+	rc           io.ReadCloser
+	byteDuration time.Duration
+}
+
+func (t *throttledReadCloser) Read(p []byte) (int, error) {
+	n, err := t.rc.Read(p)
+	if n > 0 {
+		time.Sleep(time.Duration(n) * t.byteDuration)
+	}
+	return n, err
+}
+
+func (t *throttledReadCloser) Close() error { return t.rc.Close() }
+
+// throttledConn limits Read and Write throughput of a connection by sleeping after each chunk.
+type throttledConn struct {
+	net.Conn
+	byteDuration time.Duration
+}
+
+func (c *throttledConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		time.Sleep(time.Duration(n) * c.byteDuration)
+	}
+	return n, err
+}
+
+func (c *throttledConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		time.Sleep(time.Duration(n) * c.byteDuration)
+	}
+	return n, err
+}
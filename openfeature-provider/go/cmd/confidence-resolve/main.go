@@ -0,0 +1,125 @@
+// Command confidence-resolve resolves a single flag against a local resolver state file.
+//
+// Example:
+//
+//	confidence-resolve -state resolver_state.pb -account-id my-account \
+//	    -client-secret secret -flag my-flag -context '{"targetingKey":"user-1"}'
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence"
+	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
+)
+
+// discardFlagLogger drops all flag logs; resolves from the CLI are never reported.
+type discardFlagLogger struct{}
+
+func (discardFlagLogger) Write(*resolverv1.WriteFlagLogsRequest) {}
+func (discardFlagLogger) Shutdown()                              {}
+
+type result struct {
+	Flag         string      `json:"flag"`
+	Variant      string      `json:"variant"`
+	Reason       string      `json:"reason"`
+	Value        interface{} `json:"value"`
+	ErrorCode    string      `json:"errorCode,omitempty"`
+	ErrorMessage string      `json:"errorMessage,omitempty"`
+}
+
+func main() {
+	var (
+		statePath    string
+		accountID    string
+		clientSecret string
+		flagKey      string
+		contextJSON  string
+		jsonOutput   bool
+		verbose      bool
+	)
+
+	flag.StringVar(&statePath, "state", "", "path to resolver state file (SetResolverStateRequest or raw ResolverState)")
+	flag.StringVar(&accountID, "account-id", "", "account id, required unless embedded in the state file")
+	flag.StringVar(&clientSecret, "client-secret", "", "client secret to resolve with")
+	flag.StringVar(&flagKey, "flag", "", "flag key, optionally with a dot-separated value path")
+	flag.StringVar(&contextJSON, "context", "{}", "evaluation context as a JSON object")
+	flag.BoolVar(&jsonOutput, "json", false, "print the result as JSON")
+	flag.BoolVar(&verbose, "v", false, "log provider output to stderr")
+	flag.Parse()
+
+	if statePath == "" || clientSecret == "" || flagKey == "" {
+		fmt.Fprintln(os.Stderr, "-state, -client-secret and -flag are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	evalCtx := openfeature.FlattenedContext{}
+	if err := json.Unmarshal([]byte(contextJSON), &evalCtx); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -context: %v\n", err)
+		os.Exit(2)
+	}
+
+	var logOutput io.Writer = io.Discard
+	if verbose {
+		logOutput = os.Stderr
+	}
+
+	ctx := context.Background()
+	provider, err := confidence.NewProviderForTest(ctx, confidence.ProviderTestConfig{
+		StateProvider: confidence.NewFileStateProvider(statePath, accountID),
+		FlagLogger:    discardFlagLogger{},
+		ClientSecret:  clientSecret,
+		Logger:        slog.New(slog.NewTextHandler(logOutput, nil)),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create provider: %v\n", err)
+		os.Exit(1)
+	}
+	if err := provider.Init(openfeature.NewTargetlessEvaluationContext(nil)); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load state: %v\n", err)
+		os.Exit(1)
+	}
+	detail := provider.ObjectEvaluation(ctx, flagKey, nil, evalCtx)
+	provider.Shutdown()
+
+	res := result{
+		Flag:    flagKey,
+		Variant: detail.Variant,
+		Reason:  string(detail.Reason),
+		Value:   detail.Value,
+	}
+	if detail.Error() != nil {
+		res.ErrorCode = string(detail.ResolutionDetail().ErrorCode)
+		res.ErrorMessage = detail.ResolutionDetail().ErrorMessage
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(res); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode result: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		value, _ := json.Marshal(res.Value)
+		fmt.Printf("flag:    %s\n", res.Flag)
+		fmt.Printf("variant: %s\n", res.Variant)
+		fmt.Printf("reason:  %s\n", res.Reason)
+		fmt.Printf("value:   %s\n", value)
+		if res.ErrorCode != "" {
+			fmt.Printf("error:   %s: %s\n", res.ErrorCode, res.ErrorMessage)
+		}
+	}
+
+	if res.ErrorCode != "" {
+		os.Exit(1)
+	}
+}
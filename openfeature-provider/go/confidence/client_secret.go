@@ -0,0 +1,22 @@
+package confidence
+
+import "context"
+
+type clientSecretKey struct{}
+
+// WithClientSecret returns a context that makes resolves use the given client secret instead
+// of the provider's, e.g. to serve several Confidence clients from one provider. The secret
+// must belong to a client credential in the resolver state. Flag logs are still sent with the
+// provider's client secret.
+func WithClientSecret(ctx context.Context, clientSecret string) context.Context {
+	return context.WithValue(ctx, clientSecretKey{}, clientSecret)
+}
+
+// clientSecretFor returns the client secret set on ctx with WithClientSecret, falling back to
+// the provider's
+func (p *LocalResolverProvider) clientSecretFor(ctx context.Context) string {
+	if secret, ok := ctx.Value(clientSecretKey{}).(string); ok && secret != "" {
+		return secret
+	}
+	return p.clientSecret
+}
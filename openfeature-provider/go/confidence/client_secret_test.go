@@ -0,0 +1,52 @@
+package confidence
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
+	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/resolver"
+)
+
+func TestLocalResolverProvider_WithClientSecret(t *testing.T) {
+	var secrets []string
+	mockResolver := &mockResolverAPIForInit{
+		resolveWithSticky: func(request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+			secrets = append(secrets, request.ResolveRequest.ClientSecret)
+			return &resolver.ResolveWithStickyResponse{
+				ResolveResult: &resolver.ResolveWithStickyResponse_Success_{
+					Success: &resolver.ResolveWithStickyResponse_Success{
+						Response: &resolver.ResolveFlagsResponse{},
+					},
+				},
+			}, nil
+		},
+	}
+	provider := NewLocalResolverProvider(
+		func(_ context.Context, _ lr.LogSink) lr.LocalResolver { return mockResolver },
+		&tu.StateProviderMock{State: []byte("state"), AccountID: "account"},
+		&tu.MockFlagLogger{},
+		"default-secret",
+		nil,
+	)
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+	defer provider.Shutdown()
+
+	provider.ObjectEvaluation(context.Background(), "my-flag", nil, openfeature.FlattenedContext{})
+	provider.ObjectEvaluation(WithClientSecret(context.Background(), "other-secret"), "my-flag", nil, openfeature.FlattenedContext{})
+	provider.ObjectEvaluation(WithClientSecret(context.Background(), ""), "my-flag", nil, openfeature.FlattenedContext{})
+
+	expected := []string{"default-secret", "other-secret", "default-secret"}
+	if len(secrets) != len(expected) {
+		t.Fatalf("Expected %d resolves, got %d", len(expected), len(secrets))
+	}
+	for i, secret := range expected {
+		if secrets[i] != secret {
+			t.Errorf("Resolve %d: expected client secret %q, got %q", i, secret, secrets[i])
+		}
+	}
+}
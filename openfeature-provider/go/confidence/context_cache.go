@@ -0,0 +1,165 @@
+package confidence
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// defaultContextCacheSize is the number of converted evaluation contexts kept by default
+const defaultContextCacheSize = 1024
+
+type contextKey [sha256.Size]byte
+
+// contextCache is a bounded LRU of evaluation contexts already converted to proto, keyed on a
+// stable hash of the flattened context. Cached structs are shared and must not be modified.
+// A contextCache is safe for concurrent use.
+type contextCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // front is most recently used
+	entries map[contextKey]*list.Element
+}
+
+type contextCacheEntry struct {
+	key   contextKey
+	proto *structpb.Struct
+}
+
+func newContextCache(size int) *contextCache {
+	return &contextCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[contextKey]*list.Element, size),
+	}
+}
+
+func (c *contextCache) get(key contextKey) (*structpb.Struct, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*contextCacheEntry).proto, true
+}
+
+func (c *contextCache) put(key contextKey, proto *structpb.Struct) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&contextCacheEntry{key: key, proto: proto})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*contextCacheEntry).key)
+	}
+}
+
+// purge drops all entries
+func (c *contextCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[contextKey]*list.Element, c.size)
+}
+
+// contextToProto converts an evaluation context to proto, reusing a cached conversion of an
+// identical context when possible. Large integers are only encoded on a cache miss, the cache
+// is keyed on the context as passed in.
+func (p *LocalResolverProvider) contextToProto(evalCtx openfeature.FlattenedContext) (*structpb.Struct, error) {
+	if p.contextCache == nil {
+		return flattenedContextToProto(processTargetingKey(p.encodeLargeInts(evalCtx)))
+	}
+	key, ok := hashContext(evalCtx)
+	if !ok {
+		// Contains types the hash doesn't cover, e.g. pointers
+		return flattenedContextToProto(processTargetingKey(p.encodeLargeInts(evalCtx)))
+	}
+	if cached, ok := p.contextCache.get(key); ok {
+		return cached, nil
+	}
+	protoCtx, err := flattenedContextToProto(processTargetingKey(p.encodeLargeInts(evalCtx)))
+	if err != nil {
+		return nil, err
+	}
+	p.contextCache.put(key, protoCtx)
+	return protoCtx, nil
+}
+
+// hashContext returns a stable hash of a flattened context, independent of map iteration order.
+// Returns false if the context contains a value of a type that isn't hashed.
+func hashContext(evalCtx openfeature.FlattenedContext) (contextKey, bool) {
+	h := sha256.New()
+	if !hashValue(h, map[string]interface{}(evalCtx)) {
+		return contextKey{}, false
+	}
+	var key contextKey
+	h.Sum(key[:0])
+	return key, true
+}
+
+// hashValue writes a type-tagged encoding of value, covering the types goValueToProto accepts
+// except pointers
+func hashValue(h hash.Hash, value interface{}) bool {
+	var buf [9]byte
+	writeTagged := func(tag byte, n uint64) {
+		buf[0] = tag
+		binary.LittleEndian.PutUint64(buf[1:], n)
+		h.Write(buf[:])
+	}
+
+	switch v := value.(type) {
+	case nil:
+		h.Write([]byte{'n'})
+	case bool:
+		if v {
+			writeTagged('b', 1)
+		} else {
+			writeTagged('b', 0)
+		}
+	case int:
+		writeTagged('i', uint64(v))
+	case int64:
+		writeTagged('i', uint64(v))
+	case float64:
+		writeTagged('f', math.Float64bits(v))
+	case string:
+		writeTagged('s', uint64(len(v)))
+		h.Write([]byte(v))
+	case []interface{}:
+		writeTagged('l', uint64(len(v)))
+		for _, item := range v {
+			if !hashValue(h, item) {
+				return false
+			}
+		}
+	case map[string]interface{}:
+		writeTagged('m', uint64(len(v)))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			hashValue(h, k)
+			if !hashValue(h, v[k]) {
+				return false
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package confidence
+
+import (
+	"testing"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestHashContext(t *testing.T) {
+	base := func() openfeature.FlattenedContext {
+		return openfeature.FlattenedContext{
+			"targetingKey": "user-1",
+			"user":         map[string]interface{}{"country": "SE", "age": 30, "tags": []interface{}{"a", "b"}},
+			"premium":      true,
+		}
+	}
+	key, ok := hashContext(base())
+	if !ok {
+		t.Fatal("Expected context to be hashable")
+	}
+
+	// Map iteration order varies between calls, the hash must not
+	for i := 0; i < 20; i++ {
+		if other, _ := hashContext(base()); other != key {
+			t.Fatal("Expected identical contexts to hash the same")
+		}
+	}
+
+	changes := map[string]func(openfeature.FlattenedContext){
+		"nested map value": func(c openfeature.FlattenedContext) { c["user"].(map[string]interface{})["country"] = "US" },
+		"nested slice": func(c openfeature.FlattenedContext) {
+			c["user"].(map[string]interface{})["tags"] = []interface{}{"b", "a"}
+		},
+		"value type": func(c openfeature.FlattenedContext) { c["premium"] = "true" },
+		"extra key":  func(c openfeature.FlattenedContext) { c["extra"] = nil },
+	}
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			changed := base()
+			change(changed)
+			if other, _ := hashContext(changed); other == key {
+				t.Error("Expected a different hash")
+			}
+		})
+	}
+
+	str := "pointer"
+	if _, ok := hashContext(openfeature.FlattenedContext{"p": &str}); ok {
+		t.Error("Expected contexts with pointers to not be hashable")
+	}
+}
+
+func TestContextCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newContextCache(2)
+	a, b, c := contextKey{1}, contextKey{2}, contextKey{3}
+	cache.put(a, &structpb.Struct{})
+	cache.put(b, &structpb.Struct{})
+	cache.get(a)
+	cache.put(c, &structpb.Struct{})
+
+	if _, ok := cache.get(b); ok {
+		t.Error("Expected the least recently used entry to be evicted")
+	}
+	if _, ok := cache.get(a); !ok {
+		t.Error("Expected the recently used entry to be kept")
+	}
+	if _, ok := cache.get(c); !ok {
+		t.Error("Expected the new entry to be cached")
+	}
+}
+
+func TestLocalResolverProvider_ContextToProto(t *testing.T) {
+	provider := NewLocalResolverProvider(nil, nil, nil, "secret", nil)
+	evalCtx := openfeature.FlattenedContext{"targetingKey": "user-1", "country": "SE"}
+
+	first, err := provider.contextToProto(evalCtx)
+	if err != nil {
+		t.Fatalf("Failed to convert context: %v", err)
+	}
+	if first.Fields["targeting_key"].GetStringValue() != "user-1" {
+		t.Errorf("Expected targetingKey to be converted, got %v", first)
+	}
+	second, _ := provider.contextToProto(openfeature.FlattenedContext{"targetingKey": "user-1", "country": "SE"})
+	if second != first {
+		t.Error("Expected an identical context to reuse the cached proto")
+	}
+
+	provider.Shutdown()
+	third, _ := provider.contextToProto(evalCtx)
+	if third == first {
+		t.Error("Expected Shutdown to clear the cache")
+	}
+}
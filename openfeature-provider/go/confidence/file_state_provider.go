@@ -0,0 +1,68 @@
+package confidence
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileStateProvider provides resolver state read from a file on disk.
+// The file may contain either a SetResolverStateRequest, as served by the CDN,
+// or a raw ResolverState; see ParseStatePayload.
+//
+// The file is only re-read when its modification time or size changes, so it can be
+// replaced while the provider is running and the new state is picked up on the next
+// poll. Replace it atomically, e.g. by renaming a temporary file.
+type FileStateProvider struct {
+	path      string
+	accountID string
+
+	mu            sync.Mutex
+	modTime       time.Time
+	size          int64
+	state         []byte
+	loadedAccount string
+}
+
+// Compile-time interface conformance check
+var _ StateProvider = (*FileStateProvider)(nil)
+
+// NewFileStateProvider creates a new FileStateProvider. accountID is used when
+// the file does not embed an account ID.
+func NewFileStateProvider(path string, accountID string) *FileStateProvider {
+	return &FileStateProvider{
+		path:      path,
+		accountID: accountID,
+	}
+}
+
+// Provide implements the StateProvider interface. On error, the previously read
+// state (if any) is returned along with the error.
+func (f *FileStateProvider) Provide(ctx context.Context) ([]byte, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return f.state, f.loadedAccount, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if f.state != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.state, f.loadedAccount, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return f.state, f.loadedAccount, fmt.Errorf("failed to read state file: %w", err)
+	}
+	state, accountID, err := ParseStatePayload(data, f.accountID)
+	if err != nil {
+		return f.state, f.loadedAccount, err
+	}
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	f.state = state
+	f.loadedAccount = accountID
+	return state, accountID, nil
+}
@@ -0,0 +1,89 @@
+package confidence
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
+	pb "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto"
+	adminv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/admin/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFileStateProvider_Provide(t *testing.T) {
+	state := tu.CreateMinimalResolverState()
+	path := filepath.Join(t.TempDir(), "state.pb")
+	if err := os.WriteFile(path, state, 0o644); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+
+	provider := NewFileStateProvider(path, "test-account")
+	gotState, gotAccount, err := provider.Provide(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if gotAccount != "test-account" {
+		t.Errorf("Expected account ID 'test-account', got %s", gotAccount)
+	}
+	if !bytes.Equal(gotState, state) {
+		t.Error("Expected state to match file contents")
+	}
+}
+
+func TestFileStateProvider_MissingFile(t *testing.T) {
+	provider := NewFileStateProvider(filepath.Join(t.TempDir(), "missing.pb"), "test-account")
+	if _, _, err := provider.Provide(context.Background()); err == nil {
+		t.Error("Expected error for missing state file")
+	}
+}
+
+func TestFileStateProvider_PicksUpChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.pb")
+	writeState := func(state []byte, accountID string, modTime time.Time) {
+		t.Helper()
+		data, err := proto.Marshal(&pb.SetResolverStateRequest{State: state, AccountId: accountID})
+		if err != nil {
+			t.Fatalf("Failed to marshal state: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatalf("Failed to write state file: %v", err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set modification time: %v", err)
+		}
+	}
+	first := tu.CreateMinimalResolverState()
+	second, _ := proto.Marshal(&adminv1.ResolverState{Flags: []*adminv1.Flag{{Name: "flags/new-flag"}}})
+	provider := NewFileStateProvider(path, "")
+	modTime := time.Unix(1000, 0)
+
+	writeState(first, "account-1", modTime)
+	state, accountID, err := provider.Provide(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !bytes.Equal(state, first) || accountID != "account-1" {
+		t.Errorf("Expected the embedded state and account, got account %q", accountID)
+	}
+
+	writeState(second, "account-1", modTime.Add(time.Second))
+	if state, _, _ := provider.Provide(context.Background()); !bytes.Equal(state, second) {
+		t.Error("Expected the changed file to be re-read")
+	}
+
+	// A corrupt file keeps serving the last good state
+	if err := os.WriteFile(path, []byte{0xff, 0xff}, 0o644); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+	state, accountID, err = provider.Provide(context.Background())
+	if err == nil {
+		t.Error("Expected error for a corrupt file")
+	}
+	if !bytes.Equal(state, second) || accountID != "account-1" {
+		t.Errorf("Expected the last good state, got account %q", accountID)
+	}
+}
@@ -1,6 +1,9 @@
 package confidence
 
 import (
+	"time"
+
+	fl "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/flag_logger"
 	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
 )
 
@@ -8,3 +11,62 @@ type FlagLogger interface {
 	Write(request *resolverv1.WriteFlagLogsRequest)
 	Shutdown()
 }
+
+// defaultFlagLogBreakerCooldown is how long flag log writes are dropped once the circuit
+// breaker opens, unless configured otherwise
+const defaultFlagLogBreakerCooldown = 30 * time.Second
+
+// defaultFlagLogSpoolMaxBytes caps the flag log spool directory unless configured otherwise
+const defaultFlagLogSpoolMaxBytes = 64 << 20
+
+// BreakerState is the state of the flag logger's circuit breaker
+type BreakerState = fl.BreakerState
+
+const (
+	BreakerClosed   = fl.BreakerClosed
+	BreakerOpen     = fl.BreakerOpen
+	BreakerHalfOpen = fl.BreakerHalfOpen
+)
+
+// ErrFlagLogCircuitOpen is reported to Metrics.FlagLogsWritten for flag log requests dropped
+// while the flag logger's circuit breaker is open
+var ErrFlagLogCircuitOpen = fl.ErrCircuitOpen
+
+// FlagLogBreakerState returns the state of the flag logger's circuit breaker, e.g. to export
+// it as a gauge. Always BreakerClosed when no breaker is configured or a custom FlagLogger
+// is used.
+func (p *LocalResolverProvider) FlagLogBreakerState() BreakerState {
+	if logger, ok := p.flagLogger.(interface{ BreakerState() fl.BreakerState }); ok {
+		return logger.BreakerState()
+	}
+	return BreakerClosed
+}
+
+// FlagLogsDropped returns the number of flag log requests dropped while the flag logger's
+// circuit breaker was open. Requests spooled instead (ProviderConfig.FlagLogSpoolDir) aren't
+// counted.
+func (p *LocalResolverProvider) FlagLogsDropped() int64 {
+	if logger, ok := p.flagLogger.(interface{ DroppedWrites() int64 }); ok {
+		return logger.DroppedWrites()
+	}
+	return 0
+}
+
+// FlushSummary counts the entries of a batch of flag logs flushed from the resolver
+type FlushSummary struct {
+	FlagAssigned      int
+	ClientResolveInfo int
+	FlagResolveInfo   int
+}
+
+// FlushObserver is called with each batch of flag logs flushed from the resolver, before
+// it is handed to the FlagLogger. The request may be modified in place, e.g. to sample exposures.
+type FlushObserver func(summary FlushSummary, request *resolverv1.WriteFlagLogsRequest)
+
+func summarizeFlagLogs(request *resolverv1.WriteFlagLogsRequest) FlushSummary {
+	return FlushSummary{
+		FlagAssigned:      len(request.FlagAssigned),
+		ClientResolveInfo: len(request.ClientResolveInfo),
+		FlagResolveInfo:   len(request.FlagResolveInfo),
+	}
+}
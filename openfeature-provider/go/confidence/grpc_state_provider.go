@@ -0,0 +1,85 @@
+package confidence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync/atomic"
+
+	adminv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/admin/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// GrpcStateProvider fetches the resolver state from the Confidence admin
+// ResolverStateService over gRPC, as an alternative to the CDN for environments
+// without CDN access. Authentication is configured on the connection passed in,
+// e.g. with per-RPC credentials or an interceptor attaching an access token.
+type GrpcStateProvider struct {
+	client    adminv1.ResolverStateServiceClient
+	logger    *slog.Logger
+	accountID atomic.Value // stores string
+}
+
+// Compile-time interface conformance check
+var _ StateProvider = (*GrpcStateProvider)(nil)
+
+// NewGrpcStateProvider creates a GrpcStateProvider using the given connection
+func NewGrpcStateProvider(conn grpc.ClientConnInterface, logger *slog.Logger) *GrpcStateProvider {
+	return &GrpcStateProvider{
+		client: adminv1.NewResolverStateServiceClient(conn),
+		logger: logger,
+	}
+}
+
+// Provide streams the full resolver state and returns it together with the account ID
+func (g *GrpcStateProvider) Provide(ctx context.Context) ([]byte, string, error) {
+	accountID, err := g.getAccountID(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stream, err := g.client.FullResolverState(ctx, &adminv1.ResolverStateRequest{})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to request resolver state: %w", err)
+	}
+	// The state may be split across several messages which together form the full state
+	state := &adminv1.ResolverState{}
+	chunks := 0
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to receive resolver state: %w", err)
+		}
+		proto.Merge(state, chunk)
+		chunks++
+	}
+
+	data, err := proto.Marshal(state)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal resolver state: %w", err)
+	}
+	g.logger.Debug("Loaded resolver state over gRPC", "chunks", chunks, "bytes", len(data), "account", accountID)
+	return data, accountID, nil
+}
+
+// getAccountID looks up the account the state belongs to, once
+func (g *GrpcStateProvider) getAccountID(ctx context.Context) (string, error) {
+	if accountID, ok := g.accountID.Load().(string); ok {
+		return accountID, nil
+	}
+	resp, err := g.client.ResolverStateUri(ctx, &adminv1.ResolverStateUriRequest{})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up account: %w", err)
+	}
+	if resp.Account == "" {
+		return "", fmt.Errorf("resolver state service returned an empty account")
+	}
+	g.accountID.Store(resp.Account)
+	return resp.Account, nil
+}
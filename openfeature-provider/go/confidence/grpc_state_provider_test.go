@@ -0,0 +1,105 @@
+package confidence
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"testing"
+
+	adminv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/admin/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+type fakeResolverStateService struct {
+	adminv1.UnimplementedResolverStateServiceServer
+	chunks      []*adminv1.ResolverState
+	account     string
+	accountErr  error
+	uriRequests int
+}
+
+func (f *fakeResolverStateService) FullResolverState(_ *adminv1.ResolverStateRequest, stream grpc.ServerStreamingServer[adminv1.ResolverState]) error {
+	for _, chunk := range f.chunks {
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeResolverStateService) ResolverStateUri(_ context.Context, _ *adminv1.ResolverStateUriRequest) (*adminv1.ResolverStateUriResponse, error) {
+	f.uriRequests++
+	if f.accountErr != nil {
+		return nil, f.accountErr
+	}
+	return &adminv1.ResolverStateUriResponse{Account: f.account}, nil
+}
+
+// newGrpcStateProviderForTest serves the fake service in-process and returns a provider connected to it
+func newGrpcStateProviderForTest(t *testing.T, service *fakeResolverStateService) *GrpcStateProvider {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	adminv1.RegisterResolverStateServiceServer(server, service)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return NewGrpcStateProvider(conn, slog.New(slog.NewTextHandler(os.Stderr, nil)))
+}
+
+func TestGrpcStateProvider_Provide(t *testing.T) {
+	service := &fakeResolverStateService{
+		chunks: []*adminv1.ResolverState{
+			{Flags: []*adminv1.Flag{{Name: "flags/a"}}},
+			{Flags: []*adminv1.Flag{{Name: "flags/b"}}},
+		},
+		account: "test-account",
+	}
+	provider := newGrpcStateProviderForTest(t, service)
+
+	for i := 0; i < 2; i++ {
+		data, accountID, err := provider.Provide(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if accountID != "test-account" {
+			t.Errorf("Expected account 'test-account', got: %s", accountID)
+		}
+		state := &adminv1.ResolverState{}
+		if err := proto.Unmarshal(data, state); err != nil {
+			t.Fatalf("Failed to unmarshal state: %v", err)
+		}
+		if len(state.Flags) != 2 {
+			t.Errorf("Expected chunks to be merged into 2 flags, got %d", len(state.Flags))
+		}
+	}
+	if service.uriRequests != 1 {
+		t.Errorf("Expected account to be looked up once, got %d lookups", service.uriRequests)
+	}
+}
+
+func TestGrpcStateProvider_AccountError(t *testing.T) {
+	provider := newGrpcStateProviderForTest(t, &fakeResolverStateService{
+		accountErr: status.Error(codes.Unauthenticated, "missing token"),
+	})
+
+	if _, _, err := provider.Provide(context.Background()); err == nil {
+		t.Fatal("Expected error when the account lookup fails")
+	}
+}
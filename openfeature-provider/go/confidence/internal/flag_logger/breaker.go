@@ -0,0 +1,140 @@
+package flag_logger
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for writes rejected while the circuit breaker is open
+var ErrCircuitOpen = errors.New("flag logger circuit breaker is open")
+
+// maxCooldownFactor caps the exponential growth of the breaker cooldown
+const maxCooldownFactor = 32
+
+// BreakerState is the state of the flag logger's circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed sends writes normally
+	BreakerClosed BreakerState = iota
+	// BreakerOpen drops writes until the cooldown has passed
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe write through to test the backend
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// circuitBreaker opens after a number of consecutive failures and rejects calls for a
+// cooldown, then lets one probe through. A failed probe reopens it with the cooldown
+// doubled, up to maxCooldownFactor times the base; a success closes it.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	base      time.Duration
+	cooldown  time.Duration
+	failures  int
+	state     BreakerState
+	openedAt  time.Time
+	now       func() time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		base:      cooldown,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// allow reports whether a call may proceed. In the half-open state only the first
+// caller is let through until its result is recorded.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		return true
+	case BreakerHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// record updates the breaker with the outcome of an allowed call and returns the
+// cooldown if the call opened the breaker, otherwise zero
+func (b *circuitBreaker) record(err error) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.state = BreakerClosed
+		b.failures = 0
+		b.cooldown = b.base
+		return 0
+	}
+	b.failures++
+	switch {
+	case b.state == BreakerHalfOpen:
+		b.cooldown = min(2*b.cooldown, maxCooldownFactor*b.base)
+	case b.state == BreakerClosed && b.failures >= b.threshold:
+	default:
+		return 0
+	}
+	b.state = BreakerOpen
+	b.openedAt = b.now()
+	return b.cooldown
+}
+
+func (b *circuitBreaker) current() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// SetCircuitBreaker stops sending writes after failureThreshold consecutive failures.
+// Writes are dropped for the cooldown, after which one probe write is sent; the cooldown
+// doubles each time the probe fails and resets once a write succeeds. Must be called
+// before the logger is used.
+func (g *GrpcFlagLogger) SetCircuitBreaker(failureThreshold int, cooldown time.Duration) error {
+	if failureThreshold < 1 {
+		return fmt.Errorf("failure threshold must be at least 1, got %d", failureThreshold)
+	}
+	if cooldown <= 0 {
+		return fmt.Errorf("cooldown must be positive, got %v", cooldown)
+	}
+	g.breaker = newCircuitBreaker(failureThreshold, cooldown)
+	return nil
+}
+
+// BreakerState returns the state of the circuit breaker, always BreakerClosed when none is configured
+func (g *GrpcFlagLogger) BreakerState() BreakerState {
+	if g.breaker == nil {
+		return BreakerClosed
+	}
+	return g.breaker.current()
+}
+
+// DroppedWrites returns the number of requests not sent because the circuit breaker was open,
+// not counting those stored in the spool
+func (g *GrpcFlagLogger) DroppedWrites() int64 {
+	return g.dropped.Load()
+}
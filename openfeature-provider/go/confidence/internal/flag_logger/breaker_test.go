@@ -0,0 +1,117 @@
+package flag_logger
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	resolverevents "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverevents"
+	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(1000, 0)
+	breaker := newCircuitBreaker(2, time.Second)
+	breaker.now = func() time.Time { return now }
+	failure := errors.New("unavailable")
+
+	breaker.record(failure)
+	if !breaker.allow() || breaker.current() != BreakerClosed {
+		t.Fatal("Expected the breaker to stay closed below the threshold")
+	}
+	if cooldown := breaker.record(failure); cooldown != time.Second {
+		t.Fatalf("Expected the breaker to open with the base cooldown, got %v", cooldown)
+	}
+	if breaker.allow() {
+		t.Error("Expected calls to be rejected while open")
+	}
+
+	now = now.Add(time.Second)
+	if !breaker.allow() || breaker.current() != BreakerHalfOpen {
+		t.Fatal("Expected a probe to be allowed after the cooldown")
+	}
+	if breaker.allow() {
+		t.Error("Expected only one probe while half-open")
+	}
+	if cooldown := breaker.record(failure); cooldown != 2*time.Second {
+		t.Errorf("Expected a failed probe to double the cooldown, got %v", cooldown)
+	}
+
+	now = now.Add(time.Second)
+	if breaker.allow() {
+		t.Error("Expected the doubled cooldown to apply")
+	}
+	now = now.Add(time.Second)
+	if !breaker.allow() {
+		t.Fatal("Expected a probe after the doubled cooldown")
+	}
+	breaker.record(nil)
+	if breaker.current() != BreakerClosed || breaker.cooldown != time.Second {
+		t.Errorf("Expected a successful probe to close the breaker and reset the cooldown, got %v %v", breaker.current(), breaker.cooldown)
+	}
+
+	for i := 0; i < 10; i++ {
+		breaker.cooldown = 2 * breaker.cooldown
+	}
+	breaker.state = BreakerHalfOpen
+	if cooldown := breaker.record(failure); cooldown != maxCooldownFactor*time.Second {
+		t.Errorf("Expected the cooldown to be capped, got %v", cooldown)
+	}
+}
+
+func TestGrpcWasmFlagLogger_CircuitBreaker(t *testing.T) {
+	var callCount int32
+	backendErr := errors.New("backend unavailable")
+	mockStub := &mockInternalFlagLoggerServiceClient{
+		writeFlagLogsFunc: func(ctx context.Context, req *resolverv1.WriteFlagLogsRequest) (*resolverv1.WriteFlagLogsResponse, error) {
+			atomic.AddInt32(&callCount, 1)
+			return nil, backendErr
+		},
+	}
+	logger := NewGrpcWasmFlagLogger(mockStub, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err := logger.SetCircuitBreaker(2, time.Hour); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	request := &resolverv1.WriteFlagLogsRequest{
+		FlagAssigned: []*resolverevents.FlagAssigned{{ResolveId: "resolve-1"}},
+	}
+	for i := 0; i < 2; i++ {
+		if err := logger.WriteSync(context.Background(), request); !errors.Is(err, backendErr) {
+			t.Fatalf("Expected the backend error, got: %v", err)
+		}
+	}
+	if logger.BreakerState() != BreakerOpen {
+		t.Fatalf("Expected the breaker to open, got %v", logger.BreakerState())
+	}
+	if err := logger.WriteSync(context.Background(), request); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got: %v", err)
+	}
+	logger.Write(request)
+	logger.Write(request)
+	logger.Shutdown()
+
+	if calls := atomic.LoadInt32(&callCount); calls != 2 {
+		t.Errorf("Expected no calls while the breaker is open, got %d calls", calls)
+	}
+	if dropped := logger.DroppedWrites(); dropped != 2 {
+		t.Errorf("Expected 2 dropped writes, got %d", dropped)
+	}
+}
+
+func TestGrpcWasmFlagLogger_CircuitBreaker_InvalidConfig(t *testing.T) {
+	logger := NewGrpcWasmFlagLogger(&mockInternalFlagLoggerServiceClient{}, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err := logger.SetCircuitBreaker(0, time.Second); err == nil {
+		t.Error("Expected error for a zero failure threshold")
+	}
+	if err := logger.SetCircuitBreaker(1, 0); err == nil {
+		t.Error("Expected error for a zero cooldown")
+	}
+	if logger.BreakerState() != BreakerClosed {
+		t.Error("Expected BreakerClosed without a breaker")
+	}
+}
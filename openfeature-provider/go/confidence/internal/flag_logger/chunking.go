@@ -0,0 +1,49 @@
+package flag_logger
+
+import (
+	"fmt"
+
+	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// SetMaxChunkBytes caps the serialized size of each request sent to the backend. Larger
+// requests are split into several, keeping the ClientResolveInfo, FlagResolveInfo and
+// TelemetryData on the first one only. A single FlagAssigned entry over the budget is sent
+// on its own. Zero (the default) sends requests unsplit. Must be called before the logger
+// is used.
+func (g *GrpcFlagLogger) SetMaxChunkBytes(maxBytes int) error {
+	if maxBytes < 0 {
+		return fmt.Errorf("max chunk bytes must not be negative, got %d", maxBytes)
+	}
+	g.maxChunkBytes = maxBytes
+	return nil
+}
+
+// chunkFlagLogs splits request so each chunk stays within maxBytes where possible
+func chunkFlagLogs(request *resolverv1.WriteFlagLogsRequest, maxBytes int) []*resolverv1.WriteFlagLogsRequest {
+	if maxBytes <= 0 || proto.Size(request) <= maxBytes {
+		return []*resolverv1.WriteFlagLogsRequest{request}
+	}
+
+	current := &resolverv1.WriteFlagLogsRequest{
+		TelemetryData:     request.TelemetryData,
+		ClientResolveInfo: request.ClientResolveInfo,
+		FlagResolveInfo:   request.FlagResolveInfo,
+	}
+	size := proto.Size(current)
+	chunks := []*resolverv1.WriteFlagLogsRequest{current}
+	for _, assigned := range request.FlagAssigned {
+		// Each entry is a length-delimited field 1, a one byte tag
+		entrySize := 1 + protowire.SizeBytes(proto.Size(assigned))
+		if size+entrySize > maxBytes && size > 0 {
+			current = &resolverv1.WriteFlagLogsRequest{}
+			size = 0
+			chunks = append(chunks, current)
+		}
+		current.FlagAssigned = append(current.FlagAssigned, assigned)
+		size += entrySize
+	}
+	return chunks
+}
@@ -2,20 +2,39 @@ package flag_logger
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
 )
 
+// defaultCallTimeout bounds each WriteFlagLogs call unless configured otherwise
+const defaultCallTimeout = 30 * time.Second
+
+// GrpcFlagLogger sends flag logs to the Confidence backend. Each Write is sent on a
+// short-lived goroutine that ends when the RPC completes or times out; there is no
+// long-running writer. Call Shutdown to wait for in-flight writes before exiting.
 type GrpcFlagLogger struct {
 	stub         resolverv1.InternalFlagLoggerServiceClient
 	clientSecret string
 	logger       *slog.Logger
 	wg           sync.WaitGroup
+	mu           sync.Mutex // guards closed and wg.Add against Shutdown
+	closed       bool
+	sampler      *exposureSampler
+	// maxChunkBytes caps the size of each request sent, zero sends requests unsplit
+	maxChunkBytes int
+	breaker       *circuitBreaker // nil when no circuit breaker is configured
+	dropped       atomic.Int64
+	spool         *spool // nil when failed writes aren't spooled
+	callTimeout   time.Duration
+	onWritten     func(bytes int, err error) // nil when writes aren't observed
 }
 
 func NewGrpcWasmFlagLogger(stub resolverv1.InternalFlagLoggerServiceClient, clientSecret string, logger *slog.Logger) *GrpcFlagLogger {
@@ -23,18 +42,82 @@ func NewGrpcWasmFlagLogger(stub resolverv1.InternalFlagLoggerServiceClient, clie
 		stub:         stub,
 		clientSecret: clientSecret,
 		logger:       logger,
+		callTimeout:  defaultCallTimeout,
+	}
+}
+
+// SetCallTimeout bounds each WriteFlagLogs call sent to the backend, so a hung server can't
+// hold a write forever. Calls that time out fail like any other. Defaults to 30 seconds.
+// Must be called before the logger is used.
+func (g *GrpcFlagLogger) SetCallTimeout(timeout time.Duration) error {
+	if timeout <= 0 {
+		return fmt.Errorf("call timeout must be positive, got %s", timeout)
+	}
+	g.callTimeout = timeout
+	return nil
+}
+
+// SetWriteObserver sets a function called after each request sent to the backend with its
+// serialized size and the outcome of the call, nil on success. Requests dropped by the
+// circuit breaker are reported with ErrCircuitOpen. Must be called before the logger is used.
+func (g *GrpcFlagLogger) SetWriteObserver(observer func(bytes int, err error)) {
+	g.onWritten = observer
+}
+
+// SetExposureSampling configures one in N sampling of FlagAssigned exposures per flag,
+// keyed by flag name (e.g. "flags/my-flag"). Flags not in the map are always logged.
+// Must be called before the logger is used.
+func (g *GrpcFlagLogger) SetExposureSampling(rates map[string]int) error {
+	sampler, err := newExposureSampler(rates)
+	if err != nil {
+		return err
 	}
+	g.sampler = sampler
+	return nil
 }
 
 // Write writes flag logs, splitting into chunks if necessary
 func (g *GrpcFlagLogger) Write(request *resolverv1.WriteFlagLogsRequest) {
+	sampling, ok := g.prepare(request)
+	if !ok {
+		return
+	}
+	for _, chunk := range chunkFlagLogs(request, g.maxChunkBytes) {
+		g.sendAsync(chunk, sampling)
+	}
+}
+
+// WriteSync sends flag logs and waits for the backend to acknowledge them, bypassing
+// the async path used by Write. Use it where delivery must be confirmed, at the cost
+// of the call's latency. Empty requests are skipped and return nil.
+func (g *GrpcFlagLogger) WriteSync(ctx context.Context, request *resolverv1.WriteFlagLogsRequest) error {
+	sampling, ok := g.prepare(request)
+	if !ok {
+		return nil
+	}
+	for _, chunk := range chunkFlagLogs(request, g.maxChunkBytes) {
+		if err := g.send(ctx, chunk, sampling); err != nil {
+			return fmt.Errorf("failed to write flag logs: %w", err)
+		}
+	}
+	return nil
+}
+
+// prepare applies exposure sampling and returns the sampling metadata to send along.
+// Returns false if nothing is left to send.
+func (g *GrpcFlagLogger) prepare(request *resolverv1.WriteFlagLogsRequest) (string, bool) {
+	sampling := ""
+	if g.sampler != nil && g.sampler.sample(request) {
+		sampling = g.sampler.header
+	}
+
 	flagAssignedCount := len(request.FlagAssigned)
 	clientResolveCount := len(request.ClientResolveInfo)
 	flagResolveCount := len(request.FlagResolveInfo)
 
 	if clientResolveCount == 0 && flagAssignedCount == 0 && flagResolveCount == 0 {
 		g.logger.Debug("Skipping empty flag log request")
-		return
+		return "", false
 	}
 
 	if request.TelemetryData != nil {
@@ -54,32 +137,91 @@ func (g *GrpcFlagLogger) Write(request *resolverv1.WriteFlagLogsRequest) {
 		"client_resolve_info", clientResolveCount,
 		"flag_resolve_info", flagResolveCount)
 
-	g.sendAsync(request)
-
+	return sampling, true
 }
 
-func (g *GrpcFlagLogger) sendAsync(request *resolverv1.WriteFlagLogsRequest) {
+// sendAsync sends the request on its own goroutine, tracked until Shutdown.
+// After Shutdown the request is sent on the calling goroutine instead.
+func (g *GrpcFlagLogger) sendAsync(request *resolverv1.WriteFlagLogsRequest, sampling string) {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		g.deliver(request, sampling)
+		return
+	}
 	g.wg.Add(1)
+	g.mu.Unlock()
+
 	go func() {
 		defer g.wg.Done()
-		// Create a context with timeout for the RPC
-		rpcCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		// Add Authorization header with client secret
-		md := metadata.Pairs("authorization", fmt.Sprintf("ClientSecret %s", g.clientSecret))
-		rpcCtx = metadata.NewOutgoingContext(rpcCtx, md)
-
-		if _, err := g.stub.ClientWriteFlagLogs(rpcCtx, request); err != nil {
-			g.logger.Error("Failed to write flag logs", "error", err)
-		} else {
-			g.logger.Debug("Successfully sent flag log", "entries", len(request.FlagAssigned))
-		}
+		g.deliver(request, sampling)
 	}()
 }
 
-// Shutdown waits for all pending async writes to complete
+// deliver sends the request and logs the outcome. A request that fails to send is spooled
+// when a spool is set, and only lost if spooling it fails too.
+func (g *GrpcFlagLogger) deliver(request *resolverv1.WriteFlagLogsRequest, sampling string) {
+	err := g.send(context.Background(), request, sampling)
+	if err == nil {
+		g.logger.Debug("Successfully sent flag log", "entries", len(request.FlagAssigned))
+		return
+	}
+	if g.spool != nil && g.spoolFailed(request, sampling) {
+		g.logger.Debug("Spooled flag logs that could not be sent", "entries", len(request.FlagAssigned), "error", err)
+		return
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		g.dropped.Add(1)
+		g.logger.Debug("Dropped flag logs while the circuit breaker is open", "entries", len(request.FlagAssigned))
+	} else {
+		g.logger.Error("Failed to write flag logs", "error", err)
+	}
+}
+
+// send writes the request to the backend, unless the circuit breaker rejects it, and reports
+// the outcome to the write observer
+func (g *GrpcFlagLogger) send(ctx context.Context, request *resolverv1.WriteFlagLogsRequest, sampling string) error {
+	err := g.sendThroughBreaker(ctx, request, sampling)
+	if g.onWritten != nil {
+		g.onWritten(proto.Size(request), err)
+	}
+	return err
+}
+
+func (g *GrpcFlagLogger) sendThroughBreaker(ctx context.Context, request *resolverv1.WriteFlagLogsRequest, sampling string) error {
+	if g.breaker != nil {
+		if !g.breaker.allow() {
+			return ErrCircuitOpen
+		}
+		err := g.write(ctx, request, sampling)
+		if cooldown := g.breaker.record(err); cooldown > 0 {
+			g.logger.Warn("Flag logger circuit breaker opened, dropping writes", "cooldown", cooldown, "error", err)
+		}
+		return err
+	}
+	return g.write(ctx, request, sampling)
+}
+
+func (g *GrpcFlagLogger) write(ctx context.Context, request *resolverv1.WriteFlagLogsRequest, sampling string) error {
+	// Add Authorization header with client secret
+	md := metadata.Pairs("authorization", fmt.Sprintf("ClientSecret %s", g.clientSecret))
+	if sampling != "" {
+		md.Set(samplingMetadataKey, sampling)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	ctx, cancel := context.WithTimeout(ctx, g.callTimeout)
+	defer cancel()
+
+	_, err := g.stub.ClientWriteFlagLogs(ctx, request)
+	return err
+}
+
+// Shutdown waits for all pending async writes to complete. Once it returns, no goroutines
+// started by the logger remain. Writes after Shutdown are sent synchronously.
 func (g *GrpcFlagLogger) Shutdown() {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
 	g.wg.Wait()
 }
 
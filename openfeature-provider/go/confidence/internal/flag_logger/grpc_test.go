@@ -3,15 +3,22 @@ package flag_logger
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	adminv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/admin/v1"
 	resolverevents "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverevents"
 	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
 )
 
 // mockInternalFlagLoggerServiceClient is a mock implementation for testing
@@ -161,6 +168,44 @@ func TestGrpcWasmFlagLogger_Shutdown(t *testing.T) {
 	}
 }
 
+func TestGrpcWasmFlagLogger_NoGoroutineLeakAfterShutdown(t *testing.T) {
+	mockStub := &mockInternalFlagLoggerServiceClient{
+		writeFlagLogsFunc: func(ctx context.Context, req *resolverv1.WriteFlagLogsRequest) (*resolverv1.WriteFlagLogsResponse, error) {
+			time.Sleep(20 * time.Millisecond)
+			return &resolverv1.WriteFlagLogsResponse{}, nil
+		},
+	}
+
+	before := runtime.NumGoroutine()
+	logger := NewGrpcWasmFlagLogger(mockStub, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	for i := 0; i < 20; i++ {
+		logger.Write(&resolverv1.WriteFlagLogsRequest{
+			FlagAssigned: make([]*resolverevents.FlagAssigned, 1),
+		})
+	}
+	if during := runtime.NumGoroutine(); during <= before {
+		t.Fatalf("Expected in-flight writes to run on goroutines, had %d before and %d during", before, during)
+	}
+
+	logger.Shutdown()
+	// Goroutines may still be unwinding right after their wg.Done
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected no goroutines left after Shutdown, had %d before and %d after", before, after)
+	}
+
+	// Writes after Shutdown are sent inline and leave nothing behind
+	logger.Write(&resolverv1.WriteFlagLogsRequest{
+		FlagAssigned: make([]*resolverevents.FlagAssigned, 1),
+	})
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected a write after Shutdown to not start a goroutine, had %d before and %d after", before, after)
+	}
+}
+
 func TestNoOpWasmFlagLogger(t *testing.T) {
 	logger := NewNoOpWasmFlagLogger()
 
@@ -174,3 +219,269 @@ func TestNoOpWasmFlagLogger(t *testing.T) {
 	// Shutdown should not panic
 	logger.Shutdown()
 }
+
+func TestGrpcWasmFlagLogger_ExposureSampling(t *testing.T) {
+	var received *resolverv1.WriteFlagLogsRequest
+	var samplingHeader []string
+	mockStub := &mockInternalFlagLoggerServiceClient{
+		writeFlagLogsFunc: func(ctx context.Context, req *resolverv1.WriteFlagLogsRequest) (*resolverv1.WriteFlagLogsResponse, error) {
+			received = req
+			md, _ := metadata.FromOutgoingContext(ctx)
+			samplingHeader = md.Get(samplingMetadataKey)
+			return &resolverv1.WriteFlagLogsResponse{}, nil
+		},
+	}
+
+	logger := NewGrpcWasmFlagLogger(mockStub, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err := logger.SetExposureSampling(map[string]int{"flags/sampled": 10}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	request := &resolverv1.WriteFlagLogsRequest{
+		ClientResolveInfo: []*adminv1.ClientResolveInfo{{Client: "clients/test"}},
+	}
+	for i := 0; i < 1000; i++ {
+		request.FlagAssigned = append(request.FlagAssigned, &resolverevents.FlagAssigned{
+			Flags: []*resolverevents.FlagAssigned_AppliedFlag{
+				{Flag: "flags/sampled", TargetingKey: fmt.Sprintf("user-%d", i)},
+				{Flag: "flags/unsampled", TargetingKey: fmt.Sprintf("user-%d", i)},
+			},
+		})
+	}
+	logger.Write(request)
+	logger.Shutdown()
+
+	if received == nil {
+		t.Fatal("Expected request to be sent")
+	}
+	sampled, unsampled := 0, 0
+	for _, assigned := range received.FlagAssigned {
+		for _, flag := range assigned.Flags {
+			switch flag.Flag {
+			case "flags/sampled":
+				sampled++
+			case "flags/unsampled":
+				unsampled++
+			}
+		}
+	}
+	if unsampled != 1000 {
+		t.Errorf("Expected all 1000 unsampled exposures to be kept, got %d", unsampled)
+	}
+	if sampled < 50 || sampled > 150 {
+		t.Errorf("Expected roughly 100 sampled exposures, got %d", sampled)
+	}
+	if len(received.ClientResolveInfo) != 1 {
+		t.Errorf("Expected client resolve info to be preserved, got %d", len(received.ClientResolveInfo))
+	}
+	if len(samplingHeader) != 1 || samplingHeader[0] != "flags/sampled=10" {
+		t.Errorf("Expected sampling metadata, got: %v", samplingHeader)
+	}
+}
+
+func TestGrpcWasmFlagLogger_ExposureSampling_InvalidConfig(t *testing.T) {
+	logger := NewGrpcWasmFlagLogger(&mockInternalFlagLoggerServiceClient{}, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err := logger.SetExposureSampling(map[string]int{"flags/my-flag": 0}); err == nil {
+		t.Error("Expected error for zero sampling rate")
+	}
+	if err := logger.SetExposureSampling(map[string]int{"my-flag": 10}); err == nil {
+		t.Error("Expected error for flag name without flags/ prefix")
+	}
+}
+
+func TestGrpcWasmFlagLogger_WriteSync(t *testing.T) {
+	var callCount int32
+	backendErr := errors.New("backend unavailable")
+	var failNext atomic.Bool
+	mockStub := &mockInternalFlagLoggerServiceClient{
+		writeFlagLogsFunc: func(ctx context.Context, req *resolverv1.WriteFlagLogsRequest) (*resolverv1.WriteFlagLogsResponse, error) {
+			atomic.AddInt32(&callCount, 1)
+			if failNext.Load() {
+				return nil, backendErr
+			}
+			return &resolverv1.WriteFlagLogsResponse{}, nil
+		},
+	}
+
+	logger := NewGrpcWasmFlagLogger(mockStub, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	ctx := context.Background()
+
+	if err := logger.WriteSync(ctx, &resolverv1.WriteFlagLogsRequest{}); err != nil {
+		t.Errorf("Expected no error for empty request, got: %v", err)
+	}
+	if atomic.LoadInt32(&callCount) != 0 {
+		t.Errorf("Expected empty request to be skipped, got %d calls", callCount)
+	}
+
+	request := &resolverv1.WriteFlagLogsRequest{
+		FlagAssigned: []*resolverevents.FlagAssigned{{ResolveId: "resolve-1"}},
+	}
+	if err := logger.WriteSync(ctx, request); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+	// The call is synchronous, so it has completed without waiting on Shutdown
+	if atomic.LoadInt32(&callCount) != 1 {
+		t.Errorf("Expected 1 call after WriteSync returned, got %d", callCount)
+	}
+
+	failNext.Store(true)
+	if err := logger.WriteSync(ctx, request); !errors.Is(err, backendErr) {
+		t.Errorf("Expected backend error to be returned, got: %v", err)
+	}
+}
+
+func TestGrpcWasmFlagLogger_MaxChunkBytes(t *testing.T) {
+	var mu sync.Mutex
+	var received []*resolverv1.WriteFlagLogsRequest
+	mockStub := &mockInternalFlagLoggerServiceClient{
+		writeFlagLogsFunc: func(ctx context.Context, req *resolverv1.WriteFlagLogsRequest) (*resolverv1.WriteFlagLogsResponse, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, req)
+			return &resolverv1.WriteFlagLogsResponse{}, nil
+		},
+	}
+
+	logger := NewGrpcWasmFlagLogger(mockStub, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	const maxBytes = 1024
+	if err := logger.SetMaxChunkBytes(maxBytes); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	request := &resolverv1.WriteFlagLogsRequest{
+		ClientResolveInfo: []*adminv1.ClientResolveInfo{{Client: "clients/test"}},
+		FlagResolveInfo:   []*adminv1.FlagResolveInfo{{Flag: "flags/my-flag"}},
+	}
+	for i := 0; i < 100; i++ {
+		request.FlagAssigned = append(request.FlagAssigned, &resolverevents.FlagAssigned{
+			ResolveId: fmt.Sprintf("resolve-%d", i),
+			Flags:     []*resolverevents.FlagAssigned_AppliedFlag{{Flag: "flags/my-flag", TargetingKey: fmt.Sprintf("user-%d", i)}},
+		})
+	}
+	// A single entry over the budget is sent on its own
+	request.FlagAssigned = append(request.FlagAssigned, &resolverevents.FlagAssigned{
+		ResolveId: strings.Repeat("x", 2*maxBytes),
+	})
+	logger.Write(request)
+	logger.Shutdown()
+
+	if len(received) < 2 {
+		t.Fatalf("Expected the request to be split, got %d requests", len(received))
+	}
+	total, withMetadata := 0, 0
+	for _, chunk := range received {
+		total += len(chunk.FlagAssigned)
+		if len(chunk.ClientResolveInfo) > 0 || len(chunk.FlagResolveInfo) > 0 {
+			withMetadata++
+		}
+		if size := proto.Size(chunk); size > maxBytes && len(chunk.FlagAssigned) != 1 {
+			t.Errorf("Expected chunk within %d bytes, got %d bytes with %d entries", maxBytes, size, len(chunk.FlagAssigned))
+		}
+	}
+	if total != 101 {
+		t.Errorf("Expected all 101 entries to be sent, got %d", total)
+	}
+	if withMetadata != 1 {
+		t.Errorf("Expected metadata on exactly one chunk, got %d", withMetadata)
+	}
+}
+
+func TestChunkFlagLogs_UnderBudget(t *testing.T) {
+	request := &resolverv1.WriteFlagLogsRequest{
+		FlagAssigned: []*resolverevents.FlagAssigned{{ResolveId: "resolve-1"}, {ResolveId: "resolve-2"}},
+	}
+	if chunks := chunkFlagLogs(request, 1024); len(chunks) != 1 || chunks[0] != request {
+		t.Errorf("Expected the request to be sent unsplit, got %d chunks", len(chunks))
+	}
+	if chunks := chunkFlagLogs(request, 0); len(chunks) != 1 || chunks[0] != request {
+		t.Errorf("Expected no splitting without a budget, got %d chunks", len(chunks))
+	}
+}
+
+func TestGrpcWasmFlagLogger_MaxChunkBytes_InvalidConfig(t *testing.T) {
+	logger := NewGrpcWasmFlagLogger(&mockInternalFlagLoggerServiceClient{}, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err := logger.SetMaxChunkBytes(-1); err == nil {
+		t.Error("Expected error for negative max chunk bytes")
+	}
+}
+
+func TestGrpcWasmFlagLogger_CallTimeout(t *testing.T) {
+	mockStub := &mockInternalFlagLoggerServiceClient{
+		writeFlagLogsFunc: func(ctx context.Context, req *resolverv1.WriteFlagLogsRequest) (*resolverv1.WriteFlagLogsResponse, error) {
+			// A hung server only returns once the call is cancelled
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	logger := NewGrpcWasmFlagLogger(mockStub, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err := logger.SetCallTimeout(0); err == nil {
+		t.Error("Expected error for a zero call timeout")
+	}
+	if err := logger.SetCallTimeout(10 * time.Millisecond); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	request := &resolverv1.WriteFlagLogsRequest{
+		FlagAssigned: []*resolverevents.FlagAssigned{{ResolveId: "resolve-1"}},
+	}
+	if err := logger.WriteSync(context.Background(), request); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the hung call to time out, got: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		logger.Write(request)
+		logger.Shutdown()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Shutdown to return once the hung async write timed out")
+	}
+}
+
+func TestGrpcWasmFlagLogger_WriteObserver(t *testing.T) {
+	writeErr := errors.New("unavailable")
+	var fail atomic.Bool
+	mockStub := &mockInternalFlagLoggerServiceClient{
+		writeFlagLogsFunc: func(ctx context.Context, req *resolverv1.WriteFlagLogsRequest) (*resolverv1.WriteFlagLogsResponse, error) {
+			if fail.Load() {
+				return nil, writeErr
+			}
+			return &resolverv1.WriteFlagLogsResponse{}, nil
+		},
+	}
+	logger := NewGrpcWasmFlagLogger(mockStub, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	var mu sync.Mutex
+	var sizes []int
+	var errs []error
+	logger.SetWriteObserver(func(bytes int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		sizes = append(sizes, bytes)
+		errs = append(errs, err)
+	})
+
+	request := &resolverv1.WriteFlagLogsRequest{
+		FlagAssigned: []*resolverevents.FlagAssigned{{ResolveId: "resolve-1"}},
+	}
+	logger.Write(request)
+	logger.Shutdown()
+	fail.Store(true)
+	if err := logger.WriteSync(context.Background(), request); err == nil {
+		t.Fatal("Expected the sync write to fail")
+	}
+
+	if len(sizes) != 2 {
+		t.Fatalf("Expected both writes to be observed, got %d", len(sizes))
+	}
+	if sizes[0] != proto.Size(request) {
+		t.Errorf("Expected the serialized size %d, got %d", proto.Size(request), sizes[0])
+	}
+	if errs[0] != nil || !errors.Is(errs[1], writeErr) {
+		t.Errorf("Expected a successful then a failed write, got %v", errs)
+	}
+}
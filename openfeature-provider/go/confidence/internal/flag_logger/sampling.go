@@ -0,0 +1,77 @@
+package flag_logger
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	resolverevents "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverevents"
+	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
+)
+
+// samplingMetadataKey carries the sampling rates applied to a request, formatted as
+// "flags/a=10,flags/b=100", so the backend can scale sampled exposure counts back up
+const samplingMetadataKey = "x-confidence-exposure-sampling"
+
+// exposureSampler keeps one in N FlagAssigned exposures for configured flags.
+// Sampling is deterministic per flag and targeting key, so a sampled unit has a
+// complete exposure history while unsampled units have none.
+type exposureSampler struct {
+	rates  map[string]int
+	header string
+}
+
+func newExposureSampler(rates map[string]int) (*exposureSampler, error) {
+	names := make([]string, 0, len(rates))
+	for flag, rate := range rates {
+		if !strings.HasPrefix(flag, "flags/") {
+			return nil, fmt.Errorf("invalid flag name %q, expected flags/<name>", flag)
+		}
+		if rate < 1 {
+			return nil, fmt.Errorf("invalid sampling rate %d for %s, must be at least 1", rate, flag)
+		}
+		names = append(names, flag)
+	}
+	sort.Strings(names)
+	entries := make([]string, len(names))
+	for i, flag := range names {
+		entries[i] = fmt.Sprintf("%s=%d", flag, rates[flag])
+	}
+	return &exposureSampler{rates: rates, header: strings.Join(entries, ",")}, nil
+}
+
+// sample drops unsampled exposures from the request. ClientResolveInfo and FlagResolveInfo
+// are aggregates and are left untouched. Returns true if any configured flag was present.
+func (s *exposureSampler) sample(request *resolverv1.WriteFlagLogsRequest) bool {
+	applied := false
+	kept := request.FlagAssigned[:0]
+	for _, assigned := range request.FlagAssigned {
+		flags := assigned.Flags[:0]
+		for _, flag := range assigned.Flags {
+			rate, ok := s.rates[flag.Flag]
+			if !ok || rate == 1 {
+				flags = append(flags, flag)
+				continue
+			}
+			applied = true
+			if s.keep(flag, rate) {
+				flags = append(flags, flag)
+			}
+		}
+		assigned.Flags = flags
+		if len(assigned.Flags) > 0 {
+			kept = append(kept, assigned)
+		}
+	}
+	request.FlagAssigned = kept
+	return applied
+}
+
+func (s *exposureSampler) keep(flag *resolverevents.FlagAssigned_AppliedFlag, rate int) bool {
+	h := fnv.New32a()
+	h.Write([]byte(flag.Flag))
+	h.Write([]byte{0})
+	h.Write([]byte(flag.TargetingKey))
+	return h.Sum32()%uint32(rate) == 0
+}
@@ -0,0 +1,167 @@
+package flag_logger
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
+	"google.golang.org/protobuf/proto"
+)
+
+const spoolFileExt = ".flaglogs"
+
+// spool stores flag log requests that failed to send as files in a directory, so they can
+// be sent again after a restart. Each file holds one request, prefixed with the sampling
+// metadata it was sent with.
+type spool struct {
+	dir      string
+	maxBytes int64
+	mu       sync.Mutex // guards size
+	size     int64
+	seq      atomic.Uint64
+}
+
+func newSpool(dir string, maxBytes int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	s := &spool{dir: dir, maxBytes: maxBytes}
+	files, err := s.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			s.size += info.Size()
+		}
+	}
+	return s, nil
+}
+
+// store writes the request to the spool. Returns an error if it would exceed the size cap.
+func (s *spool) store(request *resolverv1.WriteFlagLogsRequest, sampling string) error {
+	data, err := proto.Marshal(request)
+	if err != nil {
+		return err
+	}
+	entry := binary.AppendUvarint(nil, uint64(len(sampling)))
+	entry = append(entry, sampling...)
+	entry = append(entry, data...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size+int64(len(entry)) > s.maxBytes {
+		return fmt.Errorf("spool is full (%d of %d bytes used)", s.size, s.maxBytes)
+	}
+	// Names sort in write order so requests are replayed oldest first
+	name := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), s.seq.Add(1)%1e6, spoolFileExt)
+	path := filepath.Join(s.dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, entry, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	s.size += int64(len(entry))
+	return nil
+}
+
+// take reads and removes a spooled request
+func (s *spool) take(path string) (*resolverv1.WriteFlagLogsRequest, string, error) {
+	entry, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, "", err
+	}
+	s.mu.Lock()
+	s.size -= int64(len(entry))
+	s.mu.Unlock()
+
+	n, read := binary.Uvarint(entry)
+	if read <= 0 || uint64(len(entry)-read) < n {
+		return nil, "", errors.New("corrupt spool file")
+	}
+	sampling := string(entry[read : read+int(n)])
+	request := &resolverv1.WriteFlagLogsRequest{}
+	if err := proto.Unmarshal(entry[read+int(n):], request); err != nil {
+		return nil, "", fmt.Errorf("corrupt spool file: %w", err)
+	}
+	return request, sampling, nil
+}
+
+// files lists the spooled requests, oldest first
+func (s *spool) files() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), spoolFileExt) {
+			files = append(files, filepath.Join(s.dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// SetSpool makes writes that fail to send, including writes dropped by the circuit breaker,
+// be stored in dir, up to maxBytes in total, instead of being lost. Stored writes are sent
+// again by ReplaySpool, e.g. on the next start. Must be called before the logger is used.
+func (g *GrpcFlagLogger) SetSpool(dir string, maxBytes int64) error {
+	if maxBytes <= 0 {
+		return fmt.Errorf("max spool size must be positive, got %d", maxBytes)
+	}
+	s, err := newSpool(dir, maxBytes)
+	if err != nil {
+		return err
+	}
+	g.spool = s
+	return nil
+}
+
+// ReplaySpool sends the writes stored in the spool asynchronously, oldest first. Writes that
+// fail again are stored anew. Does nothing when no spool is configured.
+func (g *GrpcFlagLogger) ReplaySpool() {
+	if g.spool == nil {
+		return
+	}
+	files, err := g.spool.files()
+	if err != nil {
+		g.logger.Error("Failed to replay spooled flag logs", "error", err)
+		return
+	}
+	if len(files) > 0 {
+		g.logger.Info("Replaying spooled flag logs", "requests", len(files))
+	}
+	for _, file := range files {
+		request, sampling, err := g.spool.take(file)
+		if err != nil {
+			g.logger.Error("Failed to read spooled flag logs", "file", file, "error", err)
+			continue
+		}
+		g.sendAsync(request, sampling)
+	}
+}
+
+// spoolFailed stores a request that could not be sent, logging and returning false if it
+// has to be dropped
+func (g *GrpcFlagLogger) spoolFailed(request *resolverv1.WriteFlagLogsRequest, sampling string) bool {
+	if err := g.spool.store(request, sampling); err != nil {
+		g.logger.Error("Failed to spool flag logs, dropping them", "entries", len(request.FlagAssigned), "error", err)
+		return false
+	}
+	return true
+}
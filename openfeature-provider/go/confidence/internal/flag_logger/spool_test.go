@@ -0,0 +1,157 @@
+package flag_logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	resolverevents "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverevents"
+	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestGrpcWasmFlagLogger_Spool(t *testing.T) {
+	dir := t.TempDir()
+	failing := &mockInternalFlagLoggerServiceClient{
+		writeFlagLogsFunc: func(ctx context.Context, req *resolverv1.WriteFlagLogsRequest) (*resolverv1.WriteFlagLogsResponse, error) {
+			return nil, errors.New("backend unavailable")
+		},
+	}
+	logger := NewGrpcWasmFlagLogger(failing, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err := logger.SetSpool(dir, 1<<20); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := logger.SetExposureSampling(map[string]int{"flags/sampled": 2}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	sampled := &resolverevents.FlagAssigned{ResolveId: "resolve-1"}
+	for i := 0; i < 10; i++ {
+		sampled.Flags = append(sampled.Flags, &resolverevents.FlagAssigned_AppliedFlag{Flag: "flags/sampled", TargetingKey: fmt.Sprintf("user-%d", i)})
+	}
+	logger.Write(&resolverv1.WriteFlagLogsRequest{FlagAssigned: []*resolverevents.FlagAssigned{sampled}})
+	logger.Write(&resolverv1.WriteFlagLogsRequest{
+		FlagAssigned: []*resolverevents.FlagAssigned{{
+			ResolveId: "resolve-2",
+			Flags:     []*resolverevents.FlagAssigned_AppliedFlag{{Flag: "flags/other", TargetingKey: "user-1"}},
+		}},
+	})
+	logger.Shutdown()
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 spooled requests, got %d", len(entries))
+	}
+
+	var mu sync.Mutex
+	received := map[string][]string{}
+	working := &mockInternalFlagLoggerServiceClient{
+		writeFlagLogsFunc: func(ctx context.Context, req *resolverv1.WriteFlagLogsRequest) (*resolverv1.WriteFlagLogsResponse, error) {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			received[req.FlagAssigned[0].ResolveId] = md.Get(samplingMetadataKey)
+			return &resolverv1.WriteFlagLogsResponse{}, nil
+		},
+	}
+	restarted := NewGrpcWasmFlagLogger(working, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err := restarted.SetSpool(dir, 1<<20); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	restarted.ReplaySpool()
+	restarted.Shutdown()
+
+	if len(received) != 2 {
+		t.Fatalf("Expected both spooled requests to be replayed, got %v", received)
+	}
+	if sampling := received["resolve-1"]; len(sampling) != 1 || sampling[0] != "flags/sampled=2" {
+		t.Errorf("Expected the sampling metadata to be replayed, got %v", sampling)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected the spool to be empty after replay, got %d files", len(entries))
+	}
+}
+
+func TestGrpcWasmFlagLogger_SpoolSizeCap(t *testing.T) {
+	dir := t.TempDir()
+	failing := &mockInternalFlagLoggerServiceClient{
+		writeFlagLogsFunc: func(ctx context.Context, req *resolverv1.WriteFlagLogsRequest) (*resolverv1.WriteFlagLogsResponse, error) {
+			return nil, errors.New("backend unavailable")
+		},
+	}
+	logger := NewGrpcWasmFlagLogger(failing, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err := logger.SetSpool(dir, 40); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		logger.deliver(&resolverv1.WriteFlagLogsRequest{
+			FlagAssigned: []*resolverevents.FlagAssigned{{ResolveId: "resolve"}},
+		}, "")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	var total int64
+	for _, entry := range entries {
+		info, _ := entry.Info()
+		total += info.Size()
+	}
+	if len(entries) == 0 || total > 40 {
+		t.Errorf("Expected the spool to be filled up to its cap, got %d files with %d bytes", len(entries), total)
+	}
+}
+
+func TestGrpcWasmFlagLogger_SpoolWhileCircuitOpen(t *testing.T) {
+	failing := &mockInternalFlagLoggerServiceClient{
+		writeFlagLogsFunc: func(ctx context.Context, req *resolverv1.WriteFlagLogsRequest) (*resolverv1.WriteFlagLogsResponse, error) {
+			return nil, errors.New("backend unavailable")
+		},
+	}
+	request := &resolverv1.WriteFlagLogsRequest{
+		FlagAssigned: []*resolverevents.FlagAssigned{{ResolveId: "resolve"}},
+	}
+	newLogger := func(dir string, maxBytes int64) *GrpcFlagLogger {
+		logger := NewGrpcWasmFlagLogger(failing, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+		if err := logger.SetCircuitBreaker(1, time.Hour); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if err := logger.SetSpool(dir, maxBytes); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		return logger
+	}
+
+	dir := t.TempDir()
+	spooling := newLogger(dir, 1<<20)
+	for i := 0; i < 3; i++ {
+		spooling.deliver(request, "")
+	}
+	if spooling.BreakerState() != BreakerOpen {
+		t.Fatalf("Expected the breaker to open, got %v", spooling.BreakerState())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Errorf("Expected every request to be spooled, got %d files", len(entries))
+	}
+	if dropped := spooling.DroppedWrites(); dropped != 0 {
+		t.Errorf("Expected spooled writes not to count as dropped, got %d", dropped)
+	}
+
+	// A spool too small for any request drops them
+	full := newLogger(t.TempDir(), 1)
+	for i := 0; i < 3; i++ {
+		full.deliver(request, "")
+	}
+	if dropped := full.DroppedWrites(); dropped != 2 {
+		t.Errorf("Expected the writes rejected by the breaker to be dropped, got %d", dropped)
+	}
+}
+
+func TestGrpcWasmFlagLogger_Spool_InvalidConfig(t *testing.T) {
+	logger := NewGrpcWasmFlagLogger(&mockInternalFlagLoggerServiceClient{}, "test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err := logger.SetSpool(t.TempDir(), 0); err == nil {
+		t.Error("Expected error for a zero spool size")
+	}
+}
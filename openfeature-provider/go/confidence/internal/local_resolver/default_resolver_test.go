@@ -2,12 +2,17 @@ package local_resolver
 
 import (
 	"context"
+	"errors"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
 	messages "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto"
 	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/resolver"
+	"github.com/tetratelabs/wazero"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
@@ -29,7 +34,7 @@ func TestSwapWasmResolverApi_NewSwapWasmResolverApi(t *testing.T) {
 	defer defaultResolver.Close(ctx)
 
 	// Initialize with test state
-	if err := defaultResolver.SetResolverState(&messages.SetResolverStateRequest{
+	if err := defaultResolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
 		State:     initialState,
 		AccountId: accountId,
 	}); err != nil {
@@ -53,7 +58,7 @@ func TestSwapWasmResolverApi_WithRealState(t *testing.T) {
 	defer defaultResolver.Close(ctx)
 
 	// Initialize with test state
-	if err := defaultResolver.SetResolverState(&messages.SetResolverStateRequest{
+	if err := defaultResolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
 		State:     testState,
 		AccountId: testAcctID,
 	}); err != nil {
@@ -67,7 +72,7 @@ func TestSwapWasmResolverApi_WithRealState(t *testing.T) {
 		false, // notProcessSticky
 	)
 
-	stickyResponse, err := defaultResolver.ResolveWithSticky(request)
+	stickyResponse, err := defaultResolver.ResolveWithSticky(ctx, request)
 	if err != nil {
 		t.Fatalf("Unexpected error resolving tutorial-feature flag: %v", err)
 	}
@@ -146,7 +151,7 @@ func TestSwapWasmResolverApi_UpdateStateAndFlushLogs(t *testing.T) {
 	defer defaultResolver.Close(ctx)
 
 	// Initialize with test state
-	if err := defaultResolver.SetResolverState(&messages.SetResolverStateRequest{
+	if err := defaultResolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
 		State:     initialState,
 		AccountId: accountId,
 	}); err != nil {
@@ -155,7 +160,7 @@ func TestSwapWasmResolverApi_UpdateStateAndFlushLogs(t *testing.T) {
 
 	// Update with new state - the key test is that UpdateStateAndFlushLogs succeeds
 	newState := tu.LoadTestResolverState(t)
-	err := defaultResolver.SetResolverState(&messages.SetResolverStateRequest{
+	err := defaultResolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
 		State:     newState,
 		AccountId: accountId,
 	})
@@ -171,7 +176,7 @@ func TestSwapWasmResolverApi_UpdateStateAndFlushLogs(t *testing.T) {
 		false, // notProcessSticky
 	)
 
-	stickyResponse, err := defaultResolver.ResolveWithSticky(request)
+	stickyResponse, err := defaultResolver.ResolveWithSticky(ctx, request)
 	if err != nil {
 		t.Fatalf("Resolve failed after update: %v", err)
 	}
@@ -204,7 +209,7 @@ func TestSwapWasmResolverApi_MultipleUpdates(t *testing.T) {
 	defer defaultResolver.Close(ctx)
 
 	// Initialize with test state
-	if err := defaultResolver.SetResolverState(&messages.SetResolverStateRequest{
+	if err := defaultResolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
 		State:     initialState,
 		AccountId: accountId,
 	}); err != nil {
@@ -214,7 +219,7 @@ func TestSwapWasmResolverApi_MultipleUpdates(t *testing.T) {
 	// Perform multiple state updates to verify the defaultResolver mechanism works correctly
 	for i := 0; i < 3; i++ {
 		newState := tu.LoadTestResolverState(t)
-		err := defaultResolver.SetResolverState(&messages.SetResolverStateRequest{
+		err := defaultResolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
 			State:     newState,
 			AccountId: accountId,
 		})
@@ -230,7 +235,7 @@ func TestSwapWasmResolverApi_MultipleUpdates(t *testing.T) {
 			false, // notProcessSticky
 		)
 
-		stickyResponse, resolveErr := defaultResolver.ResolveWithSticky(request)
+		stickyResponse, resolveErr := defaultResolver.ResolveWithSticky(ctx, request)
 		if resolveErr != nil {
 			t.Fatalf("Update %d: Resolve failed: %v", i, resolveErr)
 		}
@@ -252,6 +257,7 @@ func TestSwapWasmResolverApi_MultipleUpdates(t *testing.T) {
 }
 
 func TestSwapWasmResolverApi_Close(t *testing.T) {
+	ctx := context.Background()
 
 	initialState := tu.CreateMinimalResolverState()
 	accountId := "test-account"
@@ -259,31 +265,57 @@ func TestSwapWasmResolverApi_Close(t *testing.T) {
 	defaultResolver := resolverFactory.New()
 
 	// Initialize with test state
-	if err := defaultResolver.SetResolverState(&messages.SetResolverStateRequest{
+	if err := defaultResolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
 		State:     initialState,
 		AccountId: accountId,
 	}); err != nil {
 		t.Fatalf("Failed to initialize defaultResolver with state: %v", err)
 	}
 
-	// Close should not panic
-	// defaultResolver.Close(ctx)
+	// Closing twice, as with a deferred Close after an explicit one, must not panic
+	if err := defaultResolver.Close(ctx); err != nil {
+		t.Fatalf("Expected no error on first close, got %v", err)
+	}
+	if err := defaultResolver.Close(ctx); err != nil {
+		t.Errorf("Expected second close to be a no-op, got %v", err)
+	}
+}
+
+func TestWasmResolver_CloseTwice(t *testing.T) {
+	ctx := context.Background()
+	factory := NewWasmResolverFactory(NoOpLogSink)
+	defer factory.Close(ctx)
 
-	// Note: Closing again may cause issues with WASM module, so we don't test double-close
+	wasmResolver := factory.New()
+	if err := wasmResolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
+		State:     tu.CreateMinimalResolverState(),
+		AccountId: "test-account",
+	}); err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+	if err := wasmResolver.Close(ctx); err != nil {
+		t.Fatalf("Expected no error on first close, got %v", err)
+	}
+	if err := wasmResolver.Close(ctx); err != nil {
+		t.Errorf("Expected second close to be a no-op, got %v", err)
+	}
+	if err := wasmResolver.FlushAllLogs(context.Background()); !errors.Is(err, ErrInstanceClosed) {
+		t.Errorf("Expected ErrInstanceClosed after close, got %v", err)
+	}
 }
 
-// func TestErrInstanceClosed(t *testing.T) {
-// 	err := ErrInstanceClosed
-// 	if err.Error() != "WASM instance is closed or being replaced" {
-// 		t.Errorf("Unexpected error message: %s", err.Error())
-// 	}
+func TestErrInstanceClosed(t *testing.T) {
+	err := ErrInstanceClosed
+	if err.Error() != "WASM instance is closed or being replaced" {
+		t.Errorf("Unexpected error message: %s", err.Error())
+	}
 
-// 	// Test that errors.Is works with it
-// 	testErr := ErrInstanceClosed
-// 	if !errors.Is(testErr, ErrInstanceClosed) {
-// 		t.Error("Expected errors.Is to work with ErrInstanceClosed")
-// 	}
-// }
+	// Test that errors.Is works with it
+	testErr := ErrInstanceClosed
+	if !errors.Is(testErr, ErrInstanceClosed) {
+		t.Error("Expected errors.Is to work with ErrInstanceClosed")
+	}
+}
 
 // State from data sample, flag without sticky rules
 func TestSwapWasmResolverApi_ResolveFlagWithNoStickyRules(t *testing.T) {
@@ -296,7 +328,7 @@ func TestSwapWasmResolverApi_ResolveFlagWithNoStickyRules(t *testing.T) {
 	defer defaultResolver.Close(ctx)
 
 	// Initialize with test state
-	if err := defaultResolver.SetResolverState(&messages.SetResolverStateRequest{
+	if err := defaultResolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
 		State:     testState,
 		AccountId: testAcctID,
 	}); err != nil {
@@ -310,7 +342,7 @@ func TestSwapWasmResolverApi_ResolveFlagWithNoStickyRules(t *testing.T) {
 		false, // notProcessSticky
 	)
 
-	response, err := defaultResolver.ResolveWithSticky(stickyRequest)
+	response, err := defaultResolver.ResolveWithSticky(ctx, stickyRequest)
 	if err != nil {
 		t.Fatalf("Unexpected error resolving tutorial-feature flag with sticky: %v", err)
 	}
@@ -380,7 +412,7 @@ func TestSwapWasmResolverApi_ResolveFlagWithStickyRules_MissingMaterializations(
 	defer defaultResolver.Close(ctx)
 
 	// Initialize with test state
-	if err := defaultResolver.SetResolverState(&messages.SetResolverStateRequest{
+	if err := defaultResolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
 		State:     stickyState,
 		AccountId: accountId,
 	}); err != nil {
@@ -403,7 +435,7 @@ func TestSwapWasmResolverApi_ResolveFlagWithStickyRules_MissingMaterializations(
 		false, // notProcessSticky
 	)
 
-	response, err := defaultResolver.ResolveWithSticky(stickyRequest)
+	response, err := defaultResolver.ResolveWithSticky(ctx, stickyRequest)
 	if err != nil {
 		t.Fatalf("Unexpected error from ResolveWithSticky: %v", err)
 	}
@@ -422,3 +454,294 @@ func TestSwapWasmResolverApi_ResolveFlagWithStickyRules_MissingMaterializations(
 		t.Fatal("Expected non-nil MissingMaterializations")
 	}
 }
+
+func TestCompileWasm(t *testing.T) {
+	ctx := context.Background()
+
+	compiled, err := CompileWasm(ctx, wasmBytes)
+	if err != nil {
+		t.Fatalf("Failed to compile embedded WASM: %v", err)
+	}
+	factory := NewWasmResolverFactoryFromCompiled(compiled, NoOpLogSink)
+	defer factory.Close(ctx)
+
+	resolver := factory.New()
+	if err := resolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
+		State:     tu.CreateMinimalResolverState(),
+		AccountId: "test-account",
+	}); err != nil {
+		t.Fatalf("Failed to set state on custom compiled resolver: %v", err)
+	}
+}
+
+func TestCompileWasm_InvalidBytes(t *testing.T) {
+	if _, err := CompileWasm(context.Background(), []byte{0x00, 0x61, 0x73}); err == nil {
+		t.Fatal("Expected error compiling invalid WASM bytes")
+	}
+}
+
+func TestCompileWasm_MissingExports(t *testing.T) {
+	// A valid but empty module: magic number and version only
+	emptyModule := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	_, err := CompileWasm(context.Background(), emptyModule)
+	if err == nil {
+		t.Fatal("Expected error compiling a module without the required exports")
+	}
+	if !strings.Contains(err.Error(), "missing required exports: wasm_msg_alloc") {
+		t.Errorf("Expected missing exports error, got: %v", err)
+	}
+}
+
+func TestNewSharedCompiledWasm(t *testing.T) {
+	ctx := context.Background()
+	runtime := wazero.NewRuntime(ctx)
+	defer runtime.Close(ctx)
+	if err := RegisterHostFunctions(ctx, runtime, nil); err != nil {
+		t.Fatalf("Failed to register host functions: %v", err)
+	}
+	module, err := CompileModule(ctx, runtime, nil)
+	if err != nil {
+		t.Fatalf("Failed to compile embedded WASM: %v", err)
+	}
+	compiled, err := NewSharedCompiledWasm(runtime, module)
+	if err != nil {
+		t.Fatalf("Failed to wrap shared module: %v", err)
+	}
+
+	first := NewWasmResolverFactoryFromCompiled(compiled, NoOpLogSink)
+	second := NewWasmResolverFactoryFromCompiled(compiled, NoOpLogSink)
+	firstResolver := first.New()
+	secondResolver := second.New()
+	defer secondResolver.Close(ctx)
+
+	// Closing one factory must leave the shared runtime usable for the other
+	if err := firstResolver.Close(ctx); err != nil {
+		t.Fatalf("Failed to close resolver: %v", err)
+	}
+	if err := first.Close(ctx); err != nil {
+		t.Fatalf("Failed to close factory: %v", err)
+	}
+	if err := secondResolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
+		State:     tu.CreateMinimalResolverState(),
+		AccountId: "test-account",
+	}); err != nil {
+		t.Fatalf("Failed to set state after closing the other factory: %v", err)
+	}
+	if another := second.New(); another == nil {
+		t.Fatal("Expected a new instance from the shared runtime")
+	} else {
+		another.Close(ctx)
+	}
+
+	if err := RegisterHostFunctions(ctx, runtime, nil); err == nil {
+		t.Error("Expected error registering host functions twice on one runtime")
+	}
+}
+
+func TestWasmResolverFactory_ManyInstances(t *testing.T) {
+	ctx := context.Background()
+	compiled, err := CompileWasm(ctx, wasmBytes)
+	if err != nil {
+		t.Fatalf("Failed to compile embedded WASM: %v", err)
+	}
+	factory := NewWasmResolverFactoryFromCompiled(compiled, NoOpLogSink)
+	defer factory.Close(ctx)
+
+	const instanceCount = 32
+	names := make(map[string]bool, instanceCount)
+	for i := 0; i < instanceCount; i++ {
+		instance := factory.New().(*WasmResolver)
+		defer instance.Close(ctx)
+		name := instance.instance.Name()
+		if name == "" || names[name] {
+			t.Fatalf("Expected unique non-empty instance name, got %q", name)
+		}
+		names[name] = true
+	}
+
+	for name := range names {
+		if factory.(*WasmResolverFactory).runtime.Module(name) == nil {
+			t.Errorf("Expected instance %s to be registered on the runtime", name)
+		}
+	}
+}
+
+func TestSwapWasmResolverApi_MemoryStats(t *testing.T) {
+	ctx := context.Background()
+
+	defaultResolver := resolverFactory.New()
+	defer defaultResolver.Close(ctx)
+
+	reporter, ok := defaultResolver.(MemoryStatsReporter)
+	if !ok {
+		t.Fatal("Expected the default resolver to report memory stats")
+	}
+	before := reporter.MemoryStats()
+	if before.Bytes == 0 || uint64(before.Pages)*wasmPageSize != before.Bytes {
+		t.Fatalf("Expected memory size in whole pages, got %+v", before)
+	}
+
+	if err := defaultResolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
+		State:     tu.LoadTestResolverState(t),
+		AccountId: tu.LoadTestAccountID(t),
+	}); err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+	after := reporter.MemoryStats()
+	if after.Bytes < before.Bytes || after.PeakBytes < after.Bytes {
+		t.Errorf("Expected memory to not shrink and the peak to cover it, before %+v after %+v", before, after)
+	}
+}
+
+func TestSwapWasmResolverApi_ResolveWithCancelledContext(t *testing.T) {
+	defaultResolver := resolverFactory.New()
+	defer defaultResolver.Close(context.Background())
+
+	if err := defaultResolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
+		State:     tu.CreateMinimalResolverState(),
+		AccountId: "test-account",
+	}); err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := defaultResolver.ResolveWithSticky(ctx, &resolver.ResolveWithStickyRequest{
+		ResolveRequest: &resolver.ResolveFlagsRequest{
+			ClientSecret:      "test-secret",
+			EvaluationContext: &structpb.Struct{},
+		},
+		MaterializationsPerUnit: make(map[string]*resolver.MaterializationMap),
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+// doneInGuest is a context that is done, but reports so only after the first check, so a
+// call gets past the check before entering the guest and runs with a done context
+type doneInGuest struct {
+	context.Context
+	done    chan struct{}
+	checked atomic.Bool
+}
+
+func newDoneInGuest() *doneInGuest {
+	done := make(chan struct{})
+	close(done)
+	return &doneInGuest{Context: context.Background(), done: done}
+}
+
+func (c *doneInGuest) Done() <-chan struct{} { return c.done }
+
+func (c *doneInGuest) Err() error {
+	if c.checked.Swap(true) {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestWasmResolver_CompletesRunningCall(t *testing.T) {
+	ctx := context.Background()
+	// Even a runtime that closes modules on a done context must not interrupt a running call
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	defer runtime.Close(ctx)
+	if err := RegisterHostFunctions(ctx, runtime, nil); err != nil {
+		t.Fatalf("Failed to register host functions: %v", err)
+	}
+	module, err := CompileModule(ctx, runtime, nil)
+	if err != nil {
+		t.Fatalf("Failed to compile WASM: %v", err)
+	}
+	compiled, err := NewSharedCompiledWasm(runtime, module)
+	if err != nil {
+		t.Fatalf("Failed to share compiled WASM: %v", err)
+	}
+	factory := NewWasmResolverFactoryFromCompiled(compiled, NoOpLogSink)
+	defer factory.Close(ctx)
+	wasmResolver := factory.New()
+
+	if err := wasmResolver.SetResolverState(newDoneInGuest(), &messages.SetResolverStateRequest{
+		State:     tu.LoadTestResolverState(t),
+		AccountId: tu.LoadTestAccountID(t),
+	}); err != nil {
+		t.Fatalf("Expected the running call to complete, got %v", err)
+	}
+	if _, err := wasmResolver.ResolveWithSticky(ctx, tu.CreateResolveWithStickyRequest(
+		tu.CreateTutorialFeatureRequest(), nil, true, false,
+	)); err != nil {
+		t.Errorf("Expected the instance to stay usable, got %v", err)
+	}
+}
+
+// panickingResolver panics on every resolve
+type panickingResolver struct {
+	fakeResolver
+}
+
+func (p *panickingResolver) ResolveWithSticky(context.Context, *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+	panic("resolve failed")
+}
+
+// panickingFactory creates a panickingResolver, then panics on every later New
+type panickingFactory struct {
+	news atomic.Int32
+}
+
+func (f *panickingFactory) New() LocalResolver {
+	if f.news.Add(1) > 1 {
+		panic("new failed")
+	}
+	return &panickingResolver{}
+}
+
+func (f *panickingFactory) Close(context.Context) error { return nil }
+
+func TestRecoveringResolver_RecoversRecreationPanic(t *testing.T) {
+	ctx := context.Background()
+	factory := &panickingFactory{}
+	recovering := NewRecoveringResolverFactory(factory).New().(*RecoveringResolver)
+
+	if _, err := recovering.ResolveWithSticky(ctx, &resolver.ResolveWithStickyRequest{}); err == nil {
+		t.Fatal("Expected the panic to be returned as an error")
+	}
+	// The failed recreation is retried on the next panic once the first attempt is done
+	deadline := time.Now().Add(5 * time.Second)
+	for factory.news.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected recreation to be retried, got %d attempts", factory.news.Load()-1)
+		}
+		if !recovering.broken.Load() {
+			_, _ = recovering.ResolveWithSticky(ctx, &resolver.ResolveWithStickyRequest{})
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewLocalResolverWithOptions_Instances(t *testing.T) {
+	ctx := context.Background()
+	localResolver := NewLocalResolverWithOptions(Options{Instances: 3})(ctx, NoOpLogSink)
+	defer localResolver.Close(ctx)
+
+	if slots := len(*localResolver.(*localResolverImpl).slots.Load()); slots != 3 {
+		t.Fatalf("Expected 3 instances, got %d", slots)
+	}
+	if err := localResolver.SetResolverState(context.Background(), &messages.SetResolverStateRequest{
+		State:     tu.CreateMinimalResolverState(),
+		AccountId: "test-account",
+	}); err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+	// Round-robin reaches every instance, each must have been given the state
+	for i := 0; i < 6; i++ {
+		if _, err := localResolver.ResolveWithSticky(ctx, &resolver.ResolveWithStickyRequest{
+			ResolveRequest: &resolver.ResolveFlagsRequest{
+				ClientSecret:      "test-secret",
+				EvaluationContext: &structpb.Struct{},
+			},
+			MaterializationsPerUnit: make(map[string]*resolver.MaterializationMap),
+		}); err != nil {
+			t.Fatalf("Resolve %d failed: %v", i, err)
+		}
+	}
+}
@@ -16,11 +16,13 @@ type LocalResolverFactory interface {
 	Close(context.Context) error
 }
 
+// LocalResolver evaluates flags against the loaded resolver state. A context that is done
+// before a call enters the guest aborts it; a call already running in the guest completes.
 type LocalResolver interface {
-	SetResolverState(*messages.SetResolverStateRequest) error
-	ResolveWithSticky(*resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error)
-	FlushAllLogs() error
-	FlushAssignLogs() error
+	SetResolverState(context.Context, *messages.SetResolverStateRequest) error
+	ResolveWithSticky(context.Context, *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error)
+	FlushAllLogs(context.Context) error
+	FlushAssignLogs(context.Context) error
 	Close(context.Context) error
 }
 
@@ -32,15 +34,66 @@ func DefaultResolverFactory(logSink LogSink) LocalResolverFactory {
 }
 
 type localResolverImpl struct {
-	PooledResolver
+	*PooledResolver
 	factory LocalResolverFactory
 }
 
 func NewLocalResolver(ctx context.Context, logSink LogSink) LocalResolver {
-	factory := NewWasmResolverFactory(logSink)
+	return newLocalResolver(NewWasmResolverFactory(logSink), 0)
+}
+
+// NewLocalResolverWithClock returns a resolver supplier using the embedded guest with the
+// current time read from clock.
+func NewLocalResolverWithClock(clock Clock) func(context.Context, LogSink) LocalResolver {
+	return NewLocalResolverWithOptions(Options{Clock: clock})
+}
+
+// Options configures the resolvers returned by NewLocalResolverWithOptions
+type Options struct {
+	// Compiled is a custom compiled guest, the embedded guest is used when nil. The supplier
+	// takes ownership of it and must then be called at most once, unless it is shared, see
+	// NewSharedCompiledWasm.
+	Compiled *CompiledWasm
+	// Wasm is a custom guest binary used instead of the embedded guest. Like the embedded
+	// guest it is compiled on every call of the supplier. Ignored with Compiled.
+	Wasm []byte
+	// Clock supplies the current time to the guest, the system clock when nil. Ignored
+	// with Compiled, which was compiled with its own clock.
+	Clock Clock
+	// Instances is the number of guest instances resolves are spread over. All instances
+	// share the compiled module and are loaded with the same state. Zero uses GOMAXPROCS+1.
+	Instances int
+}
+
+// NewLocalResolverWithOptions returns a resolver supplier configured by opts
+func NewLocalResolverWithOptions(opts Options) func(context.Context, LogSink) LocalResolver {
+	return func(ctx context.Context, logSink LogSink) LocalResolver {
+		compiled := opts.Compiled
+		if compiled == nil {
+			var err error
+			wasm := opts.Wasm
+			if wasm == nil {
+				wasm = wasmBytes
+			}
+			compiled, err = CompileWasmWithClock(ctx, wasm, opts.Clock)
+			if err != nil {
+				panic(err)
+			}
+		}
+		return newLocalResolver(NewWasmResolverFactoryFromCompiled(compiled, logSink), opts.Instances)
+	}
+}
+
+// newLocalResolver pools instances from factory, GOMAXPROCS+1 of them when instances is zero
+func newLocalResolver(factory LocalResolverFactory, instances int) LocalResolver {
 	factory = NewRecoveringResolverFactory(factory)
+	size := runtime.GOMAXPROCS(0)
+	if instances > 0 {
+		// The pool holds one instance more than its size
+		size = instances - 1
+	}
 	return &localResolverImpl{
-		PooledResolver: *NewPooledResolver(runtime.GOMAXPROCS(0), factory.New),
+		PooledResolver: NewPooledResolver(size, factory.New),
 		factory:        factory,
 	}
 }
@@ -1,6 +1,7 @@
 package local_resolver
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -31,78 +32,184 @@ func (f *PooledResolverFactory) Close(ctx context.Context) error {
 	return f.inner.Close(ctx)
 }
 
+// slot is one pooled instance. Resolves hold rw for reading, maintenance for writing.
 type slot struct {
-	lr LocalResolver
-	rw *sync.RWMutex
+	lr      LocalResolver
+	rw      sync.RWMutex
+	retired bool // guarded by rw, set once the slot was replaced by a state swap
 }
 
 type PooledResolver struct {
 	supplier LocalResolverSupplier
-	slots    []slot
+	size     int
+	slots    atomic.Pointer[[]*slot]
 	rr       atomic.Uint64
-	mmu      sync.Mutex
+	mmu      sync.Mutex                     // serializes maintenance and state swaps
+	state    *proto.SetResolverStateRequest // last state loaded, guarded by mmu
+	closed   atomic.Bool
 }
 
-var _ LocalResolver = (*PooledResolver)(nil)
+var (
+	_ LocalResolver       = (*PooledResolver)(nil)
+	_ MemoryStatsReporter = (*PooledResolver)(nil)
+)
 
 func NewPooledResolver(size int, supplier LocalResolverSupplier) *PooledResolver {
-	slots := make([]slot, size+1)
+	s := &PooledResolver{
+		supplier: supplier,
+		size:     size + 1,
+	}
+	slots := s.newSlots()
+	s.slots.Store(&slots)
+	return s
+}
+
+func (s *PooledResolver) newSlots() []*slot {
+	slots := make([]*slot, s.size)
 	for i := range slots {
-		slots[i] = slot{
-			lr: supplier(),
-			rw: &sync.RWMutex{},
+		slots[i] = &slot{lr: s.supplier()}
+	}
+	return slots
+}
+
+// ResolveWithSticky implements LocalResolver. The resolve goes to the next slot round-robin
+// that isn't under maintenance, or waits for the next slot if all of them are.
+func (s *PooledResolver) ResolveWithSticky(ctx context.Context, request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		response, err := s.resolveOn(ctx, s.acquire(), request)
+		if err != errSlotRetired {
+			return response, err
 		}
+		if s.closed.Load() {
+			return nil, ErrInstanceClosed
+		}
+		// Replaced by a state swap while waiting, retry on the current slots
 	}
-	return &PooledResolver{
-		supplier: supplier,
-		slots:    slots,
+}
+
+// errSlotRetired is returned by resolveOn for a slot replaced by a state swap or closed
+var errSlotRetired = errors.New("slot retired")
+
+// resolveOn resolves on a slot acquired for reading and releases it
+func (s *PooledResolver) resolveOn(ctx context.Context, slot *slot, request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+	defer slot.rw.RUnlock()
+	if slot.retired {
+		return nil, errSlotRetired
 	}
+	return slot.lr.ResolveWithSticky(ctx, request)
 }
 
-// ResolveWithSticky implements LocalResolver.
-func (s *PooledResolver) ResolveWithSticky(request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
-	n := uint64(len(s.slots))
+// acquire read locks a slot of the current pool, blocking only if every slot is busy
+func (s *PooledResolver) acquire() *slot {
+	slots := *s.slots.Load()
+	n := uint64(len(slots))
 	idx := s.rr.Add(1)
-	for !s.slots[idx%n].rw.TryRLock() {
-		idx = s.rr.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if slot := slots[(idx+i)%n]; slot.rw.TryRLock() {
+			return slot
+		}
 	}
-	slot := &s.slots[idx%n]
-	defer slot.rw.RUnlock()
-	return slot.lr.ResolveWithSticky(request)
+	slot := slots[idx%n]
+	slot.rw.RLock()
+	return slot
 }
 
-// SetResolverState implements LocalResolver.
-func (s *PooledResolver) SetResolverState(request *proto.SetResolverStateRequest) error {
-	return s.maintenance(func(lr LocalResolver) error {
-		return lr.SetResolverState(request)
-	})
+// SetResolverState implements LocalResolver. The state is loaded into fresh instances
+// while the current ones keep serving, then all slots are swapped at once, so resolves
+// never see a mix of old and new state. The replaced instances are closed once their
+// in-flight resolves are done, flushing their pending logs. Loading the state that is
+// already loaded is a no-op.
+func (s *PooledResolver) SetResolverState(ctx context.Context, request *proto.SetResolverStateRequest) error {
+	s.mmu.Lock()
+	defer s.mmu.Unlock()
+	if s.state == nil {
+		// Nothing is loaded yet, so there is nothing to keep serving while loading
+		return s.loadInPlace(ctx, request)
+	}
+	if s.state.AccountId == request.AccountId && bytes.Equal(s.state.State, request.State) {
+		return nil
+	}
+
+	fresh := s.newSlots()
+	for i, slot := range fresh {
+		if err := slot.lr.SetResolverState(ctx, request); err != nil {
+			for _, slot := range fresh {
+				_ = slot.lr.Close(context.WithoutCancel(ctx))
+			}
+			return fmt.Errorf("slot %d: %w", i, err)
+		}
+	}
+	old := *s.slots.Swap(&fresh)
+	s.state = request
+
+	errs := []error{}
+	for i, slot := range old {
+		slot.rw.Lock()
+		slot.retired = true
+		// Interrupting the close would drop the instance's pending logs
+		if err := slot.lr.Close(context.WithoutCancel(ctx)); err != nil {
+			errs = append(errs, fmt.Errorf("closing replaced slot %d: %w", i, err))
+		}
+		slot.rw.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// loadInPlace loads the first state into the current slots. Must be called with mmu held.
+func (s *PooledResolver) loadInPlace(ctx context.Context, request *proto.SetResolverStateRequest) error {
+	for i, slot := range *s.slots.Load() {
+		slot.rw.Lock()
+		err := slot.lr.SetResolverState(ctx, request)
+		slot.rw.Unlock()
+		if err != nil {
+			return fmt.Errorf("slot %d: %w", i, err)
+		}
+	}
+	s.state = request
+	return nil
 }
 
 // FlushAllLogs implements LocalResolver.
-func (s *PooledResolver) FlushAllLogs() error {
+func (s *PooledResolver) FlushAllLogs(ctx context.Context) error {
 	return s.maintenance(func(lr LocalResolver) error {
-		return lr.FlushAllLogs()
+		return lr.FlushAllLogs(ctx)
 	})
 }
 
 // FlushAssignLogs implements LocalResolver.
-func (s *PooledResolver) FlushAssignLogs() error {
+func (s *PooledResolver) FlushAssignLogs(ctx context.Context) error {
 	return s.maintenance(func(lr LocalResolver) error {
-		return lr.FlushAssignLogs()
+		return lr.FlushAssignLogs(ctx)
 	})
 }
 
+// Close implements LocalResolver. Slots are retired as they are closed, so resolves waiting
+// for them fail with ErrInstanceClosed instead of reaching a closed instance.
 func (s *PooledResolver) Close(ctx context.Context) error {
-	return s.maintenance(func(lr LocalResolver) error {
-		return lr.Close(ctx)
-	})
+	s.closed.Store(true)
+	errs := []error{}
+	s.mmu.Lock()
+	defer s.mmu.Unlock()
+	for i, slot := range *s.slots.Load() {
+		slot.rw.Lock()
+		slot.retired = true
+		if err := slot.lr.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("slot %d: %w", i, err))
+		}
+		slot.rw.Unlock()
+	}
+	return errors.Join(errs...)
 }
 
+// maintenance runs fn on every slot, locking one slot at a time
 func (s *PooledResolver) maintenance(fn func(LocalResolver) error) error {
 	errs := []error{}
 	s.mmu.Lock()
 	defer s.mmu.Unlock()
-	for i, slot := range s.slots {
+	for i, slot := range *s.slots.Load() {
 		func() {
 			slot.rw.Lock()
 			defer slot.rw.Unlock()
@@ -116,3 +223,17 @@ func (s *PooledResolver) maintenance(fn func(LocalResolver) error) error {
 	}
 	return nil
 }
+
+// MemoryStats sums the memory stats of the slots that report them
+func (s *PooledResolver) MemoryStats() MemoryStats {
+	var total MemoryStats
+	for _, slot := range *s.slots.Load() {
+		if reporter, ok := slot.lr.(MemoryStatsReporter); ok {
+			stats := reporter.MemoryStats()
+			total.Pages += stats.Pages
+			total.Bytes += stats.Bytes
+			total.PeakBytes += stats.PeakBytes
+		}
+	}
+	return total
+}
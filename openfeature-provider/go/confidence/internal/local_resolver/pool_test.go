@@ -0,0 +1,197 @@
+package local_resolver
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	messages "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/resolver"
+)
+
+// fakeResolver resolves with the state it was loaded with as the resolve id
+type fakeResolver struct {
+	mu       sync.Mutex
+	state    string
+	sets     int
+	resolves int
+	closed   bool
+	setDelay time.Duration
+}
+
+func (f *fakeResolver) SetResolverState(_ context.Context, request *messages.SetResolverStateRequest) error {
+	time.Sleep(f.setDelay)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = string(request.State)
+	f.sets++
+	return nil
+}
+
+func (f *fakeResolver) ResolveWithSticky(context.Context, *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, ErrInstanceClosed
+	}
+	f.resolves++
+	return &resolver.ResolveWithStickyResponse{
+		ResolveResult: &resolver.ResolveWithStickyResponse_Success_{
+			Success: &resolver.ResolveWithStickyResponse_Success{
+				Response: &resolver.ResolveFlagsResponse{ResolveId: f.state},
+			},
+		},
+	}, nil
+}
+
+func (f *fakeResolver) FlushAllLogs(context.Context) error    { return nil }
+func (f *fakeResolver) FlushAssignLogs(context.Context) error { return nil }
+
+func (f *fakeResolver) Close(context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+// fakeSupplier returns a supplier of fake resolvers and the resolvers created so far
+func fakeSupplier(setDelay time.Duration) (LocalResolverSupplier, func() []*fakeResolver) {
+	var mu sync.Mutex
+	var created []*fakeResolver
+	supplier := func() LocalResolver {
+		mu.Lock()
+		defer mu.Unlock()
+		r := &fakeResolver{setDelay: setDelay}
+		created = append(created, r)
+		return r
+	}
+	return supplier, func() []*fakeResolver {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*fakeResolver(nil), created...)
+	}
+}
+
+func resolvedState(t *testing.T, pool *PooledResolver) string {
+	t.Helper()
+	response, err := pool.ResolveWithSticky(context.Background(), &resolver.ResolveWithStickyRequest{})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	return response.GetSuccess().GetResponse().GetResolveId()
+}
+
+func TestPooledResolver_SetResolverState(t *testing.T) {
+	ctx := context.Background()
+	supplier, created := fakeSupplier(0)
+	pool := NewPooledResolver(1, supplier)
+
+	if err := pool.SetResolverState(ctx, &messages.SetResolverStateRequest{State: []byte("v1"), AccountId: "account"}); err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+	if n := len(created()); n != 2 {
+		t.Errorf("Expected the first state to be loaded into the initial instances, got %d instances", n)
+	}
+
+	if err := pool.SetResolverState(ctx, &messages.SetResolverStateRequest{State: []byte("v2"), AccountId: "account"}); err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+	instances := created()
+	if len(instances) != 4 {
+		t.Fatalf("Expected a new state to be loaded into fresh instances, got %d instances", len(instances))
+	}
+	for i, instance := range instances {
+		if replaced := i < 2; instance.closed != replaced {
+			t.Errorf("Expected instance %d closed to be %v, got %v", i, replaced, instance.closed)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if state := resolvedState(t, pool); state != "v2" {
+			t.Errorf("Expected resolves to see the new state, got %q", state)
+		}
+	}
+
+	if err := pool.SetResolverState(ctx, &messages.SetResolverStateRequest{State: []byte("v2"), AccountId: "account"}); err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+	instances = created()
+	if len(instances) != 4 || instances[2].sets != 1 || instances[3].sets != 1 {
+		t.Error("Expected an unchanged state not to be loaded again")
+	}
+}
+
+func TestPooledResolver_ResolvesDuringStateSwap(t *testing.T) {
+	ctx := context.Background()
+	supplier, _ := fakeSupplier(0)
+	pool := NewPooledResolver(1, supplier)
+	if err := pool.SetResolverState(ctx, &messages.SetResolverStateRequest{State: []byte("v1")}); err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+
+	// Loading the next state is slow, resolves keep being served from the current instances
+	slowSupplier, _ := fakeSupplier(200 * time.Millisecond)
+	pool.supplier = slowSupplier
+	var swapped atomic.Bool
+	done := make(chan error)
+	go func() {
+		err := pool.SetResolverState(ctx, &messages.SetResolverStateRequest{State: []byte("v2")})
+		swapped.Store(true)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if state := resolvedState(t, pool); state != "v1" || swapped.Load() {
+		t.Errorf("Expected the resolve to be served by the old state during the swap, got %q", state)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+	if state := resolvedState(t, pool); state != "v2" {
+		t.Errorf("Expected the new state after the swap, got %q", state)
+	}
+}
+
+func TestPooledResolver_ResolveWaitsForBusySlots(t *testing.T) {
+	supplier, _ := fakeSupplier(0)
+	pool := NewPooledResolver(0, supplier)
+	if err := pool.SetResolverState(context.Background(), &messages.SetResolverStateRequest{State: []byte("v1")}); err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+
+	slot := (*pool.slots.Load())[0]
+	slot.rw.Lock()
+	resolved := make(chan string)
+	go func() {
+		resolved <- resolvedState(t, pool)
+	}()
+	select {
+	case <-resolved:
+		t.Fatal("Expected the resolve to wait for the busy slot")
+	case <-time.After(20 * time.Millisecond):
+	}
+	slot.rw.Unlock()
+	if state := <-resolved; state != "v1" {
+		t.Errorf("Expected the resolve to complete once the slot is free, got %q", state)
+	}
+}
+
+func TestPooledResolver_CloseRetiresSlots(t *testing.T) {
+	supplier, created := fakeSupplier(0)
+	pool := NewPooledResolver(1, supplier)
+	if err := pool.SetResolverState(context.Background(), &messages.SetResolverStateRequest{State: []byte("v1")}); err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+	if err := pool.Close(context.Background()); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	if _, err := pool.ResolveWithSticky(context.Background(), &resolver.ResolveWithStickyRequest{}); err != ErrInstanceClosed {
+		t.Errorf("Expected ErrInstanceClosed after Close, got: %v", err)
+	}
+	for i, instance := range created() {
+		if !instance.closed || instance.resolves != 0 {
+			t.Errorf("Expected instance %d to be closed and never resolve on, got closed %v with %d resolves", i, instance.closed, instance.resolves)
+		}
+	}
+}
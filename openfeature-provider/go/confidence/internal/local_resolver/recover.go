@@ -3,6 +3,7 @@ package local_resolver
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"sync/atomic"
 	"time"
 
@@ -22,7 +23,7 @@ func NewRecoveringResolverFactory(inner LocalResolverFactory) *RecoveringResolve
 
 func (f *RecoveringResolverFactory) New() LocalResolver {
 	rr := &RecoveringResolver{
-		factory: f,
+		factory: f.LocalResolverFactory,
 	}
 	lr := f.LocalResolverFactory.New()
 	rr.current.Store(lr)
@@ -37,6 +38,7 @@ type RecoveringResolver struct {
 
 	current atomic.Value // holds LocalResolver
 	broken  atomic.Bool  // indicates an instance has panicked
+	closed  atomic.Bool  // set by Close, no instances are recreated afterwards
 
 	lastState atomic.Value // holds *messages.SetResolverStateRequest
 }
@@ -51,14 +53,29 @@ func (r *RecoveringResolver) get() LocalResolver {
 // startRecreate starts a background recreation.
 // It replaces the current resolver with a fresh one and reapplies last state.
 // Old instance is closed in a best-effort goroutine with a short timeout.
+// A panic while recreating is logged and leaves the current resolver in place,
+// so the next panic of it starts another attempt.
 func (r *RecoveringResolver) startRecreate() {
 	go func() {
 		defer r.broken.Store(false)
+		defer func() {
+			if rec := recover(); rec != nil {
+				slog.Error("Failed to recreate resolver after panic", "error", rec)
+			}
+		}()
+		if r.closed.Load() {
+			return
+		}
 		old := r.get()
 		newLR := r.factory.New()
 		if v := r.lastState.Load(); v != nil {
 			state := v.(*messages.SetResolverStateRequest)
-			_ = newLR.SetResolverState(state)
+			_ = newLR.SetResolverState(context.Background(), state)
+		}
+		if r.closed.Load() {
+			// Closed while recreating, the new instance has no logs to flush
+			_ = newLR.Close(context.Background())
+			return
 		}
 		r.current.Store(newLR)
 		if old != nil {
@@ -73,10 +90,7 @@ func (r *RecoveringResolver) startRecreate() {
 func (r *RecoveringResolver) withRecover(opName string, setErr *error, fn func(LocalResolver)) {
 	defer func() {
 		if rec := recover(); rec != nil {
-			// mark broken and kick off background recreation once
-			if r.broken.CompareAndSwap(false, true) {
-				r.startRecreate()
-			}
+			r.recreate()
 			if setErr != nil {
 				*setErr = fmt.Errorf("resolver panicked during %s: %v", opName, rec)
 			}
@@ -86,39 +100,47 @@ func (r *RecoveringResolver) withRecover(opName string, setErr *error, fn func(L
 	fn(lr)
 }
 
-func (r *RecoveringResolver) SetResolverState(request *messages.SetResolverStateRequest) (err error) {
+// recreate marks the current instance broken and kicks off background recreation once
+func (r *RecoveringResolver) recreate() {
+	if r.broken.CompareAndSwap(false, true) {
+		r.startRecreate()
+	}
+}
+
+func (r *RecoveringResolver) SetResolverState(ctx context.Context, request *messages.SetResolverStateRequest) (err error) {
 	r.withRecover("SetResolverState", &err, func(lr LocalResolver) {
-		err = lr.SetResolverState(request)
+		err = lr.SetResolverState(ctx, request)
 		// Cache last successful state
-		if err != nil {
+		if err == nil {
 			r.lastState.Store(request)
 		}
 	})
 	return
 }
 
-func (r *RecoveringResolver) ResolveWithSticky(request *resolver.ResolveWithStickyRequest) (resp *resolver.ResolveWithStickyResponse, err error) {
+func (r *RecoveringResolver) ResolveWithSticky(ctx context.Context, request *resolver.ResolveWithStickyRequest) (resp *resolver.ResolveWithStickyResponse, err error) {
 	r.withRecover("ResolveWithSticky", &err, func(lr LocalResolver) {
-		resp, err = lr.ResolveWithSticky(request)
+		resp, err = lr.ResolveWithSticky(ctx, request)
 	})
 	return
 }
 
-func (r *RecoveringResolver) FlushAllLogs() (err error) {
+func (r *RecoveringResolver) FlushAllLogs(ctx context.Context) (err error) {
 	r.withRecover("FlushAllLogs", &err, func(lr LocalResolver) {
-		err = lr.FlushAllLogs()
+		err = lr.FlushAllLogs(ctx)
 	})
 	return
 }
 
-func (r *RecoveringResolver) FlushAssignLogs() (err error) {
+func (r *RecoveringResolver) FlushAssignLogs(ctx context.Context) (err error) {
 	r.withRecover("FlushAssignLogs", &err, func(lr LocalResolver) {
-		err = lr.FlushAssignLogs()
+		err = lr.FlushAssignLogs(ctx)
 	})
 	return
 }
 
 func (r *RecoveringResolver) Close(ctx context.Context) error {
+	r.closed.Store(true)
 	// For Close, if we panic, don't recreate during shutdown; just surface error.
 	defer func() {
 		if rec := recover(); rec != nil {
@@ -131,3 +153,12 @@ func (r *RecoveringResolver) Close(ctx context.Context) error {
 	}
 	return lr.Close(ctx)
 }
+
+// MemoryStats reports the stats of the current instance, if it reports them.
+// The peak of an instance that was replaced after a panic is not carried over.
+func (r *RecoveringResolver) MemoryStats() MemoryStats {
+	if reporter, ok := r.get().(MemoryStatsReporter); ok {
+		return reporter.MemoryStats()
+	}
+	return MemoryStats{}
+}
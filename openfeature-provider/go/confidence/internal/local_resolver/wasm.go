@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "embed"
@@ -18,7 +21,7 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// defaultWasmBytes contains the embedded WASM resolver module.
+// wasmBytes contains the embedded WASM resolver module.
 // This file is automatically populated during the build process from wasm/confidence_resolver.wasm.
 // The WASM file is built from the Rust source in wasm/rust-guest/ and must be kept in sync.
 //
@@ -31,51 +34,137 @@ type LogSink func(logs *resolverv1.WriteFlagLogsRequest)
 
 func NoOpLogSink(logs *resolverv1.WriteFlagLogsRequest) {}
 
+// MemoryStats describes the linear memory of resolver guest instances
+type MemoryStats struct {
+	// Pages is the current memory size in 64KiB WASM pages
+	Pages uint32
+	// Bytes is the current memory size in bytes
+	Bytes uint64
+	// PeakBytes is the largest memory size observed after any guest call
+	PeakBytes uint64
+}
+
+// MemoryStatsReporter is implemented by resolvers that can report guest memory usage
+type MemoryStatsReporter interface {
+	MemoryStats() MemoryStats
+}
+
+// ErrInstanceClosed is returned by calls made on a WasmResolver after Close
+var ErrInstanceClosed = errors.New("WASM instance is closed or being replaced")
+
 type WasmResolver struct {
-	instance api.Module
-	logSink  LogSink
-	mu       *sync.Mutex
+	instance  api.Module
+	logSink   LogSink
+	mu        *sync.Mutex
+	peakBytes uint64 // guarded by mu
+	closed    bool   // guarded by mu
 }
 
-var _ LocalResolver = (*WasmResolver)(nil)
+var (
+	_ LocalResolver       = (*WasmResolver)(nil)
+	_ MemoryStatsReporter = (*WasmResolver)(nil)
+)
 
-func (r *WasmResolver) SetResolverState(request *messages.SetResolverStateRequest) error {
-	return r.call("wasm_msg_guest_set_resolver_state", request, nil)
+func (r *WasmResolver) SetResolverState(ctx context.Context, request *messages.SetResolverStateRequest) error {
+	return r.call(ctx, "wasm_msg_guest_set_resolver_state", request, nil)
 }
 
-func (r *WasmResolver) ResolveWithSticky(request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+func (r *WasmResolver) ResolveWithSticky(ctx context.Context, request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
 	resp := &resolver.ResolveWithStickyResponse{}
-	err := r.call("wasm_msg_guest_resolve_with_sticky", request, resp)
+	err := r.call(ctx, "wasm_msg_guest_resolve_with_sticky", request, resp)
 	return resp, err
 }
 
-func (r *WasmResolver) FlushAllLogs() error {
+func (r *WasmResolver) FlushAllLogs(ctx context.Context) error {
 	resp := &resolverv1.WriteFlagLogsRequest{}
-	err := r.call("wasm_msg_guest_bounded_flush_logs", nil, resp)
+	err := r.call(ctx, "wasm_msg_guest_bounded_flush_logs", nil, resp)
 	if err == nil && proto.Size(resp) > 0 {
 		r.logSink(resp)
 	}
 	return err
 }
 
-func (r *WasmResolver) FlushAssignLogs() error {
+func (r *WasmResolver) FlushAssignLogs(ctx context.Context) error {
 	resp := &resolverv1.WriteFlagLogsRequest{}
-	err := r.call("wasm_msg_guest_bounded_flush_assign", nil, resp)
+	err := r.call(ctx, "wasm_msg_guest_bounded_flush_assign", nil, resp)
 	if err == nil && len(resp.FlagAssigned) > 0 {
 		r.logSink(resp)
 	}
 	return err
 }
 
+// Close flushes all pending logs to the log sink and closes the instance. The log sink
+// has been called for every remaining log by the time Close returns. Closing an already
+// closed instance is a no-op.
 func (r *WasmResolver) Close(ctx context.Context) error {
-	// TODO we should call flush assigned until it doesn't flush any more
-	r.FlushAllLogs()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+
+	resp := &resolverv1.WriteFlagLogsRequest{}
+	if err := r.callLocked(ctx, "wasm_msg_guest_bounded_flush_logs", nil, resp); err == nil && proto.Size(resp) > 0 {
+		r.logSink(resp)
+	}
+	// A bounded flush only includes assign logs up to the byte limit, drain the rest
+	for {
+		resp := &resolverv1.WriteFlagLogsRequest{}
+		if err := r.callLocked(ctx, "wasm_msg_guest_bounded_flush_assign", nil, resp); err != nil || len(resp.FlagAssigned) == 0 {
+			break
+		}
+		r.logSink(resp)
+	}
+	r.closed = true
 	return r.instance.Close(ctx)
 }
 
-func (r *WasmResolver) call(fnName string, request proto.Message, response proto.Message) error {
+// MemoryStats returns the current and peak memory size of the instance. WASM memory only
+// grows, so for a single instance the peak is the size reached by the largest call so far.
+func (r *WasmResolver) MemoryStats() MemoryStats {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if r.closed {
+		return MemoryStats{PeakBytes: r.peakBytes}
+	}
+	r.recordMemoryLocked()
+	bytes := uint64(r.instance.Memory().Size())
+	return MemoryStats{
+		Pages:     uint32(bytes / wasmPageSize),
+		Bytes:     bytes,
+		PeakBytes: r.peakBytes,
+	}
+}
+
+// wasmPageSize is the size of a WASM memory page
+const wasmPageSize = 65536
+
+// recordMemoryLocked updates the memory high-water mark. Must be called with r.mu held.
+func (r *WasmResolver) recordMemoryLocked() {
+	if size := uint64(r.instance.Memory().Size()); size > r.peakBytes {
+		r.peakBytes = size
+	}
+}
+
+// call invokes a guest function. A context that is done by the time the instance is free
+// aborts the call before entering the guest. A call already running in the guest is not
+// interrupted: guest calls are short, and interrupting one would close the instance along
+// with its pending logs and the resolves queued on it.
+func (r *WasmResolver) call(ctx context.Context, fnName string, request proto.Message, response proto.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrInstanceClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.callLocked(ctx, fnName, request, response)
+}
+
+// callLocked is call for callers already holding r.mu
+func (r *WasmResolver) callLocked(ctx context.Context, fnName string, request proto.Message, response proto.Message) error {
+	defer r.recordMemoryLocked()
 
 	reqPtr := uint32(0)
 	if request != nil {
@@ -84,9 +173,10 @@ func (r *WasmResolver) call(fnName string, request proto.Message, response proto
 		}
 		reqPtr = transfer(r.instance, mustMarshal(wsmMsgReq))
 	}
-	ctx := context.Background()
 	fn := r.instance.ExportedFunction(fnName)
-	resPtr, err := fn.Call(ctx, uint64(reqPtr))
+	// Without cancellation, so a runtime created with CloseOnContextDone can't close the
+	// instance mid-call either
+	resPtr, err := fn.Call(context.WithoutCancel(ctx), uint64(reqPtr))
 	if err != nil {
 		panic(err)
 	}
@@ -109,19 +199,83 @@ func (r *WasmResolver) call(fnName string, request proto.Message, response proto
 type WasmResolverFactory struct {
 	runtime wazero.Runtime
 	module  wazero.CompiledModule
+	shared  bool // the runtime belongs to the caller and is left open on Close
 	logSink LogSink
 }
 
+// instanceID numbers instances across factories so instances never collide on a shared runtime
+var instanceID atomic.Uint64
+
 var _ LocalResolverFactory = (*WasmResolverFactory)(nil)
 
-func NewWasmResolverFactory(logSink LogSink) LocalResolverFactory {
-	ctx := context.Background()
+// CompiledWasm is a resolver guest module compiled on its own runtime with the
+// host functions registered. Ownership passes to the factory created from it, unless it
+// wraps a shared runtime, see NewSharedCompiledWasm.
+type CompiledWasm struct {
+	runtime wazero.Runtime
+	module  wazero.CompiledModule
+	shared  bool
+}
+
+// Clock supplies the current time to the resolver guest, e.g. for evaluating time-based
+// targeting rules and stamping flag logs.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// nowKey is the context key of a time overriding the clock for guest calls, see WithNow
+type nowKey struct{}
+
+// WithNow returns a context making guest calls made with it read now as the current time
+// instead of the clock, e.g. to resolve several flags at one point in time
+func WithNow(ctx context.Context, now time.Time) context.Context {
+	return context.WithValue(ctx, nowKey{}, now)
+}
+
+// CompileWasm compiles the given resolver guest binary. Returns an error if the
+// bytes are not a valid WASM module.
+func CompileWasm(ctx context.Context, wasm []byte) (*CompiledWasm, error) {
+	return CompileWasmWithClock(ctx, wasm, nil)
+}
+
+// CompileWasmWithClock is CompileWasm with the guest reading the current time from clock.
+// A nil clock uses the system clock.
+func CompileWasmWithClock(ctx context.Context, wasm []byte, clock Clock) (*CompiledWasm, error) {
 	runtime := wazero.NewRuntime(ctx)
+	if err := RegisterHostFunctions(ctx, runtime, clock); err != nil {
+		runtime.Close(ctx)
+		return nil, err
+	}
+	module, err := CompileModule(ctx, runtime, wasm)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, err
+	}
+	return &CompiledWasm{
+		runtime: runtime,
+		module:  module,
+	}, nil
+}
+
+// RegisterHostFunctions registers the host functions the resolver guest imports on runtime,
+// with the guest reading the current time from clock, nil for the system clock. They must
+// be registered exactly once per runtime, before compiling guests on it.
+func RegisterHostFunctions(ctx context.Context, runtime wazero.Runtime, clock Clock) error {
+	if clock == nil {
+		clock = realClock{}
+	}
 	_, err := runtime.NewHostModuleBuilder("wasm_msg").
 		NewFunctionBuilder().
 		WithFunc(func(ctx context.Context, mod api.Module, ptr uint32) uint32 {
-			// Return current timestamp
-			now := time.Now()
+			// Return current timestamp, pinned by the calling context if set
+			now, ok := ctx.Value(nowKey{}).(time.Time)
+			if !ok {
+				now = clock.Now()
+			}
 			timestamp := timestamppb.New(now)
 
 			// Create response wrapper
@@ -136,24 +290,92 @@ func NewWasmResolverFactory(logSink LogSink) LocalResolverFactory {
 		Export("wasm_msg_host_current_time").
 		Instantiate(ctx)
 	if err != nil {
-		runtime.Close(ctx)
-		panic(err)
+		return fmt.Errorf("failed to register host functions: %w", err)
 	}
-	module, err := runtime.CompileModule(ctx, wasmBytes)
+	return nil
+}
+
+// CompileModule compiles the resolver guest binary on runtime, the embedded guest when wasm
+// is nil. Returns an error if the bytes are not a valid, compatible WASM module.
+func CompileModule(ctx context.Context, runtime wazero.Runtime, wasm []byte) (wazero.CompiledModule, error) {
+	if wasm == nil {
+		wasm = wasmBytes
+	}
+	module, err := runtime.CompileModule(ctx, wasm)
 	if err != nil {
-		runtime.Close(ctx)
-		panic(err)
+		return nil, fmt.Errorf("failed to compile WASM module: %w", err)
 	}
-	return &WasmResolverFactory{
+	if err := checkExports(module); err != nil {
+		module.Close(ctx)
+		return nil, err
+	}
+	return module, nil
+}
+
+// NewSharedCompiledWasm wraps a guest module compiled on runtime, which must have the host
+// functions registered, so several resolvers can share one compilation. The caller keeps
+// ownership of both: closing the resolvers only closes their own instances.
+func NewSharedCompiledWasm(runtime wazero.Runtime, module wazero.CompiledModule) (*CompiledWasm, error) {
+	if err := checkExports(module); err != nil {
+		return nil, err
+	}
+	return &CompiledWasm{
 		runtime: runtime,
 		module:  module,
+		shared:  true,
+	}, nil
+}
+
+// requiredExports are the guest functions the host calls
+var requiredExports = []string{
+	"wasm_msg_alloc",
+	"wasm_msg_free",
+	"wasm_msg_guest_set_resolver_state",
+	"wasm_msg_guest_resolve_with_sticky",
+	"wasm_msg_guest_bounded_flush_logs",
+	"wasm_msg_guest_bounded_flush_assign",
+}
+
+// checkExports verifies the guest exports every function the host calls, so an
+// incompatible guest is rejected up front instead of panicking on first use.
+func checkExports(module wazero.CompiledModule) error {
+	exported := module.ExportedFunctions()
+	var missing []string
+	for _, name := range requiredExports {
+		if _, ok := exported[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("incompatible WASM module, missing required exports: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// NewWasmResolverFactory creates a factory using the embedded resolver guest.
+func NewWasmResolverFactory(logSink LogSink) LocalResolverFactory {
+	compiled, err := CompileWasm(context.Background(), wasmBytes)
+	if err != nil {
+		panic(err)
+	}
+	return NewWasmResolverFactoryFromCompiled(compiled, logSink)
+}
+
+// NewWasmResolverFactoryFromCompiled creates a factory instantiating the given compiled guest.
+func NewWasmResolverFactoryFromCompiled(compiled *CompiledWasm, logSink LogSink) LocalResolverFactory {
+	return &WasmResolverFactory{
+		runtime: compiled.runtime,
+		module:  compiled.module,
+		shared:  compiled.shared,
 		logSink: logSink,
 	}
 }
 
 func (wrf *WasmResolverFactory) New() LocalResolver {
 	ctx := context.Background()
-	config := wazero.NewModuleConfig().WithName("")
+	// Give every instance a unique name so instances never collide on the shared runtime
+	name := fmt.Sprintf("confidence-resolver-%d", instanceID.Add(1))
+	config := wazero.NewModuleConfig().WithName(name)
 	instance, err := wrf.runtime.InstantiateModule(ctx, wrf.module, config)
 	if err != nil {
 		panic(err)
@@ -166,6 +388,9 @@ func (wrf *WasmResolverFactory) New() LocalResolver {
 }
 
 func (wrf *WasmResolverFactory) Close(ctx context.Context) error {
+	if wrf.shared {
+		return nil
+	}
 	return wrf.runtime.Close(ctx)
 }
 
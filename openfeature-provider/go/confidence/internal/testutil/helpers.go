@@ -54,7 +54,7 @@ func (m *StateProviderMock) Provide(_ context.Context) ([]byte, string, error) {
 	return m.State, m.AccountID, m.Err
 }
 
-func LoadTestResolverState(t *testing.T) []byte {
+func LoadTestResolverState(t testing.TB) []byte {
 	dataPath := filepath.Join(repoRoot, "data", "resolver_state_current.pb")
 	data, err := os.ReadFile(dataPath)
 	if err != nil {
@@ -63,7 +63,7 @@ func LoadTestResolverState(t *testing.T) []byte {
 	return data
 }
 
-func LoadTestAccountID(t *testing.T) string {
+func LoadTestAccountID(t testing.TB) string {
 	dataPath := filepath.Join(repoRoot, "data", "account_id")
 	data, err := os.ReadFile(dataPath)
 	if err != nil {
@@ -0,0 +1,112 @@
+package confidence
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/open-feature/go-sdk/openfeature"
+)
+
+// maxSafeInteger is the largest magnitude up to which every integer is exactly representable
+// as a float64, which is how evaluation context numbers are sent to the resolver
+const maxSafeInteger = 1 << 53
+
+// maxLargeIntWarnings bounds the context keys remembered as warned about, so contexts with
+// unbounded key sets don't grow it without limit. Keys beyond it are not warned about.
+const maxLargeIntWarnings = 1000
+
+// warnedKeys is a bounded set of context keys already warned about. A warnedKeys is safe
+// for concurrent use.
+type warnedKeys struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+// add records key and reports whether it should be warned about, i.e. it wasn't recorded
+// before and the set isn't full
+func (w *warnedKeys) add(key string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.keys[key]; ok || len(w.keys) >= maxLargeIntWarnings {
+		return false
+	}
+	if w.keys == nil {
+		w.keys = make(map[string]struct{})
+	}
+	w.keys[key] = struct{}{}
+	return true
+}
+
+// encodeLargeInts checks evalCtx for integers beyond ±2^53, which lose precision when sent as
+// numbers. With LargeIntsAsStrings they are replaced by their decimal string in a copy of the
+// context, otherwise each offending key is warned about once, for up to maxLargeIntWarnings
+// keys. Contexts without such integers are returned as is.
+func (p *LocalResolverProvider) encodeLargeInts(evalCtx openfeature.FlattenedContext) openfeature.FlattenedContext {
+	encoded, changed := p.encodeLargeIntValue("", map[string]interface{}(evalCtx))
+	if !changed {
+		return evalCtx
+	}
+	return openfeature.FlattenedContext(encoded.(map[string]interface{}))
+}
+
+// encodeLargeIntValue returns value with large integers encoded, copying the maps and lists
+// on the way to them, and whether anything was encoded. key is the dotted path of value.
+func (p *LocalResolverProvider) encodeLargeIntValue(key string, value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case int:
+		return p.encodeLargeInt(key, int64(v))
+	case int64:
+		return p.encodeLargeInt(key, v)
+	case []interface{}:
+		var encoded []interface{}
+		for i, item := range v {
+			if item, changed := p.encodeLargeIntValue(key, item); changed {
+				if encoded == nil {
+					encoded = append([]interface{}(nil), v...)
+				}
+				encoded[i] = item
+			}
+		}
+		if encoded == nil {
+			return v, false
+		}
+		return encoded, true
+	case map[string]interface{}:
+		var encoded map[string]interface{}
+		for field, item := range v {
+			path := field
+			if key != "" {
+				path = key + "." + field
+			}
+			if item, changed := p.encodeLargeIntValue(path, item); changed {
+				if encoded == nil {
+					encoded = make(map[string]interface{}, len(v))
+					for k, original := range v {
+						encoded[k] = original
+					}
+				}
+				encoded[field] = item
+			}
+		}
+		if encoded == nil {
+			return v, false
+		}
+		return encoded, true
+	}
+	return value, false
+}
+
+// encodeLargeInt returns n as a string if it is beyond ±2^53 and LargeIntsAsStrings is set
+func (p *LocalResolverProvider) encodeLargeInt(key string, n int64) (interface{}, bool) {
+	if n >= -maxSafeInteger && n <= maxSafeInteger {
+		return n, false
+	}
+	if p.largeIntsAsStrings {
+		return strconv.FormatInt(n, 10), true
+	}
+	if p.largeIntWarned.add(key) {
+		p.log().Warn("Evaluation context integer exceeds 2^53 and loses precision, "+
+			"set LargeIntsAsStrings to send it as a string", "key", key)
+	}
+	return n, false
+}
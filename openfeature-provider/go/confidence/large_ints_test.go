@@ -0,0 +1,77 @@
+package confidence
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/open-feature/go-sdk/openfeature"
+)
+
+func TestLocalResolverProvider_EncodeLargeInts(t *testing.T) {
+	const userID = int64(1)<<53 + 1
+	evalCtx := openfeature.FlattenedContext{
+		"user_id": userID,
+		"age":     42,
+		"account": map[string]interface{}{"ids": []interface{}{int64(7), -userID}},
+	}
+
+	provider := NewLocalResolverProvider(nil, nil, nil, "secret", nil)
+	provider.largeIntsAsStrings = true
+	encoded := provider.encodeLargeInts(evalCtx)
+	expected := openfeature.FlattenedContext{
+		"user_id": "9007199254740993",
+		"age":     42,
+		"account": map[string]interface{}{"ids": []interface{}{int64(7), "-9007199254740993"}},
+	}
+	if !reflect.DeepEqual(encoded, expected) {
+		t.Errorf("Expected large integers to be encoded as strings, got %v", encoded)
+	}
+	if evalCtx["user_id"] != userID {
+		t.Error("Expected the caller's context to be left untouched")
+	}
+
+	small := openfeature.FlattenedContext{"age": 42, "max": int64(1) << 53}
+	if encoded := provider.encodeLargeInts(small); !reflect.DeepEqual(encoded, small) {
+		t.Errorf("Expected safe integers to be kept, got %v", encoded)
+	}
+}
+
+func TestLocalResolverProvider_EncodeLargeInts_Warns(t *testing.T) {
+	var logs bytes.Buffer
+	provider := NewLocalResolverProvider(nil, nil, nil, "secret", slog.New(slog.NewTextHandler(&logs, nil)))
+	evalCtx := openfeature.FlattenedContext{"user_id": int64(1) << 60}
+
+	for range 2 {
+		if encoded := provider.encodeLargeInts(evalCtx); !reflect.DeepEqual(encoded, evalCtx) {
+			t.Errorf("Expected the context to be sent as is without LargeIntsAsStrings, got %v", encoded)
+		}
+	}
+	if count := strings.Count(logs.String(), "loses precision"); count != 1 {
+		t.Errorf("Expected a single precision warning, got %d:\n%s", count, logs.String())
+	}
+	if !strings.Contains(logs.String(), "key=user_id") {
+		t.Errorf("Expected the warning to name the key, got:\n%s", logs.String())
+	}
+}
+
+func TestWarnedKeys_Bounded(t *testing.T) {
+	var warned warnedKeys
+	for i := 0; i < maxLargeIntWarnings; i++ {
+		if !warned.add(strconv.Itoa(i)) {
+			t.Fatalf("Expected key %d to be warned about", i)
+		}
+	}
+	if warned.add("0") {
+		t.Error("Expected a key to be warned about once")
+	}
+	if warned.add("another") {
+		t.Error("Expected no new keys once the set is full")
+	}
+	if len(warned.keys) != maxLargeIntWarnings {
+		t.Errorf("Expected the set to stay at %d keys, got %d", maxLargeIntWarnings, len(warned.keys))
+	}
+}
@@ -0,0 +1,24 @@
+package confidence
+
+import (
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+)
+
+// Metrics receives measurements from the provider, e.g. to export them as Prometheus
+// counters and histograms, see the prommetrics package. Methods are called on the evaluation and background paths
+// and must be safe for concurrent use and return quickly.
+type Metrics interface {
+	// ResolveCompleted is called after each evaluation with its reason and latency, for
+	// ObjectEvaluation and PreviewEvaluation as well as Resolve, ResolveRaw, ResolveWithToken
+	// and resolves through a Session or Snapshot
+	ResolveCompleted(reason openfeature.Reason, latency time.Duration)
+	// StateReloaded is called after each background state poll, with nil on success
+	StateReloaded(err error)
+	// FlagLogsWritten is called after each flag log request sent to the Confidence backend
+	// with its serialized size and the outcome of the call, nil on success. Requests dropped
+	// by the circuit breaker are reported with ErrFlagLogCircuitOpen. Not called for custom
+	// FlagLoggers.
+	FlagLogsWritten(bytes int, err error)
+}
@@ -0,0 +1,107 @@
+package confidence
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	fl "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/flag_logger"
+	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
+	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
+)
+
+type recordingMetrics struct {
+	mu          sync.Mutex
+	resolves    map[openfeature.Reason]int
+	reloads     int
+	reloadErrs  int
+	logRequests int
+	logBytes    int
+}
+
+func (m *recordingMetrics) ResolveCompleted(reason openfeature.Reason, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resolves[reason]++
+}
+
+func (m *recordingMetrics) StateReloaded(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reloads++
+	if err != nil {
+		m.reloadErrs++
+	}
+}
+
+func (m *recordingMetrics) FlagLogsWritten(bytes int, _ error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logRequests++
+	m.logBytes += bytes
+}
+
+func TestLocalResolverProvider_Metrics(t *testing.T) {
+	stateProvider := &tu.StateProviderMock{
+		State:     tu.LoadTestResolverState(t),
+		AccountID: tu.LoadTestAccountID(t),
+	}
+	metrics := &recordingMetrics{resolves: map[openfeature.Reason]int{}}
+	provider := NewLocalResolverProvider(lr.NewLocalResolver, stateProvider, fl.NewCapturingFlagLogger(), "mkjJruAATQWjeY7foFIWfVAcBWnci2YF", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	provider.metrics = metrics
+	provider.pollInterval = 10 * time.Millisecond
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+
+	if stats := provider.MemoryStats(); stats.Bytes == 0 || stats.PeakBytes < stats.Bytes {
+		t.Errorf("Expected guest memory stats, got %+v", stats)
+	}
+	evalCtx := openfeature.FlattenedContext{"visitor_id": "tutorial_visitor"}
+	provider.ObjectEvaluation(context.Background(), "tutorial-feature", nil, evalCtx)
+	provider.ObjectEvaluation(context.Background(), "missing-flag", nil, openfeature.FlattenedContext{})
+	if _, err := provider.ResolveRaw(context.Background(), "tutorial-feature", evalCtx); err != nil {
+		t.Fatalf("Failed to resolve: %v", err)
+	}
+	response, err := provider.Resolve(context.Background(), []string{"tutorial-feature"}, evalCtx, false)
+	if err != nil {
+		t.Fatalf("Failed to resolve: %v", err)
+	}
+	provider.ResolveWithToken(context.Background(), "tutorial-feature", response.ResolveToken)
+	snapshot, err := provider.Snapshot(evalCtx)
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	snapshot.Resolve(context.Background(), "tutorial-feature", nil)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		metrics.mu.Lock()
+		reloads := metrics.reloads
+		metrics.mu.Unlock()
+		if reloads > 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	provider.Shutdown()
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	expected := map[openfeature.Reason]int{
+		openfeature.TargetingMatchReason: 4,
+		openfeature.ErrorReason:          1,
+		openfeature.UnknownReason:        1,
+	}
+	if !reflect.DeepEqual(metrics.resolves, expected) {
+		t.Errorf("Expected every resolve to be measured once, got %v", metrics.resolves)
+	}
+	if metrics.reloads == 0 || metrics.reloadErrs != 0 {
+		t.Errorf("Expected successful state reloads, got %d reloads and %d failures", metrics.reloads, metrics.reloadErrs)
+	}
+}
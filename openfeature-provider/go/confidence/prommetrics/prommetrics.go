@@ -0,0 +1,92 @@
+// Package prommetrics exports the measurements of a Confidence provider as Prometheus
+// collectors. It lives apart from the confidence package so providers that don't export
+// metrics don't depend on the Prometheus client.
+package prommetrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence"
+)
+
+// Metrics is a confidence.Metrics backed by Prometheus collectors
+type Metrics struct {
+	resolves           *prometheus.CounterVec
+	resolveLatency     prometheus.Histogram
+	stateReloads       prometheus.Counter
+	stateReloadFailure prometheus.Counter
+	flagLogRequests    *prometheus.CounterVec
+	flagLogBytes       prometheus.Counter
+}
+
+var _ confidence.Metrics = (*Metrics)(nil)
+
+// NewMetrics creates the provider collectors and registers them with registerer, e.g.
+// prometheus.DefaultRegisterer. Set the result as ProviderConfig.Metrics.
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		resolves: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "confidence_resolves_total",
+			Help: "Flag evaluations by resolve reason.",
+		}, []string{"reason"}),
+		resolveLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "confidence_resolve_duration_seconds",
+			Help:    "Latency of flag evaluations.",
+			Buckets: []float64{.00005, .0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1},
+		}),
+		stateReloads: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "confidence_state_reloads_total",
+			Help: "Background resolver state polls.",
+		}),
+		stateReloadFailure: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "confidence_state_reload_failures_total",
+			Help: "Background resolver state polls that failed to fetch or apply state.",
+		}),
+		flagLogRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "confidence_flag_log_requests_total",
+			Help: "Flag log requests sent to Confidence by result: success, error or circuit_open.",
+		}, []string{"result"}),
+		flagLogBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "confidence_flag_log_bytes_total",
+			Help: "Serialized bytes of flag log requests successfully sent to Confidence.",
+		}),
+	}
+	for _, collector := range []prometheus.Collector{
+		m.resolves, m.resolveLatency, m.stateReloads, m.stateReloadFailure, m.flagLogRequests, m.flagLogBytes,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ResolveCompleted implements confidence.Metrics
+func (m *Metrics) ResolveCompleted(reason openfeature.Reason, latency time.Duration) {
+	m.resolves.WithLabelValues(string(reason)).Inc()
+	m.resolveLatency.Observe(latency.Seconds())
+}
+
+// StateReloaded implements confidence.Metrics
+func (m *Metrics) StateReloaded(err error) {
+	m.stateReloads.Inc()
+	if err != nil {
+		m.stateReloadFailure.Inc()
+	}
+}
+
+// FlagLogsWritten implements confidence.Metrics
+func (m *Metrics) FlagLogsWritten(bytes int, err error) {
+	switch {
+	case err == nil:
+		m.flagLogRequests.WithLabelValues("success").Inc()
+		m.flagLogBytes.Add(float64(bytes))
+	case errors.Is(err, confidence.ErrFlagLogCircuitOpen):
+		m.flagLogRequests.WithLabelValues("circuit_open").Inc()
+	default:
+		m.flagLogRequests.WithLabelValues("error").Inc()
+	}
+}
@@ -0,0 +1,75 @@
+package prommetrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence"
+)
+
+// gather returns the value of each sample of the registry keyed by metric name and label values
+func gather(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			key := family.GetName()
+			for _, label := range metric.GetLabel() {
+				key += "/" + label.GetValue()
+			}
+			switch {
+			case metric.GetCounter() != nil:
+				values[key] = metric.GetCounter().GetValue()
+			case metric.GetHistogram() != nil:
+				values[key] = float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return values
+}
+
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics, err := NewMetrics(registry)
+	if err != nil {
+		t.Fatalf("Failed to register metrics: %v", err)
+	}
+
+	metrics.ResolveCompleted(openfeature.TargetingMatchReason, time.Millisecond)
+	metrics.ResolveCompleted(openfeature.TargetingMatchReason, time.Millisecond)
+	metrics.ResolveCompleted(openfeature.ErrorReason, time.Millisecond)
+	metrics.StateReloaded(nil)
+	metrics.StateReloaded(errors.New("fetch failed"))
+	metrics.FlagLogsWritten(100, nil)
+	metrics.FlagLogsWritten(50, errors.New("unavailable"))
+	metrics.FlagLogsWritten(50, confidence.ErrFlagLogCircuitOpen)
+
+	expected := map[string]float64{
+		"confidence_resolves_total/TARGETING_MATCH":       2,
+		"confidence_resolves_total/ERROR":                 1,
+		"confidence_resolve_duration_seconds":             3,
+		"confidence_state_reloads_total":                  2,
+		"confidence_state_reload_failures_total":          1,
+		"confidence_flag_log_requests_total/success":      1,
+		"confidence_flag_log_requests_total/error":        1,
+		"confidence_flag_log_requests_total/circuit_open": 1,
+		"confidence_flag_log_bytes_total":                 100,
+	}
+	values := gather(t, registry)
+	for key, want := range expected {
+		if values[key] != want {
+			t.Errorf("Expected %s to be %v, got %v", key, want, values[key])
+		}
+	}
+
+	if _, err := NewMetrics(registry); err == nil {
+		t.Error("Expected registering the collectors twice to fail")
+	}
+}
@@ -1,18 +1,25 @@
 package confidence
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math/rand/v2"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/open-feature/go-sdk/openfeature"
 	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
 	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto"
+	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
 	resolvertypes "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolvertypes"
 	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/resolver"
 	"google.golang.org/protobuf/types/known/structpb"
@@ -20,27 +27,146 @@ import (
 
 const defaultPollIntervalSeconds = 30
 
+// defaultAssignFlushInterval is how often assign logs are flushed between state polls
+const defaultAssignFlushInterval = 100 * time.Millisecond
+
+// defaultPollJitter spreads state polls by ±10% of the poll interval unless configured otherwise
+const defaultPollJitter = 0.1
+
+// defaultSdk identifies this provider in resolve requests unless ProviderConfig.Sdk is set
+var defaultSdk = &resolvertypes.Sdk{
+	Sdk: &resolvertypes.Sdk_Id{
+		Id: resolvertypes.SdkId_SDK_ID_GO_LOCAL_PROVIDER,
+	},
+	Version: Version,
+}
+
 type LocalResolverSupplier func(context.Context, lr.LogSink) lr.LocalResolver
 
+// ErrProviderNotReady is the ResolutionError of evaluations made before Init has loaded
+// the initial state. Detect it with errors.Is on the detail's ResolutionError field; the
+// detail's Error() method returns a plain error that only carries its message.
+var ErrProviderNotReady = openfeature.NewProviderNotReadyResolutionError("provider not initialized")
+
+// ErrClientSecretNotFound is the ResolutionError of evaluations made with a client secret
+// that has no client credential in the resolver state, e.g. a misconfigured secret or one
+// passed with WithClientSecret. It carries the INVALID_CONTEXT error code.
+var ErrClientSecretNotFound = openfeature.NewInvalidContextResolutionError("client secret not found")
+
+// ErrInitTimeout is returned by Init when the initial state isn't fetched within the
+// configured InitTimeout
+var ErrInitTimeout = errors.New("timed out fetching initial state")
+
+// ErrAccountIDChanged is returned by state reloads when the fetched state belongs to a
+// different account than the loaded one, which almost always means a misconfigured
+// deployment. The update is rejected and the provider keeps serving the loaded state.
+var ErrAccountIDChanged = errors.New("account ID of fetched state changed")
+
+// resolveErrors maps errors reported by the resolver guest, matched on their message, to
+// resolution errors with a more specific code than GENERAL
+var resolveErrors = []struct {
+	message string
+	err     openfeature.ResolutionError
+}{
+	{message: "client secret not found", err: ErrClientSecretNotFound},
+}
+
+// resolveError converts an error returned by the resolver to a ResolutionError
+func resolveError(err error) openfeature.ResolutionError {
+	msg := err.Error()
+	for _, known := range resolveErrors {
+		if strings.Contains(msg, known.message) {
+			return known.err
+		}
+	}
+	return openfeature.NewGeneralResolutionError(fmt.Sprintf("resolve failed: %v", err))
+}
+
+// ArchivedFlagMode controls how evaluations of archived flags are reported
+type ArchivedFlagMode int
+
+const (
+	// ArchivedFlagDisabled returns the default value with DisabledReason and no error
+	ArchivedFlagDisabled ArchivedFlagMode = iota
+	// ArchivedFlagError returns the default value with ErrorReason and a GENERAL error code
+	ArchivedFlagError
+)
+
+// StickyMode controls how flags are resolved when their sticky rules need materializations
+// that aren't available locally
+type StickyMode int
+
+const (
+	// StickyFallback resolves them through the ResolverFallback, or fails the resolve
+	// without one
+	StickyFallback StickyMode = iota
+	// StickySkip resolves them locally with their sticky rules skipped. Flags that can't be
+	// resolved that way return the default value with DefaultReason. The ResolverFallback
+	// isn't used.
+	StickySkip
+)
+
 // LocalResolverProvider implements the OpenFeature FeatureProvider interface
 // for local flag resolution using the Confidence WASM resolver
 type LocalResolverProvider struct {
 	resolverSupplier LocalResolverSupplier
-	resolver         lr.LocalResolver
+	resolver         atomic.Value // holds lr.LocalResolver
 	stateProvider    StateProvider
 	flagLogger       FlagLogger
 	clientSecret     string
-	logger           *slog.Logger
+	sdk              *resolvertypes.Sdk
+	clock            Clock // passed to guests compiled by UpdateWasm, nil for the system clock
+	instances        int   // guest instances created by UpdateWasm, zero for the default
+	logger           atomic.Pointer[slog.Logger]
 	cancelFunc       context.CancelFunc
 	wg               sync.WaitGroup
 	mu               sync.Mutex
+	swapMu           sync.Mutex // serializes resolver state and guest swaps
+	reloadMu         sync.Mutex // serializes state fetches, taken before swapMu
 	pollInterval     time.Duration
+	pollJitter       float64
+	flushInterval    time.Duration // assign log flush interval, periodic flushing is off when not positive
+	staleThreshold   time.Duration
+	initTimeout      time.Duration // bounds the initial state fetch in Init, no bound when not positive
+	requireFlags     bool
+	archivedFlagMode ArchivedFlagMode
+	stale            atomic.Bool
+	events           chan openfeature.Event
+	ready            chan struct{} // closed once Init has loaded the initial state
+	readyOnce        sync.Once
+	hooks            []openfeature.Hook
+	flushObserver    FlushObserver
+	onStateUpdate    func(accountId string, stateBytes int, changed bool)
+	onStateUpdateErr func(error)
+	metrics          Metrics
+	redactor         *contextRedactor
+	rateLimiter      *tokenBucket
+	resolverFallback ResolverFallback
+	stickyMode       StickyMode
+	tokenResults     *tokenResultCache
+	contextCache     *contextCache
+	nextFetch        atomic.Pointer[time.Time]
+	lastFetchErr     atomic.Pointer[error]
+	lastState        atomic.Value // stores *loadedState
+
+	// largeIntsAsStrings sends context integers beyond ±2^53 as strings, see encodeLargeInts
+	largeIntsAsStrings bool
+	largeIntWarned     warnedKeys // context keys already warned about losing precision
+}
+
+// loadedState is the state currently applied to the resolver
+type loadedState struct {
+	request  *proto.SetResolverStateRequest
+	hash     string
+	etag     string // as reported by the state provider, empty if unknown
+	loadedAt time.Time
 }
 
 // Compile-time interface conformance checks
 var (
 	_ openfeature.FeatureProvider = (*LocalResolverProvider)(nil)
 	_ openfeature.StateHandler    = (*LocalResolverProvider)(nil)
+	_ openfeature.EventHandler    = (*LocalResolverProvider)(nil)
 )
 
 // NewLocalResolverProvider creates a new LocalResolverProvider
@@ -58,14 +184,36 @@ func NewLocalResolverProvider(
 		}))
 	}
 
-	return &LocalResolverProvider{
+	p := &LocalResolverProvider{
 		resolverSupplier: resolverSupplier,
 		stateProvider:    stateProvider,
 		flagLogger:       flagLogger,
 		clientSecret:     clientSecret,
-		logger:           logger,
+		sdk:              defaultSdk,
 		pollInterval:     getPollIntervalSeconds(),
+		flushInterval:    defaultAssignFlushInterval,
+		pollJitter:       defaultPollJitter,
+		events:           make(chan openfeature.Event, 5),
+		ready:            make(chan struct{}),
+		tokenResults:     newTokenResultCache(tokenResultTTL, maxTokenResults),
+		contextCache:     newContextCache(defaultContextCacheSize),
+	}
+	p.logger.Store(logger)
+	return p
+}
+
+// SetLogger replaces the provider's logger, e.g. for frameworks that configure logging
+// after the provider is created. Background tasks pick up the new logger immediately.
+// A nil logger is ignored. Loggers passed to the state provider and flag logger are not affected.
+func (p *LocalResolverProvider) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		return
 	}
+	p.logger.Store(logger)
+}
+
+func (p *LocalResolverProvider) log() *slog.Logger {
+	return p.logger.Load()
 }
 
 // Metadata returns the provider metadata
@@ -92,6 +240,7 @@ func (p *LocalResolverProvider) BooleanEvaluation(
 			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
 				Reason:          result.Reason,
 				ResolutionError: result.ResolutionError,
+				Variant:         result.Variant,
 			},
 		}
 	} else if boolVal, ok := result.Value.(bool); !ok {
@@ -100,6 +249,7 @@ func (p *LocalResolverProvider) BooleanEvaluation(
 			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
 				Reason:          openfeature.ErrorReason,
 				ResolutionError: openfeature.NewTypeMismatchResolutionError("value is not a boolean"),
+				Variant:         result.Variant,
 			},
 		}
 	} else {
@@ -130,6 +280,7 @@ func (p *LocalResolverProvider) StringEvaluation(
 			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
 				Reason:          result.Reason,
 				ResolutionError: result.ResolutionError,
+				Variant:         result.Variant,
 			},
 		}
 	} else if strVal, ok := result.Value.(string); !ok {
@@ -138,6 +289,7 @@ func (p *LocalResolverProvider) StringEvaluation(
 			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
 				Reason:          openfeature.ErrorReason,
 				ResolutionError: openfeature.NewTypeMismatchResolutionError("value is not a string"),
+				Variant:         result.Variant,
 			},
 		}
 	} else {
@@ -168,6 +320,7 @@ func (p *LocalResolverProvider) FloatEvaluation(
 			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
 				Reason:          result.Reason,
 				ResolutionError: result.ResolutionError,
+				Variant:         result.Variant,
 			},
 		}
 	} else if floatVal, ok := result.Value.(float64); !ok {
@@ -176,6 +329,7 @@ func (p *LocalResolverProvider) FloatEvaluation(
 			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
 				Reason:          openfeature.ErrorReason,
 				ResolutionError: openfeature.NewTypeMismatchResolutionError("value is not a float"),
+				Variant:         result.Variant,
 			},
 		}
 	} else {
@@ -206,6 +360,7 @@ func (p *LocalResolverProvider) IntEvaluation(
 			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
 				Reason:          result.Reason,
 				ResolutionError: result.ResolutionError,
+				Variant:         result.Variant,
 			},
 		}
 	} else {
@@ -227,6 +382,7 @@ func (p *LocalResolverProvider) IntEvaluation(
 				ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
 					Reason:          openfeature.ErrorReason,
 					ResolutionError: openfeature.NewTypeMismatchResolutionError("value is not an integer"),
+					Variant:         result.Variant,
 				},
 			}
 		}
@@ -243,26 +399,62 @@ func (p *LocalResolverProvider) ObjectEvaluation(
 	defaultValue interface{},
 	evalCtx openfeature.FlattenedContext,
 ) openfeature.InterfaceResolutionDetail {
-	// TODO this needs better proper handling, thread safety etc.
-	if p.resolver == nil {
-		return openfeature.InterfaceResolutionDetail{
-			Value: defaultValue,
-			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
-				Reason:          openfeature.ErrorReason,
-				ResolutionError: openfeature.NewProviderNotReadyResolutionError("provider not initialized"),
-			},
-		}
-	}
-	// Parse flag path (supports "flag.path.to.value" syntax)
-	flagPath, path := parseFlagPath(flag)
+	return p.evaluate(ctx, flag, defaultValue, evalCtx, true)
+}
+
+// PreviewEvaluation evaluates a flag like ObjectEvaluation without applying it, so no
+// exposure is logged. Use it for dry runs, admin UIs and tests. Flags with sticky rules
+// are resolved without recording any materialization.
+func (p *LocalResolverProvider) PreviewEvaluation(
+	ctx context.Context,
+	flag string,
+	defaultValue interface{},
+	evalCtx openfeature.FlattenedContext,
+) openfeature.InterfaceResolutionDetail {
+	return p.evaluate(ctx, flag, defaultValue, evalCtx, false)
+}
+
+// evaluate converts the context, resolves the flag and reports the result to metrics and
+// the debug log
+func (p *LocalResolverProvider) evaluate(
+	ctx context.Context,
+	flag string,
+	defaultValue interface{},
+	evalCtx openfeature.FlattenedContext,
+	apply bool,
+) openfeature.InterfaceResolutionDetail {
+	var result openfeature.InterfaceResolutionDetail
+	p.measureResolve(func() openfeature.Reason {
+		result = p.objectEvaluation(ctx, flag, defaultValue, evalCtx, apply)
+		return result.Reason
+	})
+	p.logResolveDecision(ctx, flag, evalCtx, result.ProviderResolutionDetail)
+	return result
+}
 
-	// Process targeting key (convert "targetingKey" to "targeting_key")
-	processedCtx := processTargetingKey(evalCtx)
+// measureResolve runs resolve and reports the reason it returns and its latency to metrics.
+// Every public resolve path goes through it, so each call is counted once.
+func (p *LocalResolverProvider) measureResolve(resolve func() openfeature.Reason) {
+	if p.metrics == nil {
+		resolve()
+		return
+	}
+	start := time.Now()
+	reason := resolve()
+	p.metrics.ResolveCompleted(reason, time.Since(start))
+}
 
-	// Convert evaluation context to protobuf Struct
-	protoCtx, err := flattenedContextToProto(processedCtx)
+func (p *LocalResolverProvider) objectEvaluation(
+	ctx context.Context,
+	flag string,
+	defaultValue interface{},
+	evalCtx openfeature.FlattenedContext,
+	apply bool,
+) openfeature.InterfaceResolutionDetail {
+	// Convert evaluation context to protobuf Struct (converting "targetingKey" to "targeting_key")
+	protoCtx, err := p.contextToProto(evalCtx)
 	if err != nil {
-		p.logger.Error("Failed to convert evaluation context to proto", "error", err)
+		p.log().Error("Failed to convert evaluation context to proto", "error", err)
 		return openfeature.InterfaceResolutionDetail{
 			Value: defaultValue,
 			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
@@ -272,88 +464,128 @@ func (p *LocalResolverProvider) ObjectEvaluation(
 		}
 	}
 
-	// Build resolve request
-	requestFlagName := "flags/" + flagPath
-	request := &resolver.ResolveFlagsRequest{
-		Flags:             []string{requestFlagName},
-		Apply:             true,
-		ClientSecret:      p.clientSecret,
-		EvaluationContext: protoCtx,
-		Sdk: &resolvertypes.Sdk{
-			Sdk: &resolvertypes.Sdk_Id{
-				Id: resolvertypes.SdkId_SDK_ID_GO_LOCAL_PROVIDER,
-			},
-			Version: Version,
-		},
-	}
+	return p.resolve(ctx, flag, defaultValue, protoCtx, apply)
+}
 
-	// Create ResolveWithSticky request
-	stickyRequest := &resolver.ResolveWithStickyRequest{
-		ResolveRequest:          request,
-		MaterializationsPerUnit: make(map[string]*resolver.MaterializationMap),
-		FailFastOnSticky:        true,
-		NotProcessSticky:        false,
-	}
+// ResolveRaw resolves and applies a flag and returns the resolver's ResolvedFlag as is,
+// with the untouched protobuf Value, Variant, Reason and FlagSchema. The schema tells
+// integer and double properties apart, which the interface{} conversion loses.
+// flag is the flag name without a value path. Errors are openfeature.ResolutionError values.
+func (p *LocalResolverProvider) ResolveRaw(
+	ctx context.Context,
+	flag string,
+	evalCtx openfeature.FlattenedContext,
+) (resolvedFlag *resolver.ResolvedFlag, err error) {
+	p.measureResolve(func() openfeature.Reason {
+		protoCtx, convErr := p.contextToProto(evalCtx)
+		if convErr != nil {
+			err = openfeature.NewGeneralResolutionError(fmt.Sprintf("failed to convert context: %v", convErr))
+			return openfeature.ErrorReason
+		}
+		var failure openfeature.ProviderResolutionDetail
+		resolvedFlag, failure = p.resolveFlag(ctx, flag, protoCtx, true)
+		if resolvedFlag == nil {
+			err = failure.ResolutionError
+			return failure.Reason
+		}
+		return mapResolveReasonToOpenFeature(resolvedFlag.Reason)
+	})
+	return resolvedFlag, err
+}
 
-	// Resolve flags with sticky support
-	stickyResponse, err := p.resolver.ResolveWithSticky(stickyRequest)
-	if err != nil {
-		p.logger.Error("Failed to resolve flag", "flag", flagPath, "error", err)
-		return openfeature.InterfaceResolutionDetail{
-			Value: defaultValue,
-			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
-				Reason:          openfeature.ErrorReason,
-				ResolutionError: openfeature.NewGeneralResolutionError(fmt.Sprintf("resolve failed: %v", err)),
-			},
+// Resolve resolves several flags in one call and returns the resolver's response as is,
+// with variants, full values, reasons and schemas for every flag. flags are flag names,
+// with or without the "flags/" prefix and without a value path; an empty list resolves
+// every flag the client has access to. apply controls whether
+// the resolved flags are applied, i.e. logged as exposed. Errors are
+// openfeature.ResolutionError values.
+func (p *LocalResolverProvider) Resolve(
+	ctx context.Context,
+	flags []string,
+	evalCtx openfeature.FlattenedContext,
+	apply bool,
+) (response *resolver.ResolveFlagsResponse, err error) {
+	// The flags of one call resolve for different reasons, so a successful call is reported
+	// to metrics with UnknownReason
+	p.measureResolve(func() openfeature.Reason {
+		protoCtx, convErr := p.contextToProto(evalCtx)
+		if convErr != nil {
+			err = openfeature.NewGeneralResolutionError(fmt.Sprintf("failed to convert context: %v", convErr))
+			return openfeature.ErrorReason
 		}
-	}
 
-	// Extract the actual resolve response from the sticky response
-	var response *resolver.ResolveFlagsResponse
-	switch result := stickyResponse.ResolveResult.(type) {
-	case *resolver.ResolveWithStickyResponse_Success_:
-		response = result.Success.Response
-	case *resolver.ResolveWithStickyResponse_MissingMaterializations_:
-		p.logger.Error("Missing materializations for flag", "flag", flagPath)
-		return openfeature.InterfaceResolutionDetail{
-			Value: defaultValue,
-			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
-				Reason:          openfeature.ErrorReason,
-				ResolutionError: openfeature.NewGeneralResolutionError("missing materializations"),
-			},
+		names := make([]string, len(flags))
+		for i, flag := range flags {
+			if !strings.HasPrefix(flag, "flags/") {
+				flag = "flags/" + flag
+			}
+			names[i] = flag
 		}
-	default:
-		p.logger.Error("Unexpected resolve result type for flag", "flag", flagPath)
+		var failure openfeature.ProviderResolutionDetail
+		response, failure = p.resolveFlags(ctx, names, protoCtx, apply)
+		if response == nil {
+			err = failure.ResolutionError
+			return failure.Reason
+		}
+		return openfeature.UnknownReason
+	})
+	return response, err
+}
+
+// resolveWithProtoContext resolves and applies a flag against an already converted evaluation
+// context and reports the result to metrics
+func (p *LocalResolverProvider) resolveWithProtoContext(
+	ctx context.Context,
+	flag string,
+	defaultValue interface{},
+	protoCtx *structpb.Struct,
+) openfeature.InterfaceResolutionDetail {
+	var result openfeature.InterfaceResolutionDetail
+	p.measureResolve(func() openfeature.Reason {
+		result = p.resolve(ctx, flag, defaultValue, protoCtx, true)
+		return result.Reason
+	})
+	return result
+}
+
+// resolve resolves a flag against an already converted evaluation context.
+// When apply is false the resolve is not logged as an exposure.
+func (p *LocalResolverProvider) resolve(
+	ctx context.Context,
+	flag string,
+	defaultValue interface{},
+	protoCtx *structpb.Struct,
+	apply bool,
+) openfeature.InterfaceResolutionDetail {
+	// Parse flag path (supports "flag.path.to.value" syntax)
+	flagPath, path := parseFlagPath(flag)
+
+	resolvedFlag, failure := p.resolveFlag(ctx, flagPath, protoCtx, apply)
+	if resolvedFlag == nil {
 		return openfeature.InterfaceResolutionDetail{
-			Value: defaultValue,
-			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
-				Reason:          openfeature.ErrorReason,
-				ResolutionError: openfeature.NewGeneralResolutionError("unexpected resolve result"),
-			},
+			Value:                    defaultValue,
+			ProviderResolutionDetail: failure,
 		}
 	}
 
-	// Check if flag was found
-	if len(response.ResolvedFlags) == 0 {
+	if resolvedFlag.Reason == resolvertypes.ResolveReason_RESOLVE_REASON_FLAG_ARCHIVED && p.archivedFlagMode == ArchivedFlagError {
 		return openfeature.InterfaceResolutionDetail{
 			Value: defaultValue,
 			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
 				Reason:          openfeature.ErrorReason,
-				ResolutionError: openfeature.NewFlagNotFoundResolutionError(fmt.Sprintf("flag '%s' not found", flagPath)),
+				ResolutionError: openfeature.NewGeneralResolutionError(fmt.Sprintf("flag '%s' is archived", flagPath)),
 			},
 		}
 	}
 
-	resolvedFlag := response.ResolvedFlags[0]
-
-	// Verify flag name matches
-	if resolvedFlag.Flag != requestFlagName {
-		p.logger.Error("Unexpected flag from resolver", "expected", requestFlagName, "got", resolvedFlag.Flag)
+	// The resolver only reports a targeting key error for a key of the wrong type, a missing
+	// key is resolved as not matching
+	if resolvedFlag.Reason == resolvertypes.ResolveReason_RESOLVE_REASON_TARGETING_KEY_ERROR {
 		return openfeature.InterfaceResolutionDetail{
 			Value: defaultValue,
 			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
 				Reason:          openfeature.ErrorReason,
-				ResolutionError: openfeature.NewFlagNotFoundResolutionError("unexpected flag returned"),
+				ResolutionError: openfeature.NewInvalidContextResolutionError(fmt.Sprintf("invalid targeting key for flag '%s', expected a string or an integer", flagPath)),
 			},
 		}
 	}
@@ -403,9 +635,465 @@ func (p *LocalResolverProvider) ObjectEvaluation(
 	}
 }
 
-// Hooks returns provider hooks (none for this implementation)
+// resolveFlag resolves a single flag, given without the "flags/" prefix. On failure the
+// returned flag is nil and the detail carries the reason and resolution error.
+func (p *LocalResolverProvider) resolveFlag(
+	ctx context.Context,
+	flagPath string,
+	protoCtx *structpb.Struct,
+	apply bool,
+) (*resolver.ResolvedFlag, openfeature.ProviderResolutionDetail) {
+	requestFlagName := "flags/" + flagPath
+	response, failure := p.resolveFlags(ctx, []string{requestFlagName}, protoCtx, apply)
+	if response == nil {
+		return nil, failure
+	}
+
+	// Check if flag was found
+	if len(response.ResolvedFlags) == 0 {
+		return nil, openfeature.ProviderResolutionDetail{
+			Reason:          openfeature.ErrorReason,
+			ResolutionError: openfeature.NewFlagNotFoundResolutionError(fmt.Sprintf("flag '%s' not found", flagPath)),
+		}
+	}
+
+	resolvedFlag := response.ResolvedFlags[0]
+
+	// Verify flag name matches
+	if resolvedFlag.Flag != requestFlagName {
+		p.log().Error("Unexpected flag from resolver", "expected", requestFlagName, "got", resolvedFlag.Flag)
+		return nil, openfeature.ProviderResolutionDetail{
+			Reason:          openfeature.ErrorReason,
+			ResolutionError: openfeature.NewFlagNotFoundResolutionError("unexpected flag returned"),
+		}
+	}
+	return resolvedFlag, openfeature.ProviderResolutionDetail{}
+}
+
+// resolveFlags resolves the given fully qualified flag names. On failure the response is nil
+// and the returned detail carries the reason and error.
+func (p *LocalResolverProvider) resolveFlags(
+	ctx context.Context,
+	flags []string,
+	protoCtx *structpb.Struct,
+	apply bool,
+) (*resolver.ResolveFlagsResponse, openfeature.ProviderResolutionDetail) {
+	if !p.allowResolve(ctx) {
+		return nil, openfeature.ProviderResolutionDetail{
+			Reason:          RateLimitedReason,
+			ResolutionError: openfeature.NewGeneralResolutionError("resolve rate limit exceeded"),
+		}
+	}
+
+	localResolver := p.getResolver()
+	if localResolver == nil {
+		return nil, openfeature.ProviderResolutionDetail{
+			Reason:          openfeature.ErrorReason,
+			ResolutionError: ErrProviderNotReady,
+		}
+	}
+	// Build resolve request
+	request := &resolver.ResolveFlagsRequest{
+		Flags:             flags,
+		Apply:             apply,
+		ClientSecret:      p.clientSecretFor(ctx),
+		EvaluationContext: protoCtx,
+		Sdk:               p.sdk,
+	}
+
+	// Create ResolveWithSticky request
+	stickyRequest := &resolver.ResolveWithStickyRequest{
+		ResolveRequest:          request,
+		MaterializationsPerUnit: make(map[string]*resolver.MaterializationMap),
+		// Skipping sticky rules needs the missing materializations to tell which flags to skip
+		FailFastOnSticky: p.stickyMode != StickySkip,
+		NotProcessSticky: false,
+	}
+
+	// Resolve flags with sticky support
+	stickyResponse, localResolver, err := p.resolveWithSticky(ctx, localResolver, stickyRequest)
+	if err != nil {
+		p.log().Error("Failed to resolve flags", "flags", flags, "error", err)
+		return nil, openfeature.ProviderResolutionDetail{
+			Reason:          openfeature.ErrorReason,
+			ResolutionError: resolveError(err),
+		}
+	}
+
+	// Extract the actual resolve response from the sticky response
+	switch result := stickyResponse.ResolveResult.(type) {
+	case *resolver.ResolveWithStickyResponse_Success_:
+		return result.Success.Response, openfeature.ProviderResolutionDetail{}
+	case *resolver.ResolveWithStickyResponse_MissingMaterializations_:
+		if p.stickyMode == StickySkip {
+			return p.resolveSkippingSticky(ctx, localResolver, request, result.MissingMaterializations.GetItems())
+		}
+		if p.resolverFallback != nil {
+			return p.resolveWithFallback(ctx, request)
+		}
+		p.log().Error("Missing materializations for flags", "flags", flags)
+		return nil, openfeature.ProviderResolutionDetail{
+			Reason:          openfeature.ErrorReason,
+			ResolutionError: openfeature.NewGeneralResolutionError("missing materializations"),
+		}
+	default:
+		p.log().Error("Unexpected resolve result type for flags", "flags", flags)
+		return nil, openfeature.ProviderResolutionDetail{
+			Reason:          openfeature.ErrorReason,
+			ResolutionError: openfeature.NewGeneralResolutionError("unexpected resolve result"),
+		}
+	}
+}
+
+// resolveWithSticky resolves on localResolver. When a re-Init or UpdateWasm closed it during
+// the call, the request is retried on the resolver that replaced it, which is returned.
+func (p *LocalResolverProvider) resolveWithSticky(
+	ctx context.Context,
+	localResolver lr.LocalResolver,
+	request *resolver.ResolveWithStickyRequest,
+) (*resolver.ResolveWithStickyResponse, lr.LocalResolver, error) {
+	for {
+		response, err := localResolver.ResolveWithSticky(ctx, request)
+		if !errors.Is(err, lr.ErrInstanceClosed) {
+			return response, localResolver, err
+		}
+		current := p.getResolver()
+		if current == nil || current == localResolver {
+			return response, localResolver, err
+		}
+		localResolver = current
+	}
+}
+
+// resolveWithFallback resolves the request through the configured ResolverFallback
+func (p *LocalResolverProvider) resolveWithFallback(
+	ctx context.Context,
+	request *resolver.ResolveFlagsRequest,
+) (*resolver.ResolveFlagsResponse, openfeature.ProviderResolutionDetail) {
+	response, err := p.resolverFallback.ResolveFlags(ctx, request)
+	if err != nil {
+		p.log().Error("Fallback resolve failed for flags", "flags", request.Flags, "error", err)
+		return nil, openfeature.ProviderResolutionDetail{
+			Reason:          openfeature.ErrorReason,
+			ResolutionError: openfeature.NewGeneralResolutionError(err.Error()),
+		}
+	}
+	return response, openfeature.ProviderResolutionDetail{}
+}
+
+// resolveSkippingSticky resolves the request locally again without processing sticky rules,
+// reporting the flags of the missing materializations as not matching
+func (p *LocalResolverProvider) resolveSkippingSticky(
+	ctx context.Context,
+	localResolver lr.LocalResolver,
+	request *resolver.ResolveFlagsRequest,
+	missing []*resolver.ResolveWithStickyResponse_MissingMaterializationItem,
+) (*resolver.ResolveFlagsResponse, openfeature.ProviderResolutionDetail) {
+	stickyResponse, _, err := p.resolveWithSticky(ctx, localResolver, &resolver.ResolveWithStickyRequest{
+		ResolveRequest:          request,
+		MaterializationsPerUnit: make(map[string]*resolver.MaterializationMap),
+		NotProcessSticky:        true,
+	})
+	if err != nil {
+		p.log().Error("Failed to resolve flags without sticky rules", "flags", request.Flags, "error", err)
+		return nil, openfeature.ProviderResolutionDetail{
+			Reason:          openfeature.ErrorReason,
+			ResolutionError: resolveError(err),
+		}
+	}
+	success, ok := stickyResponse.ResolveResult.(*resolver.ResolveWithStickyResponse_Success_)
+	if !ok {
+		p.log().Error("Unexpected resolve result type for flags", "flags", request.Flags)
+		return nil, openfeature.ProviderResolutionDetail{
+			Reason:          openfeature.ErrorReason,
+			ResolutionError: openfeature.NewGeneralResolutionError("unexpected resolve result"),
+		}
+	}
+	response := success.Success.GetResponse()
+	if response == nil {
+		response = &resolver.ResolveFlagsResponse{}
+	}
+	for _, flag := range skippedStickyFlags(missing, response) {
+		response.ResolvedFlags = append(response.ResolvedFlags, &resolver.ResolvedFlag{
+			Flag:   flag,
+			Reason: resolvertypes.ResolveReason_RESOLVE_REASON_NO_SEGMENT_MATCH,
+		})
+	}
+	return response, openfeature.ProviderResolutionDetail{}
+}
+
+// skippedStickyFlags returns the flags with a missing materialization that the response
+// lacks, i.e. flags skipped because their sticky rules couldn't be evaluated. Other flags
+// missing from the response weren't found.
+func skippedStickyFlags(missing []*resolver.ResolveWithStickyResponse_MissingMaterializationItem, response *resolver.ResolveFlagsResponse) []string {
+	done := make(map[string]bool, len(response.GetResolvedFlags()))
+	for _, flag := range response.GetResolvedFlags() {
+		done[flag.Flag] = true
+	}
+	var skipped []string
+	for _, item := range missing {
+		// Rule names are scoped to their flag, e.g. "flags/my-flag/rules/my-rule"
+		flag, _, ok := strings.Cut(item.Rule, "/rules/")
+		if !ok || done[flag] {
+			continue
+		}
+		done[flag] = true
+		skipped = append(skipped, flag)
+	}
+	return skipped
+}
+
+// Ready returns a channel that is closed once Init has applied the initial state to the
+// resolver. It stays closed after later reinitializations and Shutdown.
+func (p *LocalResolverProvider) Ready() <-chan struct{} {
+	return p.ready
+}
+
+// WaitUntilReady blocks until Init has applied the initial state or ctx is done, in which
+// case the context's error is returned.
+func (p *LocalResolverProvider) WaitUntilReady(ctx context.Context) error {
+	select {
+	case <-p.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Clock supplies the current time to the resolver guest
+type Clock = lr.Clock
+
+// now returns the current time as the resolver guest sees it
+func (p *LocalResolverProvider) now() time.Time {
+	if p.clock != nil {
+		return p.clock.Now()
+	}
+	return time.Now()
+}
+
+// MemoryStats describes the WASM memory used by the resolver guest instances
+type MemoryStats = lr.MemoryStats
+
+// MemoryStats returns the memory size and high-water mark summed over the resolver's guest
+// instances, e.g. to alert before a large state hits the guest's allocation ceiling.
+// Returns zero stats before Init or when the resolver doesn't report memory usage.
+func (p *LocalResolverProvider) MemoryStats() MemoryStats {
+	if reporter, ok := p.getResolver().(lr.MemoryStatsReporter); ok {
+		return reporter.MemoryStats()
+	}
+	return MemoryStats{}
+}
+
+// AccountID returns the account ID of the state currently loaded into the resolver.
+// Unlike FlagsAdminStateFetcher.GetAccountID, this only reflects state that was
+// successfully applied. Returns an empty string before the first successful load.
+func (p *LocalResolverProvider) AccountID() string {
+	if state := p.getLastState(); state != nil {
+		return state.request.AccountId
+	}
+	return ""
+}
+
+// StateHash returns a hash of the state currently loaded into the resolver,
+// see HashResolverState. Returns an empty string before the first successful load.
+func (p *LocalResolverProvider) StateHash() string {
+	if state := p.getLastState(); state != nil {
+		return state.hash
+	}
+	return ""
+}
+
+// IsStateStale reports whether the resolver is serving stale state, i.e. the last
+// successful state reload is older than ProviderConfig.StaleThreshold. Always false
+// when no threshold is configured or before the first successful load.
+func (p *LocalResolverProvider) IsStateStale() bool {
+	state := p.getLastState()
+	if p.staleThreshold <= 0 || state == nil {
+		return false
+	}
+	return time.Since(state.loadedAt) > p.staleThreshold
+}
+
+// StateAge returns the time since the resolver state was last successfully reloaded,
+// whether or not it changed. Zero before the first successful load.
+func (p *LocalResolverProvider) StateAge() time.Duration {
+	state := p.getLastState()
+	if state == nil {
+		return 0
+	}
+	return time.Since(state.loadedAt)
+}
+
+// EventChannel returns the channel on which provider events are emitted (part of EventHandler interface)
+func (p *LocalResolverProvider) EventChannel() <-chan openfeature.Event {
+	return p.events
+}
+
+// checkStaleness emits PROVIDER_STALE when the state turns stale and PROVIDER_READY when it recovers
+func (p *LocalResolverProvider) checkStaleness() {
+	stale := p.IsStateStale()
+	if p.stale.Swap(stale) == stale {
+		return
+	}
+	if stale {
+		p.log().Warn("Resolver state is stale", "threshold", p.staleThreshold, "age", p.StateAge())
+		p.emit(openfeature.ProviderStale, "resolver state has not been reloaded within the stale threshold")
+	} else {
+		p.log().Info("Resolver state is fresh again")
+		p.emit(openfeature.ProviderReady, "resolver state reloaded")
+	}
+}
+
+// emit sends an event without blocking, dropping it if nobody is consuming events
+func (p *LocalResolverProvider) emit(eventType openfeature.EventType, message string) {
+	event := openfeature.Event{
+		ProviderName:         p.Metadata().Name,
+		EventType:            eventType,
+		ProviderEventDetails: openfeature.ProviderEventDetails{Message: message},
+	}
+	select {
+	case p.events <- event:
+	default:
+		p.log().Debug("Dropped provider event", "type", eventType)
+	}
+}
+
+// FlagKeys returns the keys of the flags in the state currently loaded into the resolver,
+// sorted and without the "flags/" prefix, without resolving them. The state is parsed on
+// every call, so this is meant for admin and debug use rather than the evaluation path.
+func (p *LocalResolverProvider) FlagKeys() ([]string, error) {
+	state := p.getLastState()
+	if state == nil {
+		return nil, fmt.Errorf("provider not initialized")
+	}
+	return stateFlagKeys(state.request.State)
+}
+
+// CurrentETag returns the ETag of the state currently loaded into the resolver, e.g. to
+// check which version a reload picked up. Empty before the first successful load, when the
+// state provider doesn't report ETags, or after ApplyStateDelta.
+func (p *LocalResolverProvider) CurrentETag() string {
+	if state := p.getLastState(); state != nil {
+		return state.etag
+	}
+	return ""
+}
+
+// Reload fetches the latest state and loads it into the resolver right away instead of
+// waiting for the next background poll, e.g. when a webhook reports a change. Reloads and
+// background polls never run at the same time. Like polls, reloads are reported to
+// OnStateUpdate, OnStateUpdateError and Metrics.
+func (p *LocalResolverProvider) Reload(ctx context.Context) error {
+	if p.getLastState() == nil {
+		return fmt.Errorf("provider not initialized")
+	}
+	err := p.pollState(ctx)
+	p.checkStaleness()
+	return err
+}
+
+// UpdateWasm replaces the resolver guest at runtime without a restart.
+// The new guest is compiled, loaded with the current state and probed with a
+// resolve before it is swapped in. On any failure the current guest is kept.
+func (p *LocalResolverProvider) UpdateWasm(ctx context.Context, wasm []byte) error {
+	p.swapMu.Lock()
+	defer p.swapMu.Unlock()
+
+	state := p.getLastState()
+	if state == nil || p.flagLogger == nil {
+		return fmt.Errorf("provider not initialized")
+	}
+
+	compiled, err := lr.CompileWasmWithClock(ctx, wasm, p.clock)
+	if err != nil {
+		return err
+	}
+	newResolver := lr.NewLocalResolverWithOptions(lr.Options{Compiled: compiled, Instances: p.instances})(ctx, p.writeLogs)
+	if err := p.probeResolver(ctx, newResolver, state.request); err != nil {
+		newResolver.Close(ctx)
+		p.log().Error("Rejected WASM update, keeping current guest", "error", err)
+		return err
+	}
+
+	old := p.getResolver()
+	p.resolver.Store(newResolver)
+	if old != nil {
+		// Close flushes any logs still pending in the old guest, which a cancelled ctx
+		// must not cut short
+		if err := old.Close(context.WithoutCancel(ctx)); err != nil {
+			p.log().Warn("Failed to close previous resolver", "error", err)
+		}
+	}
+	p.log().Info("Swapped resolver WASM guest")
+	return nil
+}
+
+// probeResolver loads state into the resolver and verifies it can serve a resolve.
+func (p *LocalResolverProvider) probeResolver(ctx context.Context, r lr.LocalResolver, state *proto.SetResolverStateRequest) error {
+	if err := r.SetResolverState(ctx, state); err != nil {
+		return fmt.Errorf("failed to set state on new resolver: %w", err)
+	}
+	probe := &resolver.ResolveWithStickyRequest{
+		ResolveRequest: &resolver.ResolveFlagsRequest{
+			Apply:             false,
+			ClientSecret:      p.clientSecret,
+			EvaluationContext: &structpb.Struct{},
+		},
+		MaterializationsPerUnit: make(map[string]*resolver.MaterializationMap),
+		FailFastOnSticky:        true,
+	}
+	if _, err := r.ResolveWithSticky(ctx, probe); err != nil {
+		return fmt.Errorf("probe resolve failed: %w", err)
+	}
+	return nil
+}
+
+// writeLogs passes logs flushed from the resolver through the flush observer before writing them
+func (p *LocalResolverProvider) writeLogs(request *resolverv1.WriteFlagLogsRequest) {
+	// Redact before the observer so sensitive values never leave the resolver
+	if p.redactor != nil {
+		p.redactor.redactFlagLogs(request)
+	}
+	if p.flushObserver != nil {
+		p.flushObserver(summarizeFlagLogs(request), request)
+	}
+	p.flagLogger.Write(request)
+}
+
+func (p *LocalResolverProvider) getResolver() lr.LocalResolver {
+	if v := p.resolver.Load(); v != nil {
+		return v.(lr.LocalResolver)
+	}
+	return nil
+}
+
+func (p *LocalResolverProvider) getLastState() *loadedState {
+	if v := p.lastState.Load(); v != nil {
+		return v.(*loadedState)
+	}
+	return nil
+}
+
+// setLastState records the state applied to the resolver, rehashing only when it changed.
+// Cached resolve token results were resolved against the previous state and are dropped.
+func (p *LocalResolverProvider) setLastState(request *proto.SetResolverStateRequest, etag string) {
+	p.tokenResults.clear()
+	loaded := &loadedState{request: request, etag: etag, loadedAt: time.Now()}
+	if prev := p.getLastState(); prev != nil && bytes.Equal(prev.request.State, request.State) {
+		loaded.hash = prev.hash
+	} else {
+		loaded.hash = HashResolverState(request.State)
+	}
+	p.lastState.Store(loaded)
+}
+
+// Hooks returns the provider-level hooks configured through ProviderConfig.Hooks.
+// The OpenFeature SDK runs them around every evaluation served by this provider.
 func (p *LocalResolverProvider) Hooks() []openfeature.Hook {
-	return []openfeature.Hook{}
+	if p.hooks == nil {
+		return []openfeature.Hook{}
+	}
+	return p.hooks
 }
 
 // Init initializes the provider (part of StateHandler interface)
@@ -421,6 +1109,10 @@ func (p *LocalResolverProvider) Init(evaluationContext openfeature.EvaluationCon
 		}
 	}()
 
+	// Serialize with Shutdown and repeated Init calls
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	// Check if required components are present
 	if p.stateProvider == nil {
 		return fmt.Errorf("state provider is nil, cannot initialize")
@@ -433,131 +1125,203 @@ func (p *LocalResolverProvider) Init(evaluationContext openfeature.EvaluationCon
 	if p.flagLogger == nil {
 		return fmt.Errorf("Flag logger is nil,  cannot initialize")
 	}
-	logSink := p.flagLogger.Write
-
-	p.resolver = p.resolverSupplier(ctx, logSink)
-
 	// Fetch initial state and accountID from StateProvider
-	initialState, accountId, err := p.stateProvider.Provide(ctx)
+	initialState, accountId, err := p.provideInitialState(ctx)
 	if err != nil {
-		p.logger.Error("Failed to fetch initial state", "error", err)
+		p.log().Error("Failed to fetch initial state", "error", err)
 		return fmt.Errorf("failed to fetch initial state: %w", err)
 	}
+	etag := p.stateETag()
 
 	if accountId == "" {
-		p.logger.Error("AccountID is empty in the fetched state, this should not happen")
+		p.log().Error("AccountID is empty in the fetched state, this should not happen")
 		return fmt.Errorf("AccountID is empty in the initial state")
 	}
 
-	// Update resolver with initial state (triggers WASM compilation and initialization)
+	if p.requireFlags {
+		flagCount, err := countStateFlags(initialState)
+		if err != nil {
+			p.log().Error("Failed to parse initial state", "error", err)
+			return fmt.Errorf("failed to parse initial state: %w", err)
+		}
+		if flagCount == 0 {
+			p.log().Error("Initial state contains no flags", "account", accountId)
+			return fmt.Errorf("initial state for account %s contains no flags", accountId)
+		}
+	}
+
+	// Create the resolver only once the state is known to be usable, so a failed fetch
+	// doesn't leave a compiled guest behind, then load it with the initial state
+	localResolver := p.resolverSupplier(ctx, p.writeLogs)
 	setResolverStateRequest := &proto.SetResolverStateRequest{
 		State:     initialState,
 		AccountId: accountId,
 	}
-	if err := p.resolver.SetResolverState(setResolverStateRequest); err != nil {
-		p.logger.Error("Failed to initialize resolver with initial state", "error", err)
+	if err := localResolver.SetResolverState(ctx, setResolverStateRequest); err != nil {
+		localResolver.Close(ctx)
+		p.log().Error("Failed to initialize resolver with initial state", "error", err)
 		return fmt.Errorf("failed to initialize resolver: %w", err)
 	}
 
+	// A repeated Init reinitializes the provider: the previous background tasks are stopped
+	// before the new resolver takes over, and the previous resolver is closed to flush its logs
+	if p.stopScheduledTasks() {
+		p.log().Info("Provider already initialized, reinitializing")
+	}
+	p.swapMu.Lock()
+	previous := p.getResolver()
+	p.setLastState(setResolverStateRequest, etag)
+	p.resolver.Store(localResolver)
+	p.swapMu.Unlock()
+	if previous != nil {
+		previous.Close(ctx)
+	}
+	p.readyOnce.Do(func() { close(p.ready) })
+	if p.onStateUpdate != nil {
+		p.onStateUpdate(accountId, len(initialState), true)
+	}
+
 	// Start background tasks for state updates and log flushing
 	p.startScheduledTasks(ctx)
 
-	p.logger.Info("Provider initialized successfully")
+	// Resend flag logs a previous run failed to send, see ProviderConfig.FlagLogSpoolDir
+	if spooling, ok := p.flagLogger.(interface{ ReplaySpool() }); ok {
+		spooling.ReplaySpool()
+	}
+
+	p.log().Info("Provider initialized successfully")
 	return nil
 }
 
-// Shutdown closes the provider and cleans up resources (part of StateHandler interface)
+// Shutdown closes the provider and cleans up resources (part of StateHandler interface).
+// Shutdown happens in a fixed order so that no exposures are lost:
+//  1. background tasks are cancelled and awaited, so nothing flushes concurrently
+//  2. the resolver is closed, which hands all remaining logs to the flag logger
+//  3. the flag logger is shut down, which waits until every write has been sent
 func (p *LocalResolverProvider) Shutdown() {
 	ctx := context.Background()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if p.logger != nil {
-		p.logger.Info("Shutting down provider")
+	p.log().Info("Shutting down provider")
+
+	// Cancel background tasks and wait for them to exit
+	if p.stopScheduledTasks() {
+		p.log().Debug("Cancelled scheduled tasks")
 	}
 
-	// Cancel background tasks
-	if p.cancelFunc != nil {
-		p.cancelFunc()
-		p.cancelFunc = nil
-		if p.logger != nil {
-			p.logger.Debug("Cancelled scheduled tasks")
+	// Release the state provider's resources now that the poll loop no longer uses it
+	if closer, ok := p.stateProvider.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			p.log().Error("Failed to close state provider", "error", err)
 		}
 	}
 
-	// Wait for background goroutines to exit
-	p.wg.Wait()
-
-	// ctx := context.Background()
+	// Close resolver API (which flushes final logs synchronously into the flag logger)
+	if localResolver := p.getResolver(); localResolver != nil {
+		localResolver.Close(ctx)
+		p.log().Debug("Closed resolver API")
+	}
 
-	// Close resolver API (which flushes final logs)
-	if p.resolver != nil {
-		p.resolver.Close(ctx)
-		if p.logger != nil {
-			p.logger.Debug("Closed resolver API")
-		}
+	if p.contextCache != nil {
+		p.contextCache.purge()
 	}
+	p.tokenResults.clear()
 
-	// Shutdown flag logger (which waits for log sends to complete)
+	// Shutdown flag logger last (which waits for log sends to complete, including the final flush)
 	if p.flagLogger != nil {
 		p.flagLogger.Shutdown()
-		if p.logger != nil {
-			p.logger.Debug("Shut down flag logger")
+		p.log().Debug("Shut down flag logger")
+	}
+
+	p.log().Info("Provider has been shut down")
+}
+
+// provideInitialState fetches the initial state from the state provider. With an init timeout
+// the fetch is given a context with that deadline, and abandoned once it passes even if the
+// state provider doesn't honor the deadline.
+func (p *LocalResolverProvider) provideInitialState(ctx context.Context) ([]byte, string, error) {
+	if p.initTimeout <= 0 {
+		return p.stateProvider.Provide(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.initTimeout)
+	defer cancel()
+
+	type provided struct {
+		state     []byte
+		accountId string
+		err       error
+	}
+	done := make(chan provided, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- provided{err: fmt.Errorf("state provider panicked: %v", r)}
+			}
+		}()
+		state, accountId, err := p.stateProvider.Provide(ctx)
+		done <- provided{state, accountId, err}
+	}()
+
+	select {
+	case result := <-done:
+		if result.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, "", fmt.Errorf("%w after %v: %w", ErrInitTimeout, p.initTimeout, result.err)
 		}
+		return result.state, result.accountId, result.err
+	case <-ctx.Done():
+		return nil, "", fmt.Errorf("%w after %v: %w", ErrInitTimeout, p.initTimeout, ctx.Err())
 	}
+}
 
-	if p.logger != nil {
-		p.logger.Info("Provider has been shut down")
+// stopScheduledTasks cancels the background tasks and waits for them to exit.
+// Returns false if no tasks were running. Must be called with p.mu held.
+func (p *LocalResolverProvider) stopScheduledTasks() bool {
+	if p.cancelFunc == nil {
+		return false
 	}
+	p.cancelFunc()
+	p.cancelFunc = nil
+	p.wg.Wait()
+	return true
 }
 
-// startScheduledTasks starts the background tasks for state fetching and log polling
+// startScheduledTasks starts the background tasks for state fetching and log polling.
+// Must be called with p.mu held.
 func (p *LocalResolverProvider) startScheduledTasks(parentCtx context.Context) {
 	ctx, cancel := context.WithCancel(parentCtx)
-	p.mu.Lock()
 	p.cancelFunc = cancel
-	p.mu.Unlock()
 
 	// Ticker for state fetching and log flushing
+	pollTimer := time.NewTimer(p.scheduleNextPoll())
+
 	p.wg.Add(1)
 	go func() {
 		defer p.wg.Done()
-		ticker := time.NewTicker(p.pollInterval)
-		defer ticker.Stop()
-
-		assignTicker := time.NewTicker(100 * time.Millisecond)
-		defer assignTicker.Stop()
+		defer pollTimer.Stop()
+		defer p.nextFetch.Store(nil)
+
+		// A nil channel never fires, leaving assign logs to be flushed on state polls
+		var assignFlush <-chan time.Time
+		if p.flushInterval > 0 {
+			assignTicker := time.NewTicker(p.flushInterval)
+			defer assignTicker.Stop()
+			assignFlush = assignTicker.C
+		}
 
 		for {
 			select {
-			case <-ticker.C:
-				// Fetch latest state and accountID
-				state, accountId, err := p.stateProvider.Provide(ctx)
-				if err != nil {
-					p.logger.Error("State fetch failed", "error", err)
-					continue
-				}
-
-				if accountId == "" {
-					p.logger.Error("AccountID inside fetched state is empty, skipping this state update attempt")
-					continue
-				}
-				if err := p.resolver.FlushAllLogs(); err != nil {
-					p.logger.Error("Failed to flush all logs", "error", err)
-				}
-
-				// Update state and flush logs
-				setResolverStateRequest := &proto.SetResolverStateRequest{
-					State:     state,
-					AccountId: accountId,
-				}
-				if err := p.resolver.SetResolverState(setResolverStateRequest); err != nil {
-					p.logger.Error("Failed to update state and flush logs", "error", err)
-				}
-			case <-assignTicker.C:
-				if err := p.resolver.FlushAssignLogs(); err != nil {
-					p.logger.Error("Failed to flush assign logs", "error", err)
+			case <-pollTimer.C:
+				err := p.pollState(ctx)
+				p.lastFetchErr.Store(&err)
+				p.checkStaleness()
+				pollTimer.Reset(p.scheduleNextPoll())
+			case <-assignFlush:
+				// Not cancelled with the loop, interrupting a flush would drop its logs
+				if err := p.getResolver().FlushAssignLogs(context.WithoutCancel(ctx)); err != nil {
+					p.log().Error("Failed to flush assign logs", "error", err)
 				}
+				p.checkStaleness()
 			case <-ctx.Done():
 				return
 			}
@@ -565,6 +1329,125 @@ func (p *LocalResolverProvider) startScheduledTasks(parentCtx context.Context) {
 	}()
 }
 
+// pollState fetches the latest state and accountID and applies it to the resolver,
+// reporting the outcome to the state update callbacks
+func (p *LocalResolverProvider) pollState(ctx context.Context) error {
+	accountId, stateBytes, changed, err := p.fetchAndUpdateState(ctx)
+	if p.metrics != nil {
+		p.metrics.StateReloaded(err)
+	}
+	if err != nil {
+		if p.onStateUpdateErr != nil {
+			p.onStateUpdateErr(err)
+		}
+		return err
+	}
+	if p.onStateUpdate != nil {
+		p.onStateUpdate(accountId, stateBytes, changed)
+	}
+	return nil
+}
+
+// fetchAndUpdateState fetches the latest state and accountID and applies it to the resolver.
+// changed reports whether the content differs from the previously loaded state. reloadMu is
+// held throughout, so background polls and manual reloads never interleave. swapMu is only
+// taken to apply the fetched state, so state deltas and guest swaps don't wait on the fetch.
+func (p *LocalResolverProvider) fetchAndUpdateState(ctx context.Context) (accountId string, stateBytes int, changed bool, err error) {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	state, accountId, err := p.stateProvider.Provide(ctx)
+	if err != nil {
+		p.log().Error("State fetch failed", "error", err)
+		return "", 0, false, fmt.Errorf("state fetch failed: %w", err)
+	}
+	etag := p.stateETag()
+
+	p.swapMu.Lock()
+	defer p.swapMu.Unlock()
+
+	if accountId == "" {
+		p.log().Error("AccountID inside fetched state is empty, skipping this state update attempt")
+		return "", 0, false, fmt.Errorf("fetched state has an empty account ID")
+	}
+	if loaded := p.AccountID(); loaded != "" && accountId != loaded {
+		p.log().Error("AccountID inside fetched state changed, skipping this state update attempt",
+			"loaded", loaded, "fetched", accountId)
+		return "", 0, false, fmt.Errorf("%w: loaded %s, fetched %s", ErrAccountIDChanged, loaded, accountId)
+	}
+	previous := p.StateHash()
+	if err := p.updateStateLocked(ctx, state, accountId, etag); err != nil {
+		p.log().Error("Failed to update state and flush logs", "error", err)
+		return "", 0, false, fmt.Errorf("failed to update state: %w", err)
+	}
+	return accountId, len(state), p.StateHash() != previous, nil
+}
+
+// stateETag returns the ETag of the state most recently provided, for state providers
+// that expose one like FlagsAdminStateFetcher
+func (p *LocalResolverProvider) stateETag() string {
+	if etagger, ok := p.stateProvider.(interface{ ETag() string }); ok {
+		return etagger.ETag()
+	}
+	return ""
+}
+
+// scheduleNextPoll picks the delay until the next state poll and records when it will happen
+func (p *LocalResolverProvider) scheduleNextPoll() time.Duration {
+	delay := p.nextPollDelay()
+	next := time.Now().Add(delay)
+	p.nextFetch.Store(&next)
+	return delay
+}
+
+// NextStateFetch returns when the background poll loop will next fetch state.
+// Returns the zero time when the poll loop is not running.
+func (p *LocalResolverProvider) NextStateFetch() time.Time {
+	if next := p.nextFetch.Load(); next != nil {
+		return *next
+	}
+	return time.Time{}
+}
+
+// LastStateFetchError returns why the most recent background state poll failed,
+// or nil if it succeeded or no poll has run yet.
+func (p *LocalResolverProvider) LastStateFetchError() error {
+	if err := p.lastFetchErr.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// nextPollDelay returns the poll interval randomly spread by ±pollJitter so that
+// fleets of providers don't fetch state in lockstep
+func (p *LocalResolverProvider) nextPollDelay() time.Duration {
+	if p.pollJitter <= 0 {
+		return p.pollInterval
+	}
+	spread := (rand.Float64()*2 - 1) * p.pollJitter
+	return time.Duration(float64(p.pollInterval) * (1 + spread))
+}
+
+// updateStateLocked flushes pending logs and swaps the resolver to the given state.
+// Must be called with swapMu held.
+func (p *LocalResolverProvider) updateStateLocked(ctx context.Context, state []byte, accountId string, etag string) error {
+	localResolver := p.getResolver()
+	// Interrupting the flush would drop its logs, only the state load follows ctx
+	if err := localResolver.FlushAllLogs(context.WithoutCancel(ctx)); err != nil {
+		p.log().Error("Failed to flush all logs", "error", err)
+	}
+
+	setResolverStateRequest := &proto.SetResolverStateRequest{
+		State:     state,
+		AccountId: accountId,
+	}
+	if err := localResolver.SetResolverState(ctx, setResolverStateRequest); err != nil {
+		return err
+	}
+	p.setLastState(setResolverStateRequest, etag)
+	return nil
+}
+
 // getPollIntervalSeconds gets the poll interval from environment or returns default
 func getPollIntervalSeconds() time.Duration {
 	if envVal := os.Getenv("CONFIDENCE_RESOLVER_POLL_INTERVAL_SECONDS"); envVal != "" {
@@ -585,15 +1468,30 @@ func parseFlagPath(key string) (flagName string, path string) {
 	return parts[0], parts[1]
 }
 
-// processTargetingKey converts "targetingKey" to "targeting_key" in the context
+// processTargetingKey converts "targetingKey" to "targeting_key" in the context and in
+// nested contexts. An explicit "targeting_key" is kept and takes precedence over "targetingKey".
 func processTargetingKey(evalCtx openfeature.FlattenedContext) openfeature.FlattenedContext {
-	newEvalContext := make(openfeature.FlattenedContext)
+	return normalizeTargetingKey(evalCtx)
+}
+
+// normalizeTargetingKey returns a copy of the context with "targetingKey" renamed to
+// "targeting_key" at every level, leaving the input untouched
+func normalizeTargetingKey(evalCtx map[string]interface{}) map[string]interface{} {
+	newEvalContext := make(map[string]interface{}, len(evalCtx))
 	for k, v := range evalCtx {
+		switch nested := v.(type) {
+		case map[string]interface{}:
+			v = normalizeTargetingKey(nested)
+		case openfeature.FlattenedContext:
+			v = openfeature.FlattenedContext(normalizeTargetingKey(nested))
+		}
 		newEvalContext[k] = v
 	}
 
-	if targetingKey, exists := evalCtx["targetingKey"]; exists {
-		newEvalContext["targeting_key"] = targetingKey
+	if targetingKey, exists := newEvalContext["targetingKey"]; exists {
+		if _, explicit := newEvalContext["targeting_key"]; !explicit {
+			newEvalContext["targeting_key"] = targetingKey
+		}
 		delete(newEvalContext, "targetingKey")
 	}
 
@@ -651,6 +1549,13 @@ func goValueToProto(value interface{}) (*structpb.Value, error) {
 		}
 		return structpb.NewStructValue(&structpb.Struct{Fields: fields}), nil
 	default:
+		// Dereference pointers, e.g. optional fields of generated structs; nil pointers are null
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				return structpb.NewNullValue(), nil
+			}
+			return goValueToProto(rv.Elem().Interface())
+		}
 		return nil, fmt.Errorf("unsupported type: %T", v)
 	}
 }
@@ -733,8 +1638,31 @@ func (p *LocalResolverProvider) logResolutionErrorIfPresent(flag string, detail
 	errStr := detail.ResolutionError.Error()
 	// Empty ResolutionError returns ": ", so check for meaningful error
 	if errStr != "" && errStr != ": " {
-		p.logger.Warn("Flag evaluation error", "flag", flag, "error_code", errStr)
+		p.log().Warn("Flag evaluation error", "flag", flag, "error_code", errStr)
+	}
+}
+
+// logResolveDecision logs the variant and reason of a resolve at debug level. The value is
+// left out since it may hold personal data, and the targeting key is redacted like in flag
+// logs when it is a sensitive context key.
+func (p *LocalResolverProvider) logResolveDecision(ctx context.Context, flag string, evalCtx openfeature.FlattenedContext, detail openfeature.ProviderResolutionDetail) {
+	logger := p.log()
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	var targetingKey string
+	if key, ok := processTargetingKey(evalCtx)["targeting_key"]; ok && key != nil {
+		targetingKey = fmt.Sprint(key)
+	}
+	if p.redactor != nil {
+		targetingKey = p.redactor.redactTargetingKey(targetingKey)
 	}
+	logger.LogAttrs(ctx, slog.LevelDebug, "Resolved flag",
+		slog.String("flag", flag),
+		slog.String("variant", detail.Variant),
+		slog.String("reason", string(detail.Reason)),
+		slog.String("targeting_key", targetingKey),
+	)
 }
 
 // mapResolveReasonToOpenFeature converts Confidence ResolveReason to OpenFeature Reason
@@ -6,20 +6,142 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/open-feature/go-sdk/openfeature"
 	fl "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/flag_logger"
 	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
 	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolvertypes"
+	"github.com/tetratelabs/wazero"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
 
 const confidenceDomain = "edge-grpc.spotify.com"
 
+// maxPollJitter bounds PollJitter so a poll is never delayed by more than 1.5x the interval
+const maxPollJitter = 0.5
+
 type ProviderConfig struct {
 	ClientSecret   string
 	Logger         *slog.Logger
 	TransportHooks TransportHooks
+	// Sdk overrides the SDK identity reported with each resolve, e.g. to attribute resolves
+	// to a wrapper SDK with a Sdk_CustomId. Defaults to this provider's id and Version. It
+	// must not be modified after the provider is created.
+	Sdk *resolvertypes.Sdk
+	// WasmBytes optionally overrides the embedded resolver guest binary.
+	WasmBytes []byte
+	// WasmRuntime and CompiledWasm share one compiled resolver guest between providers instead
+	// of each compiling its own. The module must be compiled on the runtime, e.g. with
+	// CompileWasm, after registering the host functions with RegisterHostFunctions. Both stay
+	// owned by the caller, who closes the runtime once every provider using it is shut down.
+	// They can't be combined with WasmBytes or Clock.
+	WasmRuntime  wazero.Runtime
+	CompiledWasm wazero.CompiledModule
+	// ResolverInstances is the number of resolver guest instances resolves are spread over,
+	// round-robin. All instances share the compiled guest and state; state swaps replace the
+	// state of all of them at once. Zero uses GOMAXPROCS+1 instances.
+	ResolverInstances int
+	// Clock supplies the current time to the resolver, e.g. to freeze time when testing
+	// time-based targeting rules. Defaults to the system clock.
+	Clock Clock
+	// StaleThreshold marks the state as stale when it has not been reloaded for this long.
+	// Zero disables staleness tracking.
+	StaleThreshold time.Duration
+	// InitTimeout bounds how long Init waits for the initial state, failing with ErrInitTimeout
+	// once it passes. Zero (the default) waits as long as the state provider takes.
+	InitTimeout time.Duration
+	// PollInterval is how often state is polled. Zero falls back to the
+	// CONFIDENCE_RESOLVER_POLL_INTERVAL_SECONDS environment variable, then to 30 seconds.
+	PollInterval time.Duration
+	// AssignFlushInterval is how often assign logs are flushed between state polls. Zero uses
+	// the default of 100ms; a negative interval disables periodic flushing, so assign logs
+	// are only flushed on state polls and Shutdown.
+	AssignFlushInterval time.Duration
+	// PollJitter randomly spreads each state poll, including the first one after Init, by up
+	// to this fraction of the poll interval in either direction. At most 0.5, so polls are
+	// never back to back. Zero uses the default of 0.1 (±10%); a negative value disables
+	// jitter.
+	PollJitter float64
+	// Hooks are provider-level OpenFeature hooks run around every evaluation.
+	Hooks []openfeature.Hook
+	// FlushObserver is called with each batch of flag logs before it is sent.
+	FlushObserver FlushObserver
+	// ExposureSampling logs only one in N exposures for the given flags, keyed by
+	// flag name (e.g. "flags/my-flag"). Flags not listed are always logged.
+	ExposureSampling map[string]int
+	// FlagLogMaxChunkBytes splits flag log requests so each one sent stays within this
+	// serialized size. Zero (the default) sends each flush as a single request.
+	FlagLogMaxChunkBytes int
+	// FlagLogCallTimeout bounds each flag log request sent to Confidence; requests that time
+	// out count as failed writes. Defaults to 30 seconds.
+	FlagLogCallTimeout time.Duration
+	// FlagLogBreakerThreshold opens a circuit breaker after this many consecutive failed
+	// flag log writes, dropping writes until FlagLogBreakerCooldown has passed. Zero (the
+	// default) disables the breaker.
+	FlagLogBreakerThreshold int
+	// FlagLogBreakerCooldown is how long writes are dropped once the breaker opens, doubling
+	// while the backend keeps failing. Defaults to 30 seconds.
+	FlagLogBreakerCooldown time.Duration
+	// FlagLogSpoolDir stores flag log requests that fail to send in this directory, including
+	// the final flush on Shutdown, and resends them on the next Init. Empty (the default)
+	// drops failed requests.
+	FlagLogSpoolDir string
+	// FlagLogSpoolMaxBytes caps the total size of the spool directory, requests that don't
+	// fit are dropped. Defaults to 64 MiB.
+	FlagLogSpoolMaxBytes int64
+	// ResolverFallback resolves flags whose sticky rules need materializations that aren't
+	// available locally, e.g. a GrpcResolverFallback. Without one those resolves fail.
+	ResolverFallback ResolverFallback
+	// StickyMode selects whether flags whose sticky rules need materializations that aren't
+	// available locally go to the ResolverFallback (the default) or are resolved locally
+	// with their sticky rules skipped, returning the default value with DefaultReason when
+	// that leaves them unresolved.
+	StickyMode StickyMode
+	// RateLimitQPS caps resolves per second; evaluations over the limit return the
+	// default value with RateLimitedReason. Zero (the default) means unlimited.
+	RateLimitQPS float64
+	// RateLimitBurst is the number of resolves allowed in a burst above RateLimitQPS.
+	// Defaults to 1.
+	RateLimitBurst int
+	// StateBaseURLs are the CDN base URLs to fetch resolver state from, primary first.
+	// Fallbacks are tried on connection errors and 5xx responses. Defaults to DefaultStateBaseURL.
+	StateBaseURLs []string
+	// StateFilePath loads the resolver state from a file holding a marshaled
+	// SetResolverStateRequest instead of fetching it from the CDN. The file is checked for
+	// changes on every state poll. StateBaseURLs is ignored when set. ClientSecret is optional
+	// with a state file: without it the provider runs offline, no flag logs are sent and
+	// resolves pass their client secret with WithClientSecret.
+	StateFilePath string
+	// RequireNonEmptyState makes Init fail when the initial state contains no flags,
+	// which usually means a misconfigured client secret or a bad publish.
+	RequireNonEmptyState bool
+	// ArchivedFlagMode controls whether archived flags resolve silently to the default value
+	// with DisabledReason (the default) or return an error.
+	ArchivedFlagMode ArchivedFlagMode
+	// SensitiveContextKeys are evaluation context keys whose values must not appear in
+	// flag logs, e.g. "email". They can still be used for targeting.
+	SensitiveContextKeys []string
+	// SensitiveKeyRedaction selects whether sensitive values are hashed (the default) or dropped.
+	SensitiveKeyRedaction RedactionMode
+	// ContextCacheSize bounds the cache of evaluation contexts already converted for the
+	// resolver. Zero uses the default of 1024; a negative size disables the cache.
+	ContextCacheSize int
+	// LargeIntsAsStrings sends evaluation context integers beyond ±2^53, e.g. large user ids,
+	// as decimal strings since numbers are sent as float64 and would lose precision. Numeric
+	// targeting rules don't match such values. When unset they are sent as numbers and a
+	// warning is logged once per context key.
+	LargeIntsAsStrings bool
+	// OnStateUpdate is called after each successful swap to newly fetched state, including the
+	// initial load in Init, with the state size in bytes and whether its content changed.
+	OnStateUpdate func(accountId string, stateBytes int, changed bool)
+	// OnStateUpdateError is called when a background state poll fails to fetch or apply state.
+	OnStateUpdateError func(error)
+	// Metrics receives resolve, state reload and flag log measurements, e.g. the Prometheus
+	// collectors of prommetrics.NewMetrics. Nil (the default) disables measuring.
+	Metrics Metrics
 }
 
 type ProviderTestConfig struct {
@@ -27,12 +149,29 @@ type ProviderTestConfig struct {
 	FlagLogger    FlagLogger
 	ClientSecret  string
 	Logger        *slog.Logger
+	Hooks         []openfeature.Hook
+	Clock         Clock
 }
 
 func NewProvider(ctx context.Context, config ProviderConfig) (*LocalResolverProvider, error) {
-	if config.ClientSecret == "" {
+	if config.ClientSecret == "" && config.StateFilePath == "" {
 		return nil, fmt.Errorf("ClientSecret is required")
 	}
+	if config.RateLimitQPS < 0 {
+		return nil, fmt.Errorf("RateLimitQPS must not be negative, got %v", config.RateLimitQPS)
+	}
+	if config.PollInterval < 0 {
+		return nil, fmt.Errorf("PollInterval must not be negative, got %v", config.PollInterval)
+	}
+	if config.ResolverInstances < 0 {
+		return nil, fmt.Errorf("ResolverInstances must not be negative, got %d", config.ResolverInstances)
+	}
+	if config.InitTimeout < 0 {
+		return nil, fmt.Errorf("InitTimeout must not be negative, got %v", config.InitTimeout)
+	}
+	if config.PollJitter > maxPollJitter {
+		return nil, fmt.Errorf("PollJitter must be at most %v, got %v", maxPollJitter, config.PollJitter)
+	}
 
 	logger := config.Logger
 	if logger == nil {
@@ -41,12 +180,109 @@ func NewProvider(ctx context.Context, config ProviderConfig) (*LocalResolverProv
 		}))
 	}
 
-	// Create gRPC connection for flag logger
+	// Compile a custom resolver guest up front so an invalid binary fails here rather than in Init
+	resolverOptions := lr.Options{Clock: config.Clock, Instances: config.ResolverInstances}
+	if config.WasmRuntime != nil || config.CompiledWasm != nil {
+		if config.WasmRuntime == nil || config.CompiledWasm == nil {
+			return nil, fmt.Errorf("WasmRuntime and CompiledWasm must be set together")
+		}
+		if config.WasmBytes != nil {
+			return nil, fmt.Errorf("WasmBytes can't be combined with CompiledWasm")
+		}
+		if config.Clock != nil {
+			return nil, fmt.Errorf("Clock can't be combined with CompiledWasm, pass it to RegisterHostFunctions")
+		}
+		compiled, err := lr.NewSharedCompiledWasm(config.WasmRuntime, config.CompiledWasm)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CompiledWasm: %w", err)
+		}
+		resolverOptions.Compiled = compiled
+	}
+	if config.WasmBytes != nil {
+		// Every Init compiles the guest on a runtime of its own, which the resolver closes
+		// when it is replaced, so only check the bytes compile here
+		runtime := wazero.NewRuntime(ctx)
+		_, err := lr.CompileModule(ctx, runtime, config.WasmBytes)
+		runtime.Close(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WasmBytes: %w", err)
+		}
+		resolverOptions.Wasm = config.WasmBytes
+	}
+	resolverSupplier := lr.NewLocalResolverWithOptions(resolverOptions)
+
 	hooks := config.TransportHooks
 	if hooks == nil {
 		hooks = DefaultTransportHooks
 	}
 
+	// Create state provider and flag logger
+	var stateProvider StateProvider
+	if config.StateFilePath != "" {
+		stateProvider = NewFileStateProvider(config.StateFilePath, "")
+	} else {
+		// Build HTTP transport using hooks and pass into state fetcher
+		transport := hooks.WrapHTTP(http.DefaultTransport)
+		stateProvider = NewFlagsAdminStateFetcherWithBaseURLs(config.ClientSecret, logger, transport, config.StateBaseURLs)
+	}
+	var flagLogger FlagLogger
+	if config.ClientSecret == "" {
+		// Offline with a state file only: there is nothing to send flag logs as, so
+		// Confidence isn't dialed at all
+		flagLogger = fl.NewNoOpWasmFlagLogger()
+	} else {
+		grpcLogger, err := newGrpcFlagLogger(config, hooks, logger)
+		if err != nil {
+			return nil, err
+		}
+		flagLogger = grpcLogger
+	}
+
+	provider := NewLocalResolverProvider(resolverSupplier, stateProvider, flagLogger, config.ClientSecret, logger)
+	provider.clock = config.Clock
+	provider.instances = config.ResolverInstances
+	if config.Sdk != nil {
+		provider.sdk = config.Sdk
+	}
+	provider.resolverFallback = config.ResolverFallback
+	provider.stickyMode = config.StickyMode
+	provider.staleThreshold = config.StaleThreshold
+	provider.initTimeout = config.InitTimeout
+	if config.PollInterval > 0 {
+		provider.pollInterval = config.PollInterval
+	}
+	if config.AssignFlushInterval != 0 {
+		provider.flushInterval = config.AssignFlushInterval
+	}
+	if config.PollJitter != 0 {
+		provider.pollJitter = config.PollJitter
+	}
+	provider.hooks = config.Hooks
+	provider.flushObserver = config.FlushObserver
+	provider.onStateUpdate = config.OnStateUpdate
+	provider.onStateUpdateErr = config.OnStateUpdateError
+	provider.metrics = config.Metrics
+	provider.requireFlags = config.RequireNonEmptyState
+	provider.archivedFlagMode = config.ArchivedFlagMode
+	provider.largeIntsAsStrings = config.LargeIntsAsStrings
+	switch {
+	case config.ContextCacheSize < 0:
+		provider.contextCache = nil
+	case config.ContextCacheSize > 0:
+		provider.contextCache = newContextCache(config.ContextCacheSize)
+	}
+	if len(config.SensitiveContextKeys) > 0 {
+		provider.redactor = newContextRedactor(config.SensitiveContextKeys, config.SensitiveKeyRedaction)
+	}
+	if config.RateLimitQPS > 0 {
+		provider.rateLimiter = newTokenBucket(config.RateLimitQPS, config.RateLimitBurst)
+	}
+
+	return provider, nil
+}
+
+// newGrpcFlagLogger dials Confidence and creates a flag logger configured from config
+func newGrpcFlagLogger(config ProviderConfig, hooks TransportHooks, logger *slog.Logger) (*fl.GrpcFlagLogger, error) {
 	tlsCreds := credentials.NewTLS(nil)
 	baseOpts := []grpc.DialOption{
 		grpc.WithTransportCredentials(tlsCreds),
@@ -58,16 +294,43 @@ func NewProvider(ctx context.Context, config ProviderConfig) (*LocalResolverProv
 		return nil, fmt.Errorf("failed to create connection: %w", err)
 	}
 
-	// Create state provider and flag logger
 	flagLoggerService := resolverv1.NewInternalFlagLoggerServiceClient(conn)
-	// Build HTTP transport using hooks and pass into state fetcher
-	transport := hooks.WrapHTTP(http.DefaultTransport)
-	stateProvider := NewFlagsAdminStateFetcherWithTransport(config.ClientSecret, logger, transport)
 	flagLogger := fl.NewGrpcWasmFlagLogger(flagLoggerService, config.ClientSecret, logger)
-
-	provider := NewLocalResolverProvider(lr.NewLocalResolver, stateProvider, flagLogger, config.ClientSecret, logger)
-
-	return provider, nil
+	if config.ExposureSampling != nil {
+		if err := flagLogger.SetExposureSampling(config.ExposureSampling); err != nil {
+			return nil, fmt.Errorf("invalid ExposureSampling: %w", err)
+		}
+	}
+	if err := flagLogger.SetMaxChunkBytes(config.FlagLogMaxChunkBytes); err != nil {
+		return nil, fmt.Errorf("invalid FlagLogMaxChunkBytes: %w", err)
+	}
+	if config.FlagLogCallTimeout != 0 {
+		if err := flagLogger.SetCallTimeout(config.FlagLogCallTimeout); err != nil {
+			return nil, fmt.Errorf("invalid FlagLogCallTimeout: %w", err)
+		}
+	}
+	if config.FlagLogSpoolDir != "" {
+		maxBytes := config.FlagLogSpoolMaxBytes
+		if maxBytes == 0 {
+			maxBytes = defaultFlagLogSpoolMaxBytes
+		}
+		if err := flagLogger.SetSpool(config.FlagLogSpoolDir, maxBytes); err != nil {
+			return nil, fmt.Errorf("invalid FlagLogSpoolDir: %w", err)
+		}
+	}
+	if config.FlagLogBreakerThreshold != 0 {
+		cooldown := config.FlagLogBreakerCooldown
+		if cooldown == 0 {
+			cooldown = defaultFlagLogBreakerCooldown
+		}
+		if err := flagLogger.SetCircuitBreaker(config.FlagLogBreakerThreshold, cooldown); err != nil {
+			return nil, fmt.Errorf("invalid flag log circuit breaker: %w", err)
+		}
+	}
+	if config.Metrics != nil {
+		flagLogger.SetWriteObserver(config.Metrics.FlagLogsWritten)
+	}
+	return flagLogger, nil
 }
 
 // NewProviderForTest creates a provider with mocked StateProvider and FlagLogger for testing
@@ -86,7 +349,13 @@ func NewProviderForTest(ctx context.Context, config ProviderTestConfig) (*LocalR
 		}))
 	}
 
-	provider := NewLocalResolverProvider(lr.NewLocalResolver, config.StateProvider, config.FlagLogger, config.ClientSecret, logger)
+	var resolverSupplier LocalResolverSupplier = lr.NewLocalResolver
+	if config.Clock != nil {
+		resolverSupplier = lr.NewLocalResolverWithClock(config.Clock)
+	}
+	provider := NewLocalResolverProvider(resolverSupplier, config.StateProvider, config.FlagLogger, config.ClientSecret, logger)
+	provider.hooks = config.Hooks
+	provider.clock = config.Clock
 
 	return provider, nil
 }
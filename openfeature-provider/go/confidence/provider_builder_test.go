@@ -0,0 +1,327 @@
+package confidence
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	fl "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/flag_logger"
+	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
+	pb "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto"
+	"github.com/tetratelabs/wazero"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNewProvider_RequiresClientSecret(t *testing.T) {
+	_, err := NewProvider(context.Background(), ProviderConfig{})
+	if err == nil {
+		t.Fatal("Expected error when ClientSecret is empty")
+	}
+}
+
+func TestNewProvider_StateFileWithoutClientSecret(t *testing.T) {
+	envelope, err := proto.Marshal(&pb.SetResolverStateRequest{
+		State:     tu.LoadTestResolverState(t),
+		AccountId: tu.LoadTestAccountID(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal state: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "state.pb")
+	if err := os.WriteFile(path, envelope, 0o644); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+
+	provider, err := NewProvider(context.Background(), ProviderConfig{StateFilePath: path})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, ok := provider.flagLogger.(*fl.NoOpWasmFlagLogger); !ok {
+		t.Errorf("Expected flag logs to be dropped offline, got %T", provider.flagLogger)
+	}
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+	defer provider.Shutdown()
+
+	ctx := WithClientSecret(context.Background(), "mkjJruAATQWjeY7foFIWfVAcBWnci2YF")
+	result := provider.ObjectEvaluation(ctx, "tutorial-feature", nil, openfeature.FlattenedContext{"visitor_id": "tutorial_visitor"})
+	if result.Error() != nil {
+		t.Errorf("Expected the flag to resolve from the state file, got %v", result.Error())
+	}
+}
+
+func TestNewProvider_InvalidWasmBytes(t *testing.T) {
+	_, err := NewProvider(context.Background(), ProviderConfig{
+		ClientSecret: "secret",
+		WasmBytes:    []byte("not a wasm module"),
+	})
+	if err == nil {
+		t.Fatal("Expected error for invalid WasmBytes")
+	}
+	if !strings.HasPrefix(err.Error(), "invalid WasmBytes: failed to compile WASM module") {
+		t.Errorf("Expected compile error, got: %v", err)
+	}
+}
+
+func TestNewProvider_WasmBytesReinit(t *testing.T) {
+	wasm, err := os.ReadFile("internal/local_resolver/assets/confidence_resolver.wasm")
+	if err != nil {
+		t.Skipf("Skipping test - could not load WASM guest: %v", err)
+	}
+	envelope, err := proto.Marshal(&pb.SetResolverStateRequest{
+		State:     tu.LoadTestResolverState(t),
+		AccountId: tu.LoadTestAccountID(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal state: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "state.pb")
+	if err := os.WriteFile(path, envelope, 0o644); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+
+	provider, err := NewProvider(context.Background(), ProviderConfig{StateFilePath: path, WasmBytes: wasm})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	// The second Init replaces the resolver of the first, which must not take the new one down
+	for i := 0; i < 2; i++ {
+		if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+			t.Fatalf("Failed to init provider: %v", err)
+		}
+	}
+	defer provider.Shutdown()
+
+	ctx := WithClientSecret(context.Background(), "mkjJruAATQWjeY7foFIWfVAcBWnci2YF")
+	result := provider.ObjectEvaluation(ctx, "tutorial-feature", nil, openfeature.FlattenedContext{"visitor_id": "tutorial_visitor"})
+	if result.Error() != nil {
+		t.Errorf("Expected the flag to resolve after a second Init, got %v", result.Error())
+	}
+}
+
+func TestNewProvider_CompiledWasm(t *testing.T) {
+	ctx := context.Background()
+	runtime := wazero.NewRuntime(ctx)
+	defer runtime.Close(ctx)
+	if err := RegisterHostFunctions(ctx, runtime, nil); err != nil {
+		t.Fatalf("Failed to register host functions: %v", err)
+	}
+	module, err := CompileWasm(ctx, runtime, nil)
+	if err != nil {
+		t.Fatalf("Failed to compile embedded WASM: %v", err)
+	}
+
+	invalid := []ProviderConfig{
+		{ClientSecret: "secret", CompiledWasm: module},
+		{ClientSecret: "secret", WasmRuntime: runtime},
+		{ClientSecret: "secret", WasmRuntime: runtime, CompiledWasm: module, WasmBytes: []byte("wasm")},
+		{ClientSecret: "secret", WasmRuntime: runtime, CompiledWasm: module, Clock: fixedClock(time.Now())},
+	}
+	for i, config := range invalid {
+		if _, err := NewProvider(ctx, config); err == nil {
+			t.Errorf("Expected error for invalid config %d", i)
+		}
+	}
+
+	// Several providers share the one compilation
+	for i := 0; i < 2; i++ {
+		if _, err := NewProvider(ctx, ProviderConfig{
+			ClientSecret: "secret",
+			WasmRuntime:  runtime,
+			CompiledWasm: module,
+		}); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+}
+
+func TestNewProvider_PollJitter(t *testing.T) {
+	if _, err := NewProvider(context.Background(), ProviderConfig{ClientSecret: "secret", PollJitter: 0.6}); err == nil {
+		t.Error("Expected error for PollJitter 0.6")
+	}
+
+	for _, tc := range []struct {
+		jitter   float64
+		expected float64
+	}{
+		{0, defaultPollJitter},
+		{0.3, 0.3},
+		{-1, -1},
+	} {
+		provider, err := NewProvider(context.Background(), ProviderConfig{
+			ClientSecret: "secret",
+			PollJitter:   tc.jitter,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if provider.pollJitter != tc.expected {
+			t.Errorf("Expected pollJitter %v for PollJitter %v, got: %v", tc.expected, tc.jitter, provider.pollJitter)
+		}
+	}
+
+	provider, _ := NewProvider(context.Background(), ProviderConfig{ClientSecret: "secret", PollJitter: -1})
+	if delay := provider.nextPollDelay(); delay != provider.pollInterval {
+		t.Errorf("Expected a negative PollJitter to disable jitter, got delay %v", delay)
+	}
+}
+
+func TestNewProvider_ResolverInstances(t *testing.T) {
+	if _, err := NewProvider(context.Background(), ProviderConfig{ClientSecret: "secret", ResolverInstances: -1}); err == nil {
+		t.Error("Expected error for negative ResolverInstances")
+	}
+
+	provider, err := NewProvider(context.Background(), ProviderConfig{ClientSecret: "secret", ResolverInstances: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if provider.instances != 2 {
+		t.Errorf("Expected instances to be 2, got: %v", provider.instances)
+	}
+}
+
+func TestNewProvider_PollInterval(t *testing.T) {
+	t.Setenv("CONFIDENCE_RESOLVER_POLL_INTERVAL_SECONDS", "7")
+
+	provider, err := NewProvider(context.Background(), ProviderConfig{ClientSecret: "secret"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if provider.pollInterval != 7*time.Second {
+		t.Errorf("Expected the environment variable to apply without PollInterval, got: %v", provider.pollInterval)
+	}
+
+	provider, err = NewProvider(context.Background(), ProviderConfig{
+		ClientSecret: "secret",
+		PollInterval: 2 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if provider.pollInterval != 2*time.Minute {
+		t.Errorf("Expected PollInterval to take precedence, got: %v", provider.pollInterval)
+	}
+
+	if _, err := NewProvider(context.Background(), ProviderConfig{ClientSecret: "secret", PollInterval: -time.Second}); err == nil {
+		t.Error("Expected error for a negative PollInterval")
+	}
+}
+
+func TestNewProvider_InvalidExposureSampling(t *testing.T) {
+	_, err := NewProvider(context.Background(), ProviderConfig{
+		ClientSecret:     "secret",
+		ExposureSampling: map[string]int{"flags/my-flag": 0},
+	})
+	if err == nil {
+		t.Fatal("Expected error for zero sampling rate")
+	}
+	if !strings.HasPrefix(err.Error(), "invalid ExposureSampling") {
+		t.Errorf("Expected sampling error, got: %v", err)
+	}
+}
+
+func TestNewProvider_FlagLogCallTimeout(t *testing.T) {
+	if _, err := NewProvider(context.Background(), ProviderConfig{ClientSecret: "secret", FlagLogCallTimeout: time.Second}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	_, err := NewProvider(context.Background(), ProviderConfig{
+		ClientSecret:       "secret",
+		FlagLogCallTimeout: -time.Second,
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid FlagLogCallTimeout") {
+		t.Errorf("Expected an error for a negative call timeout, got: %v", err)
+	}
+}
+
+func TestNewProvider_FlagLogCircuitBreaker(t *testing.T) {
+	provider, err := NewProvider(context.Background(), ProviderConfig{
+		ClientSecret:            "secret",
+		FlagLogBreakerThreshold: 3,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if state := provider.FlagLogBreakerState(); state != BreakerClosed {
+		t.Errorf("Expected a closed breaker, got %v", state)
+	}
+	if dropped := provider.FlagLogsDropped(); dropped != 0 {
+		t.Errorf("Expected no dropped writes, got %d", dropped)
+	}
+
+	_, err = NewProvider(context.Background(), ProviderConfig{
+		ClientSecret:            "secret",
+		FlagLogBreakerThreshold: -1,
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid flag log circuit breaker") {
+		t.Errorf("Expected an error for a negative threshold, got: %v", err)
+	}
+}
+
+func TestNewProvider_FlagLogSpool(t *testing.T) {
+	dir := t.TempDir() + "/spool"
+	if _, err := NewProvider(context.Background(), ProviderConfig{ClientSecret: "secret", FlagLogSpoolDir: dir}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("Expected the spool directory to be created, got: %v", err)
+	}
+
+	_, err := NewProvider(context.Background(), ProviderConfig{
+		ClientSecret:         "secret",
+		FlagLogSpoolDir:      dir,
+		FlagLogSpoolMaxBytes: -1,
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid FlagLogSpoolDir") {
+		t.Errorf("Expected an error for a negative spool size, got: %v", err)
+	}
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+func TestNewProviderForTest_Clock(t *testing.T) {
+	frozen := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	flagLogger := fl.NewCapturingFlagLogger()
+	provider, err := NewProviderForTest(context.Background(), ProviderTestConfig{
+		StateProvider: &tu.StateProviderMock{
+			State:     tu.LoadTestResolverState(t),
+			AccountID: tu.LoadTestAccountID(t),
+		},
+		FlagLogger:   flagLogger,
+		ClientSecret: "mkjJruAATQWjeY7foFIWfVAcBWnci2YF",
+		Clock:        fixedClock(frozen),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+	result := provider.ObjectEvaluation(context.Background(), "tutorial-feature", nil, openfeature.FlattenedContext{"visitor_id": "tutorial_visitor"})
+	if result.Error() != nil {
+		t.Fatalf("Expected no error, got %v", result.Error())
+	}
+	provider.Shutdown()
+
+	exposures := 0
+	for _, request := range flagLogger.GetCapturedRequests() {
+		for _, assigned := range request.FlagAssigned {
+			for _, flag := range assigned.Flags {
+				exposures++
+				if got := flag.ApplyTime.AsTime(); !got.Equal(frozen) {
+					t.Errorf("Expected apply time from the clock %v, got %v", frozen, got)
+				}
+			}
+		}
+	}
+	if exposures == 0 {
+		t.Error("Expected the exposure to be flushed")
+	}
+}
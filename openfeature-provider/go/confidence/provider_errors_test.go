@@ -1,10 +1,17 @@
 package confidence
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/open-feature/go-sdk/openfeature"
+	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
+	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
 	resolvertypes "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolvertypes"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/resolver"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 func TestLocalResolverProvider_ReasonMapping(t *testing.T) {
@@ -52,3 +59,192 @@ func TestLocalResolverProvider_ReasonMapping(t *testing.T) {
 		})
 	}
 }
+
+func TestLocalResolverProvider_ArchivedFlagMode(t *testing.T) {
+	mockResolver := &mockResolverAPIForInit{
+		resolveWithSticky: func(request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+			return &resolver.ResolveWithStickyResponse{
+				ResolveResult: &resolver.ResolveWithStickyResponse_Success_{
+					Success: &resolver.ResolveWithStickyResponse_Success{
+						Response: &resolver.ResolveFlagsResponse{
+							ResolvedFlags: []*resolver.ResolvedFlag{{
+								Flag:   "flags/archived-flag",
+								Reason: resolvertypes.ResolveReason_RESOLVE_REASON_FLAG_ARCHIVED,
+							}},
+						},
+					},
+				},
+			}, nil
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		mode           ArchivedFlagMode
+		expectedReason openfeature.Reason
+		expectedError  string
+	}{
+		{
+			name:           "Disabled mode returns default without error",
+			mode:           ArchivedFlagDisabled,
+			expectedReason: openfeature.DisabledReason,
+		},
+		{
+			name:           "Error mode returns a general error",
+			mode:           ArchivedFlagError,
+			expectedReason: openfeature.ErrorReason,
+			expectedError:  "GENERAL: flag 'archived-flag' is archived",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := NewLocalResolverProvider(
+				func(_ context.Context, _ lr.LogSink) lr.LocalResolver { return mockResolver },
+				&tu.StateProviderMock{State: []byte("state"), AccountID: "account"},
+				&tu.MockFlagLogger{},
+				"secret",
+				nil,
+			)
+			provider.archivedFlagMode = tc.mode
+			if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+				t.Fatalf("Failed to init provider: %v", err)
+			}
+			defer provider.Shutdown()
+
+			result := provider.BooleanEvaluation(context.Background(), "archived-flag.enabled", true, openfeature.FlattenedContext{})
+			if result.Value != true {
+				t.Errorf("Expected default value true, got %v", result.Value)
+			}
+			if result.Reason != tc.expectedReason {
+				t.Errorf("Expected reason %s, got %s", tc.expectedReason, result.Reason)
+			}
+			if tc.expectedError == "" {
+				if result.Error() != nil {
+					t.Errorf("Expected no error, got %v", result.Error())
+				}
+			} else if result.Error() == nil || result.Error().Error() != tc.expectedError {
+				t.Errorf("Expected error %q, got %v", tc.expectedError, result.Error())
+			}
+		})
+	}
+}
+
+func TestLocalResolverProvider_ErrProviderNotReady(t *testing.T) {
+	provider := NewLocalResolverProvider(nil, nil, nil, "secret", nil)
+
+	result := provider.ObjectEvaluation(context.Background(), "my-flag", "default", openfeature.FlattenedContext{})
+	if !errors.Is(result.ResolutionError, ErrProviderNotReady) {
+		t.Errorf("Expected ErrProviderNotReady, got %v", result.ResolutionError)
+	}
+	if result.Value != "default" || result.Reason != openfeature.ErrorReason {
+		t.Errorf("Expected the default value with ErrorReason, got %+v", result)
+	}
+
+	if _, err := provider.ResolveRaw(context.Background(), "my-flag", openfeature.FlattenedContext{}); !errors.Is(err, ErrProviderNotReady) {
+		t.Errorf("Expected ErrProviderNotReady from ResolveRaw, got %v", err)
+	}
+}
+
+func TestLocalResolverProvider_ClientSecretNotFound(t *testing.T) {
+	provider := NewLocalResolverProvider(
+		lr.NewLocalResolver,
+		&tu.StateProviderMock{
+			State:     tu.LoadTestResolverState(t),
+			AccountID: tu.LoadTestAccountID(t),
+		},
+		&tu.MockFlagLogger{},
+		"unknown-secret",
+		nil,
+	)
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+	defer provider.Shutdown()
+
+	result := provider.ObjectEvaluation(context.Background(), "tutorial-feature", nil, openfeature.FlattenedContext{"visitor_id": "tutorial_visitor"})
+	if !errors.Is(result.ResolutionError, ErrClientSecretNotFound) {
+		t.Errorf("Expected ErrClientSecretNotFound, got %v", result.ResolutionError)
+	}
+	if result.Reason != openfeature.ErrorReason {
+		t.Errorf("Expected ErrorReason, got %v", result.Reason)
+	}
+}
+
+func TestResolveError(t *testing.T) {
+	if got := resolveError(errors.New("client secret not found")); got != ErrClientSecretNotFound {
+		t.Errorf("Expected ErrClientSecretNotFound, got %v", got)
+	}
+	got := resolveError(errors.New("something broke"))
+	if got.Error() != "GENERAL: resolve failed: something broke" {
+		t.Errorf("Expected a general error, got %q", got.Error())
+	}
+}
+
+func TestLocalResolverProvider_TargetingKeyError(t *testing.T) {
+	provider := NewLocalResolverProvider(
+		lr.NewLocalResolver,
+		&tu.StateProviderMock{
+			State:     tu.LoadTestResolverState(t),
+			AccountID: tu.LoadTestAccountID(t),
+		},
+		&tu.MockFlagLogger{},
+		"mkjJruAATQWjeY7foFIWfVAcBWnci2YF",
+		nil,
+	)
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+	defer provider.Shutdown()
+
+	// A boolean is not a valid targeting key
+	evalCtx := openfeature.FlattenedContext{"visitor_id": true}
+	details := map[string]openfeature.ProviderResolutionDetail{
+		"Boolean": provider.BooleanEvaluation(context.Background(), "tutorial-feature.enabled", false, evalCtx).ProviderResolutionDetail,
+		"String":  provider.StringEvaluation(context.Background(), "tutorial-feature.title", "", evalCtx).ProviderResolutionDetail,
+		"Int":     provider.IntEvaluation(context.Background(), "tutorial-feature.count", 0, evalCtx).ProviderResolutionDetail,
+		"Float":   provider.FloatEvaluation(context.Background(), "tutorial-feature.ratio", 0, evalCtx).ProviderResolutionDetail,
+		"Object":  provider.ObjectEvaluation(context.Background(), "tutorial-feature", nil, evalCtx).ProviderResolutionDetail,
+	}
+	for name, detail := range details {
+		if detail.Reason != openfeature.ErrorReason {
+			t.Errorf("%s: expected ErrorReason, got %v", name, detail.Reason)
+		}
+		if got := detail.ResolutionError.Error(); !strings.HasPrefix(got, "INVALID_CONTEXT: invalid targeting key") {
+			t.Errorf("%s: expected an INVALID_CONTEXT error, got %q", name, got)
+		}
+	}
+}
+
+func TestLocalResolverProvider_TypeMismatchKeepsVariant(t *testing.T) {
+	value, _ := structpb.NewStruct(map[string]interface{}{"color": "blue"})
+	supplier := NewScriptedResolver(map[string]*resolver.ResolveFlagsResponse{
+		"my-flag": {ResolvedFlags: []*resolver.ResolvedFlag{{
+			Flag:    "flags/my-flag",
+			Variant: "flags/my-flag/variants/blue",
+			Value:   value,
+			Reason:  resolvertypes.ResolveReason_RESOLVE_REASON_MATCH,
+		}}},
+	})
+	provider := NewLocalResolverProvider(supplier, &tu.StateProviderMock{State: []byte("state"), AccountID: "account"}, &tu.MockFlagLogger{}, "secret", nil)
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+	defer provider.Shutdown()
+
+	ctx := context.Background()
+	details := map[string]openfeature.ProviderResolutionDetail{
+		"boolean": provider.BooleanEvaluation(ctx, "my-flag.color", false, nil).ProviderResolutionDetail,
+		"int":     provider.IntEvaluation(ctx, "my-flag.color", 0, nil).ProviderResolutionDetail,
+		"float":   provider.FloatEvaluation(ctx, "my-flag.color", 0, nil).ProviderResolutionDetail,
+		"string":  provider.StringEvaluation(ctx, "my-flag", "", nil).ProviderResolutionDetail,
+	}
+	for kind, detail := range details {
+		if detail.ResolutionDetail().ErrorCode != openfeature.TypeMismatchCode {
+			t.Errorf("Expected a type mismatch for the %s evaluation, got %v", kind, detail.ResolutionError)
+		}
+		if detail.Variant != "flags/my-flag/variants/blue" {
+			t.Errorf("Expected the %s evaluation to keep the assigned variant, got %q", kind, detail.Variant)
+		}
+	}
+}
@@ -2,14 +2,21 @@ package confidence
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/open-feature/go-sdk/openfeature"
+	fl "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/flag_logger"
 	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
 	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
 	adminv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/admin/v1"
+	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
+	resolvertypes "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolvertypes"
 	iamv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/iam/v1"
 	"google.golang.org/protobuf/proto"
 )
@@ -52,7 +59,7 @@ func TestLocalResolverProvider_ReturnsDefaultOnError(t *testing.T) {
 		if err == nil {
 			t.Errorf("Expected error during StringValueDetails, got nil")
 		}
-		if err.Error() != "error code: GENERAL: resolve failed: client secret not found" {
+		if err.Error() != "error code: INVALID_CONTEXT: client secret not found" {
 			t.Errorf("Expected specific error message during StringValueDetails, got %v", err.Error())
 		}
 
@@ -284,3 +291,178 @@ func TestLocalResolverProvider_MissingMaterializations(t *testing.T) {
 		}
 	})
 }
+
+// slowAsyncFlagLogger delivers writes from a goroutine after a delay, like the gRPC flag logger
+type slowAsyncFlagLogger struct {
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	delivered int
+}
+
+func (l *slowAsyncFlagLogger) Write(request *resolverv1.WriteFlagLogsRequest) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		time.Sleep(50 * time.Millisecond)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for _, assigned := range request.FlagAssigned {
+			l.delivered += len(assigned.Flags)
+		}
+	}()
+}
+
+func (l *slowAsyncFlagLogger) Shutdown() {
+	l.wg.Wait()
+}
+
+func TestLocalResolverProvider_ShutdownDeliversFinalFlush(t *testing.T) {
+	stateProvider := &tu.StateProviderMock{
+		State:     tu.LoadTestResolverState(t),
+		AccountID: tu.LoadTestAccountID(t),
+	}
+	flagLogger := &slowAsyncFlagLogger{}
+	provider := NewLocalResolverProvider(lr.NewLocalResolver, stateProvider, flagLogger, "mkjJruAATQWjeY7foFIWfVAcBWnci2YF", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+
+	const resolves = 50
+	for i := 0; i < resolves; i++ {
+		result := provider.ObjectEvaluation(context.Background(), "tutorial-feature", nil, openfeature.FlattenedContext{
+			"visitor_id": "tutorial_visitor",
+		})
+		if result.Error() != nil {
+			t.Fatalf("Failed to resolve: %v", result.Error())
+		}
+	}
+
+	// No waiting after Shutdown: every exposure must already be delivered when it returns
+	provider.Shutdown()
+
+	flagLogger.mu.Lock()
+	defer flagLogger.mu.Unlock()
+	if flagLogger.delivered != resolves {
+		t.Errorf("Expected %d exposures delivered by shutdown, got %d", resolves, flagLogger.delivered)
+	}
+}
+
+func TestLocalResolverProvider_ResolveRaw(t *testing.T) {
+	stateProvider := &tu.StateProviderMock{
+		State:     tu.LoadTestResolverState(t),
+		AccountID: tu.LoadTestAccountID(t),
+	}
+	provider := NewLocalResolverProvider(lr.NewLocalResolver, stateProvider, fl.NewCapturingFlagLogger(), "mkjJruAATQWjeY7foFIWfVAcBWnci2YF", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+	defer provider.Shutdown()
+
+	evalCtx := openfeature.FlattenedContext{"visitor_id": "tutorial_visitor"}
+
+	t.Run("returns the resolved flag", func(t *testing.T) {
+		resolved, err := provider.ResolveRaw(context.Background(), "tutorial-feature", evalCtx)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if resolved.Flag != "flags/tutorial-feature" {
+			t.Errorf("Expected flags/tutorial-feature, got %s", resolved.Flag)
+		}
+		if resolved.Variant == "" {
+			t.Error("Expected a variant")
+		}
+		if resolved.Reason != resolvertypes.ResolveReason_RESOLVE_REASON_MATCH {
+			t.Errorf("Expected RESOLVE_REASON_MATCH, got %v", resolved.Reason)
+		}
+		if got := resolved.Value.GetFields()["title"].GetStringValue(); got != "Welcome to Confidence!" {
+			t.Errorf("Expected raw title value, got %q", got)
+		}
+	})
+
+	t.Run("returns a resolution error for an unknown flag", func(t *testing.T) {
+		resolved, err := provider.ResolveRaw(context.Background(), "does-not-exist", evalCtx)
+		if resolved != nil {
+			t.Errorf("Expected no flag, got %v", resolved)
+		}
+		var resErr openfeature.ResolutionError
+		if !errors.As(err, &resErr) {
+			t.Fatalf("Expected a ResolutionError, got %v", err)
+		}
+		if err.Error() != "FLAG_NOT_FOUND: flag 'does-not-exist' not found" {
+			t.Errorf("Expected FLAG_NOT_FOUND, got %v", err)
+		}
+	})
+}
+
+func TestLocalResolverProvider_Resolve(t *testing.T) {
+	evalCtx := openfeature.FlattenedContext{"visitor_id": "tutorial_visitor"}
+
+	for _, apply := range []bool{false, true} {
+		t.Run(fmt.Sprintf("apply=%v", apply), func(t *testing.T) {
+			stateProvider := &tu.StateProviderMock{
+				State:     tu.LoadTestResolverState(t),
+				AccountID: tu.LoadTestAccountID(t),
+			}
+			flagLogger := fl.NewCapturingFlagLogger()
+			provider := NewLocalResolverProvider(lr.NewLocalResolver, stateProvider, flagLogger, "mkjJruAATQWjeY7foFIWfVAcBWnci2YF", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+			if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+				t.Fatalf("Failed to init provider: %v", err)
+			}
+
+			response, err := provider.Resolve(context.Background(), []string{"tutorial-feature", "flags/fallthrough-test-1"}, evalCtx, apply)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			provider.Shutdown()
+
+			if len(response.ResolvedFlags) != 2 {
+				t.Fatalf("Expected 2 resolved flags, got %d", len(response.ResolvedFlags))
+			}
+			for _, resolved := range response.ResolvedFlags {
+				if resolved.Variant == "" || resolved.Reason != resolvertypes.ResolveReason_RESOLVE_REASON_MATCH {
+					t.Errorf("Expected a matched variant for %s, got %q (%v)", resolved.Flag, resolved.Variant, resolved.Reason)
+				}
+			}
+
+			exposures := 0
+			for _, request := range flagLogger.GetCapturedRequests() {
+				for _, assigned := range request.FlagAssigned {
+					exposures += len(assigned.Flags)
+				}
+			}
+			if apply != (exposures > 0) {
+				t.Errorf("Expected exposures to be logged only when applied, got %d with apply=%v", exposures, apply)
+			}
+		})
+	}
+}
+
+func TestLocalResolverProvider_PreviewEvaluation(t *testing.T) {
+	stateProvider := &tu.StateProviderMock{
+		State:     tu.LoadTestResolverState(t),
+		AccountID: tu.LoadTestAccountID(t),
+	}
+	flagLogger := fl.NewCapturingFlagLogger()
+	provider := NewLocalResolverProvider(lr.NewLocalResolver, stateProvider, flagLogger, "mkjJruAATQWjeY7foFIWfVAcBWnci2YF", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+
+	evalCtx := openfeature.FlattenedContext{"visitor_id": "tutorial_visitor"}
+	preview := provider.PreviewEvaluation(context.Background(), "tutorial-feature.title", "default", evalCtx)
+	evaluated := provider.ObjectEvaluation(context.Background(), "tutorial-feature.title", "default", evalCtx)
+	provider.Shutdown()
+
+	if preview.Value != "Welcome to Confidence!" || preview.Variant != evaluated.Variant {
+		t.Errorf("Expected the preview to match the evaluation, got %+v", preview)
+	}
+	exposures := 0
+	for _, request := range flagLogger.GetCapturedRequests() {
+		for _, assigned := range request.FlagAssigned {
+			exposures += len(assigned.Flags)
+		}
+	}
+	if exposures != 1 {
+		t.Errorf("Expected only the applied evaluation to be exposed, got %d exposures", exposures)
+	}
+}
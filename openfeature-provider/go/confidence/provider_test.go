@@ -1,14 +1,31 @@
 package confidence
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/open-feature/go-sdk/openfeature"
+	fl "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/flag_logger"
 	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
 	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
 	messages "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto"
+	adminv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/admin/v1"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverevents"
+	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolvertypes"
 	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/resolver"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
@@ -101,6 +118,44 @@ func TestProcessTargetingKey(t *testing.T) {
 				"other":         "value",
 			},
 		},
+		{
+			name: "Keeps an existing targeting_key",
+			input: openfeature.FlattenedContext{
+				"targeting_key": "user-123",
+				"other":         "value",
+			},
+			expected: map[string]interface{}{
+				"targeting_key": "user-123",
+				"other":         "value",
+			},
+		},
+		{
+			name: "Prefers targeting_key over targetingKey",
+			input: openfeature.FlattenedContext{
+				"targetingKey":  "user-123",
+				"targeting_key": "user-456",
+			},
+			expected: map[string]interface{}{
+				"targeting_key": "user-456",
+			},
+		},
+		{
+			name: "Converts targetingKey in nested contexts",
+			input: openfeature.FlattenedContext{
+				"targetingKey": "user-123",
+				"device": map[string]interface{}{
+					"targetingKey": "device-123",
+					"os":           "android",
+				},
+			},
+			expected: map[string]interface{}{
+				"targeting_key": "user-123",
+				"device": map[string]interface{}{
+					"targeting_key": "device-123",
+					"os":            "android",
+				},
+			},
+		},
 		{
 			name: "No targetingKey",
 			input: openfeature.FlattenedContext{
@@ -126,7 +181,7 @@ func TestProcessTargetingKey(t *testing.T) {
 			}
 
 			for key, expectedValue := range tc.expected {
-				if result[key] != expectedValue {
+				if !reflect.DeepEqual(result[key], expectedValue) {
 					t.Errorf("Expected key '%s' to have value '%v', got '%v'", key, expectedValue, result[key])
 				}
 			}
@@ -210,6 +265,39 @@ func TestGoValueToProto(t *testing.T) {
 	}
 }
 
+func TestGoValueToProto_Pointers(t *testing.T) {
+	str := "hello"
+	flag := true
+	num := 3.14
+	var nilStr *string
+
+	testCases := []struct {
+		name     string
+		input    interface{}
+		expected *structpb.Value
+	}{
+		{name: "String pointer", input: &str, expected: structpb.NewStringValue("hello")},
+		{name: "Bool pointer", input: &flag, expected: structpb.NewBoolValue(true)},
+		{name: "Float64 pointer", input: &num, expected: structpb.NewNumberValue(3.14)},
+		{name: "Nil pointer", input: nilStr, expected: structpb.NewNullValue()},
+		{name: "Pointer in map", input: map[string]interface{}{"name": &str}, expected: structpb.NewStructValue(&structpb.Struct{
+			Fields: map[string]*structpb.Value{"name": structpb.NewStringValue("hello")},
+		})},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := goValueToProto(tc.input)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !proto.Equal(result, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
 func TestProtoValueToGo(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -443,19 +531,46 @@ func TestLocalResolverProvider_ShutdownWithCancelFunc(t *testing.T) {
 	}
 }
 
+// closingStateProvider is a StateProviderMock that records whether it was closed
+type closingStateProvider struct {
+	tu.StateProviderMock
+	closed bool
+}
+
+func (c *closingStateProvider) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestLocalResolverProvider_ShutdownClosesStateProvider(t *testing.T) {
+	stateProvider := &closingStateProvider{StateProviderMock: tu.StateProviderMock{State: []byte("state"), AccountID: "account"}}
+	provider := NewLocalResolverProvider(mockResolverSupplier, stateProvider, &tu.MockFlagLogger{}, "secret", nil)
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if stateProvider.closed {
+		t.Fatal("Expected the state provider to stay open until Shutdown")
+	}
+	provider.Shutdown()
+	if !stateProvider.closed {
+		t.Error("Expected Shutdown to close the state provider")
+	}
+}
+
 // Mock implementations for Init() testing
 
 type mockResolverAPIForInit struct {
 	updateStateFunc   func(state []byte, accountID string) error
 	closeFunc         func(ctx context.Context)
 	resolveWithSticky func(request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error)
+	flushAssignLogs   func()
 }
 
 func mockResolverSupplier(_ context.Context, _ lr.LogSink) lr.LocalResolver {
 	return &mockResolverAPIForInit{}
 }
 
-func (m *mockResolverAPIForInit) SetResolverState(request *messages.SetResolverStateRequest) error {
+func (m *mockResolverAPIForInit) SetResolverState(_ context.Context, request *messages.SetResolverStateRequest) error {
 	if m.updateStateFunc != nil {
 		return m.updateStateFunc(request.State, request.AccountId)
 	}
@@ -469,18 +584,21 @@ func (m *mockResolverAPIForInit) Close(ctx context.Context) error {
 	return nil
 }
 
-func (m *mockResolverAPIForInit) ResolveWithSticky(request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+func (m *mockResolverAPIForInit) ResolveWithSticky(_ context.Context, request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
 	if m.resolveWithSticky != nil {
 		return m.resolveWithSticky(request)
 	}
 	return nil, nil
 }
 
-func (m *mockResolverAPIForInit) FlushAllLogs() error {
+func (m *mockResolverAPIForInit) FlushAllLogs(context.Context) error {
 	return nil
 }
 
-func (m *mockResolverAPIForInit) FlushAssignLogs() error {
+func (m *mockResolverAPIForInit) FlushAssignLogs(context.Context) error {
+	if m.flushAssignLogs != nil {
+		m.flushAssignLogs()
+	}
 	return nil
 }
 
@@ -569,6 +687,61 @@ func TestLocalResolverProvider_Init_StateProviderError(t *testing.T) {
 	}
 }
 
+// blockingStateProvider never returns from Provide until released, ignoring the context
+type blockingStateProvider struct {
+	release chan struct{}
+}
+
+func (b *blockingStateProvider) Provide(context.Context) ([]byte, string, error) {
+	<-b.release
+	return nil, "", errors.New("released")
+}
+
+// TestLocalResolverProvider_FetchDoesNotHoldSwapLock verifies a slow state fetch doesn't block state swaps
+func TestLocalResolverProvider_FetchDoesNotHoldSwapLock(t *testing.T) {
+	stateProvider := &blockingStateProvider{release: make(chan struct{})}
+	provider := NewLocalResolverProvider(mockResolverSupplier, stateProvider, &tu.MockFlagLogger{}, "secret", nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		provider.fetchAndUpdateState(context.Background())
+	}()
+	for provider.reloadMu.TryLock() {
+		provider.reloadMu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+
+	if !provider.swapMu.TryLock() {
+		t.Error("Expected swapMu to be free while the state is fetched")
+	} else {
+		provider.swapMu.Unlock()
+	}
+	close(stateProvider.release)
+	<-done
+}
+
+// TestLocalResolverProvider_Init_Timeout verifies Init gives up on a hanging state provider
+func TestLocalResolverProvider_Init_Timeout(t *testing.T) {
+	stateProvider := &blockingStateProvider{release: make(chan struct{})}
+	defer close(stateProvider.release)
+
+	provider := NewLocalResolverProvider(mockResolverSupplier, stateProvider, &tu.MockFlagLogger{}, "secret", nil)
+	provider.initTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	err := provider.Init(openfeature.EvaluationContext{})
+	if !errors.Is(err, ErrInitTimeout) {
+		t.Fatalf("Expected ErrInitTimeout, got: %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the error to wrap context.DeadlineExceeded, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected Init to fail fast, took %v", elapsed)
+	}
+}
+
 // TestLocalResolverProvider_Init_EmptyAccountID verifies Init fails when accountID is empty
 func TestLocalResolverProvider_Init_EmptyAccountID(t *testing.T) {
 	mockStateProvider := &tu.StateProviderMock{
@@ -635,6 +808,180 @@ func TestLocalResolverProvider_Init_UpdateStateError(t *testing.T) {
 	}
 }
 
+// TestLocalResolverProvider_Init_FailureReleasesResolver verifies a failed Init leaves no open resolver behind
+func TestLocalResolverProvider_Init_FailureReleasesResolver(t *testing.T) {
+	tests := []struct {
+		name          string
+		stateProvider StateProvider
+		setStateErr   error
+	}{
+		{name: "fetch error", stateProvider: &tu.StateProviderMock{Err: context.DeadlineExceeded}},
+		{name: "empty account", stateProvider: &tu.StateProviderMock{State: []byte("test-state")}},
+		{
+			name:          "set state error",
+			stateProvider: &tu.StateProviderMock{State: []byte("test-state"), AccountID: "test-account"},
+			setStateErr:   errors.New("invalid state"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created, closed int
+			supplier := func(_ context.Context, _ lr.LogSink) lr.LocalResolver {
+				created++
+				return &mockResolverAPIForInit{
+					updateStateFunc: func([]byte, string) error { return tt.setStateErr },
+					closeFunc:       func(context.Context) { closed++ },
+				}
+			}
+			provider := NewLocalResolverProvider(supplier, tt.stateProvider, &tu.MockFlagLogger{}, "secret", nil)
+
+			if err := provider.Init(openfeature.EvaluationContext{}); err == nil {
+				t.Fatal("Expected Init to fail")
+			}
+			if created != closed {
+				t.Errorf("Expected every created resolver to be closed, created %d, closed %d", created, closed)
+			}
+		})
+	}
+}
+
+// TestLocalResolverProvider_Init_Twice verifies a second Init replaces the first one's background tasks and resolver
+func TestLocalResolverProvider_Init_Twice(t *testing.T) {
+	var closed atomic.Int32
+	supplier := func(_ context.Context, _ lr.LogSink) lr.LocalResolver {
+		return &mockResolverAPIForInit{
+			closeFunc: func(_ context.Context) { closed.Add(1) },
+		}
+	}
+	provider := NewLocalResolverProvider(
+		supplier,
+		&tu.StateProviderMock{State: []byte("state"), AccountID: "account"},
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+
+	before := runtime.NumGoroutine()
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("First Init failed: %v", err)
+	}
+	afterFirst := runtime.NumGoroutine()
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Second Init failed: %v", err)
+	}
+	afterSecond := runtime.NumGoroutine()
+
+	if afterSecond > afterFirst {
+		t.Errorf("Expected a single poll loop, goroutines grew from %d to %d", afterFirst, afterSecond)
+	}
+	if closed.Load() != 1 {
+		t.Errorf("Expected the first resolver to be closed, got %d closes", closed.Load())
+	}
+
+	provider.Shutdown()
+	if closed.Load() != 2 {
+		t.Errorf("Expected the second resolver to be closed on shutdown, got %d closes", closed.Load())
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected no leaked goroutines, had %d before Init and %d after Shutdown", before, after)
+	}
+}
+
+// TestLocalResolverProvider_Init_TwiceWhileResolving verifies a resolve racing a re-Init is
+// retried on the new resolver instead of failing on the closed one
+func TestLocalResolverProvider_Init_TwiceWhileResolving(t *testing.T) {
+	var provider *LocalResolverProvider
+	var created atomic.Int32
+	supplier := func(_ context.Context, _ lr.LogSink) lr.LocalResolver {
+		if created.Add(1) > 1 {
+			return &mockResolverAPIForInit{
+				resolveWithSticky: func(*resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+					return &resolver.ResolveWithStickyResponse{
+						ResolveResult: &resolver.ResolveWithStickyResponse_Success_{
+							Success: &resolver.ResolveWithStickyResponse_Success{
+								Response: &resolver.ResolveFlagsResponse{},
+							},
+						},
+					}, nil
+				},
+			}
+		}
+		// The first resolver is replaced by a re-Init while resolving, closing it under the call
+		return &mockResolverAPIForInit{
+			resolveWithSticky: func(*resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+				if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+					t.Errorf("Second Init failed: %v", err)
+				}
+				return nil, lr.ErrInstanceClosed
+			},
+		}
+	}
+	provider = NewLocalResolverProvider(
+		supplier,
+		&tu.StateProviderMock{State: []byte("state"), AccountID: "account"},
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("First Init failed: %v", err)
+	}
+	defer provider.Shutdown()
+
+	result := provider.BooleanEvaluation(context.Background(), "my-flag", true, openfeature.FlattenedContext{})
+	if result.ResolutionDetail().ErrorCode != openfeature.FlagNotFoundCode {
+		t.Errorf("Expected the resolve to be retried on the new resolver, got %v", result.ResolutionError)
+	}
+}
+
+// TestLocalResolverProvider_Init_RequireNonEmptyState verifies Init rejects a state without flags when required
+func TestLocalResolverProvider_Init_RequireNonEmptyState(t *testing.T) {
+	emptyState, _ := proto.Marshal(&adminv1.ResolverState{})
+	stateWithFlag, _ := proto.Marshal(&adminv1.ResolverState{
+		Flags: []*adminv1.Flag{{Name: "flags/my-flag"}},
+	})
+
+	tests := []struct {
+		name         string
+		state        []byte
+		requireFlags bool
+		expectedErr  string
+	}{
+		{name: "empty state fails when required", state: emptyState, requireFlags: true, expectedErr: "initial state for account test-account contains no flags"},
+		{name: "empty state succeeds by default", state: emptyState},
+		{name: "state with flags succeeds when required", state: stateWithFlag, requireFlags: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewLocalResolverProvider(
+				mockResolverSupplier,
+				&tu.StateProviderMock{State: tt.state, AccountID: "test-account"},
+				&tu.MockFlagLogger{},
+				"secret",
+				nil,
+			)
+			provider.requireFlags = tt.requireFlags
+			defer provider.Shutdown()
+
+			err := provider.Init(openfeature.EvaluationContext{})
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Expected Init to succeed, got: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expectedErr {
+				t.Errorf("Expected error %q, got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
 // TestLocalResolverProvider_Init_Success verifies successful Init
 func TestLocalResolverProvider_Init_Success(t *testing.T) {
 	updateStateCalled := false
@@ -699,3 +1046,797 @@ func TestLocalResolverProvider_Init_Success(t *testing.T) {
 	// Clean up
 	provider.Shutdown()
 }
+
+// TestLocalResolverProvider_LoadedStateInfo verifies AccountID and StateHash reflect the state loaded into the resolver
+func TestLocalResolverProvider_LoadedStateInfo(t *testing.T) {
+	mockStateProvider := &tu.StateProviderMock{
+		State:     []byte("test-state-data"),
+		AccountID: "test-account-123",
+	}
+
+	provider := NewLocalResolverProvider(
+		mockResolverSupplier,
+		mockStateProvider,
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+
+	if provider.AccountID() != "" {
+		t.Errorf("Expected empty account ID before Init, got: %s", provider.AccountID())
+	}
+	if provider.StateHash() != "" {
+		t.Errorf("Expected empty state hash before Init, got: %s", provider.StateHash())
+	}
+
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer provider.Shutdown()
+
+	if provider.AccountID() != "test-account-123" {
+		t.Errorf("Expected account ID to be 'test-account-123', got: %s", provider.AccountID())
+	}
+	if provider.StateHash() != HashResolverState([]byte("test-state-data")) {
+		t.Errorf("Expected state hash of loaded state, got: %s", provider.StateHash())
+	}
+}
+
+func TestLocalResolverProvider_FlagKeys(t *testing.T) {
+	state, err := proto.Marshal(&adminv1.ResolverState{
+		Flags: []*adminv1.Flag{{Name: "flags/second"}, {Name: "flags/first"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal state: %v", err)
+	}
+	provider := NewLocalResolverProvider(
+		mockResolverSupplier,
+		&tu.StateProviderMock{State: state, AccountID: "account"},
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+
+	if _, err := provider.FlagKeys(); err == nil {
+		t.Error("Expected an error before Init")
+	}
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer provider.Shutdown()
+
+	keys, err := provider.FlagKeys()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if strings.Join(keys, ",") != "first,second" {
+		t.Errorf("Expected [first second], got: %v", keys)
+	}
+}
+
+// etagStateProvider is a StateProviderMock that reports an ETag for its state
+type etagStateProvider struct {
+	tu.StateProviderMock
+	etag string
+}
+
+func (e *etagStateProvider) ETag() string { return e.etag }
+
+func TestLocalResolverProvider_Reload(t *testing.T) {
+	stateProvider := &etagStateProvider{
+		StateProviderMock: tu.StateProviderMock{State: []byte("state-v1"), AccountID: "account"},
+		etag:              "v1",
+	}
+	var updates []bool
+	provider := NewLocalResolverProvider(mockResolverSupplier, stateProvider, &tu.MockFlagLogger{}, "secret", nil)
+	provider.onStateUpdate = func(_ string, _ int, changed bool) { updates = append(updates, changed) }
+
+	if err := provider.Reload(context.Background()); err == nil {
+		t.Error("Expected Reload to fail before Init")
+	}
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer provider.Shutdown()
+	if provider.CurrentETag() != "v1" {
+		t.Errorf("Expected ETag v1 after Init, got: %q", provider.CurrentETag())
+	}
+
+	stateProvider.State = []byte("state-v2")
+	stateProvider.etag = "v2"
+	if err := provider.Reload(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if provider.CurrentETag() != "v2" {
+		t.Errorf("Expected ETag v2 after Reload, got: %q", provider.CurrentETag())
+	}
+	if provider.StateHash() != HashResolverState([]byte("state-v2")) {
+		t.Error("Expected Reload to load the new state")
+	}
+
+	stateProvider.Err = errors.New("fetch failed")
+	if err := provider.Reload(context.Background()); err == nil {
+		t.Error("Expected Reload to report the fetch error")
+	}
+	if provider.CurrentETag() != "v2" {
+		t.Errorf("Expected a failed Reload to keep the loaded ETag, got: %q", provider.CurrentETag())
+	}
+	// Init reports the initial load, so the reload is the second update
+	if len(updates) != 2 || !updates[1] {
+		t.Errorf("Expected the reload to be reported as a changed state update, got: %v", updates)
+	}
+}
+
+func TestLocalResolverProvider_Reload_AccountIDChanged(t *testing.T) {
+	stateProvider := &tu.StateProviderMock{State: []byte("state-v1"), AccountID: "account"}
+	provider := NewLocalResolverProvider(mockResolverSupplier, stateProvider, &tu.MockFlagLogger{}, "secret", nil)
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer provider.Shutdown()
+
+	stateProvider.State = []byte("state-v2")
+	stateProvider.AccountID = "other-account"
+	if err := provider.Reload(context.Background()); !errors.Is(err, ErrAccountIDChanged) {
+		t.Errorf("Expected ErrAccountIDChanged, got: %v", err)
+	}
+	if provider.AccountID() != "account" || provider.StateHash() != HashResolverState([]byte("state-v1")) {
+		t.Error("Expected the state of the loaded account to be kept")
+	}
+
+	stateProvider.AccountID = "account"
+	if err := provider.Reload(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if provider.StateHash() != HashResolverState([]byte("state-v2")) {
+		t.Error("Expected state of the loaded account to be applied")
+	}
+}
+
+func TestLocalResolverProvider_UpdateWasm(t *testing.T) {
+	ctx := context.Background()
+	wasm, err := os.ReadFile("internal/local_resolver/assets/confidence_resolver.wasm")
+	if err != nil {
+		t.Skipf("Skipping test - could not load WASM guest: %v", err)
+	}
+
+	provider := NewLocalResolverProvider(
+		lr.NewLocalResolver,
+		&tu.StateProviderMock{
+			State:     tu.CreateMinimalResolverState(),
+			AccountID: "test-account",
+		},
+		&tu.MockFlagLogger{},
+		"test-secret",
+		nil,
+	)
+
+	if err := provider.UpdateWasm(ctx, wasm); err == nil {
+		t.Error("Expected error updating WASM before Init")
+	}
+
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer provider.Shutdown()
+
+	original := provider.getResolver()
+	if err := provider.UpdateWasm(ctx, []byte("not wasm")); err == nil {
+		t.Error("Expected error for invalid WASM bytes")
+	}
+	if provider.getResolver() != original {
+		t.Error("Expected original resolver to be kept after failed update")
+	}
+
+	if err := provider.UpdateWasm(ctx, wasm); err != nil {
+		t.Fatalf("Expected WASM update to succeed, got: %v", err)
+	}
+	if provider.getResolver() == original {
+		t.Error("Expected resolver to be swapped after successful update")
+	}
+	if provider.AccountID() != "test-account" {
+		t.Errorf("Expected account ID to be kept, got: %s", provider.AccountID())
+	}
+}
+
+// ctxRecordingResolver records the context of the last resolve
+type ctxRecordingResolver struct {
+	mockResolverAPIForInit
+	resolveCtx context.Context
+}
+
+func (r *ctxRecordingResolver) ResolveWithSticky(ctx context.Context, request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+	r.resolveCtx = ctx
+	return r.mockResolverAPIForInit.ResolveWithSticky(ctx, request)
+}
+
+func TestLocalResolverProvider_ProbeResolverUsesCallerContext(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "caller")
+	provider := NewLocalResolverProvider(mockResolverSupplier, &tu.StateProviderMock{}, &tu.MockFlagLogger{}, "secret", nil)
+	probed := &ctxRecordingResolver{}
+	if err := provider.probeResolver(ctx, probed, &messages.SetResolverStateRequest{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if probed.resolveCtx == nil || probed.resolveCtx.Value(key{}) != "caller" {
+		t.Error("Expected the probe resolve to run with the caller's context")
+	}
+}
+
+// stallableStateProvider serves state until stalled, after which every fetch fails
+type stallableStateProvider struct {
+	stalled atomic.Bool
+}
+
+func (s *stallableStateProvider) Provide(_ context.Context) ([]byte, string, error) {
+	if s.stalled.Load() {
+		return nil, "", errors.New("state fetch stalled")
+	}
+	return []byte("test-state-data"), "test-account-123", nil
+}
+
+func awaitEvent(t *testing.T, provider *LocalResolverProvider, want openfeature.EventType) {
+	t.Helper()
+	select {
+	case event := <-provider.EventChannel():
+		if event.EventType != want {
+			t.Fatalf("Expected %s event, got: %s", want, event.EventType)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for %s event", want)
+	}
+}
+
+// TestLocalResolverProvider_IsStateStale verifies a stalled poll loop turns the state stale and a reload recovers it
+func TestLocalResolverProvider_IsStateStale(t *testing.T) {
+	stateProvider := &stallableStateProvider{}
+	provider := NewLocalResolverProvider(
+		mockResolverSupplier,
+		stateProvider,
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+	provider.pollInterval = 10 * time.Millisecond
+	provider.staleThreshold = 50 * time.Millisecond
+
+	if provider.IsStateStale() {
+		t.Error("Expected state not to be stale before Init")
+	}
+	if age := provider.StateAge(); age != 0 {
+		t.Errorf("Expected zero state age before Init, got %v", age)
+	}
+
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer provider.Shutdown()
+
+	if provider.IsStateStale() {
+		t.Error("Expected state not to be stale right after Init")
+	}
+
+	stateProvider.stalled.Store(true)
+	awaitEvent(t, provider, openfeature.ProviderStale)
+	if !provider.IsStateStale() {
+		t.Error("Expected state to be stale after the poll loop stalled")
+	}
+	if age := provider.StateAge(); age <= provider.staleThreshold {
+		t.Errorf("Expected state age above the threshold, got %v", age)
+	}
+
+	stateProvider.stalled.Store(false)
+	awaitEvent(t, provider, openfeature.ProviderReady)
+	if provider.IsStateStale() {
+		t.Error("Expected state not to be stale after a successful reload")
+	}
+	if age := provider.StateAge(); age > provider.staleThreshold {
+		t.Errorf("Expected state age to reset after a successful reload, got %v", age)
+	}
+}
+
+// TestLocalResolverProvider_IsStateStale_Disabled verifies staleness is never reported without a threshold
+func TestLocalResolverProvider_IsStateStale_Disabled(t *testing.T) {
+	stateProvider := &stallableStateProvider{}
+	provider := NewLocalResolverProvider(
+		mockResolverSupplier,
+		stateProvider,
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+	provider.pollInterval = 10 * time.Millisecond
+
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer provider.Shutdown()
+
+	stateProvider.stalled.Store(true)
+	time.Sleep(100 * time.Millisecond)
+	if provider.IsStateStale() {
+		t.Error("Expected state never to be stale without a threshold")
+	}
+	select {
+	case event := <-provider.EventChannel():
+		t.Errorf("Expected no events, got: %s", event.EventType)
+	default:
+	}
+}
+
+func TestLocalResolverProvider_NextPollDelay(t *testing.T) {
+	provider := NewLocalResolverProvider(nil, nil, nil, "secret", nil)
+	provider.pollInterval = 10 * time.Second
+
+	provider.pollJitter = 0
+	if delay := provider.nextPollDelay(); delay != 10*time.Second {
+		t.Errorf("Expected unjittered delay of 10s, got: %v", delay)
+	}
+
+	provider.pollJitter = 0.1
+	spread := false
+	for i := 0; i < 100; i++ {
+		delay := provider.nextPollDelay()
+		if delay < 9*time.Second || delay > 11*time.Second {
+			t.Fatalf("Expected delay within ±10%% of 10s, got: %v", delay)
+		}
+		if delay != 10*time.Second {
+			spread = true
+		}
+	}
+	if !spread {
+		t.Error("Expected jitter to spread the poll delay")
+	}
+}
+
+// recordingHook injects an attribute before evaluation and records the stages it ran
+type recordingHook struct {
+	openfeature.UnimplementedHook
+	stages []string
+}
+
+func (h *recordingHook) Before(_ context.Context, hookCtx openfeature.HookContext, _ openfeature.HookHints) (*openfeature.EvaluationContext, error) {
+	h.stages = append(h.stages, "before")
+	attributes := hookCtx.EvaluationContext().Attributes()
+	attributes["injected"] = "by-hook"
+	evalCtx := openfeature.NewEvaluationContext(hookCtx.EvaluationContext().TargetingKey(), attributes)
+	return &evalCtx, nil
+}
+
+func (h *recordingHook) After(_ context.Context, _ openfeature.HookContext, _ openfeature.InterfaceEvaluationDetails, _ openfeature.HookHints) error {
+	h.stages = append(h.stages, "after")
+	return nil
+}
+
+func (h *recordingHook) Error(_ context.Context, _ openfeature.HookContext, _ error, _ openfeature.HookHints) {
+	h.stages = append(h.stages, "error")
+}
+
+func (h *recordingHook) Finally(_ context.Context, _ openfeature.HookContext, _ openfeature.InterfaceEvaluationDetails, _ openfeature.HookHints) {
+	h.stages = append(h.stages, "finally")
+}
+
+func TestLocalResolverProvider_Sdk(t *testing.T) {
+	var sdks []*resolvertypes.Sdk
+	mockResolver := &mockResolverAPIForInit{
+		resolveWithSticky: func(request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+			sdks = append(sdks, request.ResolveRequest.Sdk)
+			return &resolver.ResolveWithStickyResponse{
+				ResolveResult: &resolver.ResolveWithStickyResponse_Success_{
+					Success: &resolver.ResolveWithStickyResponse_Success{
+						Response: &resolver.ResolveFlagsResponse{},
+					},
+				},
+			}, nil
+		},
+	}
+	custom := &resolvertypes.Sdk{
+		Sdk:     &resolvertypes.Sdk_CustomId{CustomId: "my-wrapper"},
+		Version: "1.2.3",
+	}
+	for _, sdk := range []*resolvertypes.Sdk{nil, custom} {
+		provider := NewLocalResolverProvider(
+			func(_ context.Context, _ lr.LogSink) lr.LocalResolver { return mockResolver },
+			&tu.StateProviderMock{State: []byte("state"), AccountID: "account"},
+			&tu.MockFlagLogger{},
+			"secret",
+			nil,
+		)
+		if sdk != nil {
+			provider.sdk = sdk
+		}
+		if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+			t.Fatalf("Failed to init provider: %v", err)
+		}
+		provider.ObjectEvaluation(context.Background(), "my-flag", nil, openfeature.FlattenedContext{})
+		provider.Shutdown()
+	}
+
+	if len(sdks) != 2 {
+		t.Fatalf("Expected 2 resolves, got %d", len(sdks))
+	}
+	if sdks[0].GetId() != resolvertypes.SdkId_SDK_ID_GO_LOCAL_PROVIDER || sdks[0].GetVersion() != Version {
+		t.Errorf("Expected the default SDK, got %v", sdks[0])
+	}
+	if sdks[1].GetCustomId() != "my-wrapper" || sdks[1].GetVersion() != "1.2.3" {
+		t.Errorf("Expected the custom SDK, got %v", sdks[1])
+	}
+}
+
+func TestLocalResolverProvider_DebugResolveLog(t *testing.T) {
+	supplier := NewScriptedResolver(map[string]*resolver.ResolveFlagsResponse{
+		"my-flag": {ResolvedFlags: []*resolver.ResolvedFlag{{
+			Flag:    "flags/my-flag",
+			Variant: "flags/my-flag/variants/on",
+			Value:   &structpb.Struct{Fields: map[string]*structpb.Value{"secret": structpb.NewStringValue("private-value")}},
+			Reason:  resolvertypes.ResolveReason_RESOLVE_REASON_MATCH,
+		}}},
+	})
+	hashed := sha256.Sum256([]byte("user-1"))
+
+	testCases := []struct {
+		name     string
+		level    slog.Level
+		redactor *contextRedactor
+		expected string
+	}{
+		{name: "Debug", level: slog.LevelDebug, expected: "targeting_key=user-1"},
+		{name: "Redacted", level: slog.LevelDebug, redactor: newContextRedactor([]string{"targetingKey"}, RedactHash), expected: "targeting_key=" + hex.EncodeToString(hashed[:])},
+		{name: "Info", level: slog.LevelInfo},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: tc.level}))
+			provider := NewLocalResolverProvider(supplier, &tu.StateProviderMock{State: []byte("state"), AccountID: "account"}, &tu.MockFlagLogger{}, "secret", logger)
+			provider.redactor = tc.redactor
+			if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+				t.Fatalf("Failed to init provider: %v", err)
+			}
+			defer provider.Shutdown()
+			buf.Reset()
+
+			provider.StringEvaluation(context.Background(), "my-flag.secret", "", openfeature.FlattenedContext{"targetingKey": "user-1"})
+
+			var line string
+			for _, l := range strings.Split(buf.String(), "\n") {
+				if strings.Contains(l, "Resolved flag") {
+					line = l
+				}
+			}
+			if tc.expected == "" {
+				if line != "" {
+					t.Errorf("Expected no resolve log above debug level, got: %s", line)
+				}
+				return
+			}
+			for _, part := range []string{"flag=my-flag.secret", "variant=flags/my-flag/variants/on", "reason=TARGETING_MATCH", tc.expected} {
+				if !strings.Contains(line, part) {
+					t.Errorf("Expected %q in resolve log, got: %s", part, line)
+				}
+			}
+			if strings.Contains(buf.String(), "private-value") {
+				t.Error("Expected the resolved value to be left out of the log")
+			}
+		})
+	}
+}
+
+func TestLocalResolverProvider_ConfiguredHooks(t *testing.T) {
+	var captured *structpb.Struct
+	mockResolver := &mockResolverAPIForInit{
+		resolveWithSticky: func(request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+			captured = request.ResolveRequest.EvaluationContext
+			return &resolver.ResolveWithStickyResponse{
+				ResolveResult: &resolver.ResolveWithStickyResponse_Success_{
+					Success: &resolver.ResolveWithStickyResponse_Success{
+						Response: &resolver.ResolveFlagsResponse{},
+					},
+				},
+			}, nil
+		},
+	}
+	hook := &recordingHook{}
+	provider, err := NewProviderForTest(context.Background(), ProviderTestConfig{
+		StateProvider: &tu.StateProviderMock{State: []byte("state"), AccountID: "account"},
+		FlagLogger:    &tu.MockFlagLogger{},
+		ClientSecret:  "secret",
+		Hooks:         []openfeature.Hook{hook},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	provider.resolverSupplier = func(_ context.Context, _ lr.LogSink) lr.LocalResolver { return mockResolver }
+
+	if hooks := provider.Hooks(); len(hooks) != 1 {
+		t.Fatalf("Expected 1 hook, got %d", len(hooks))
+	}
+
+	if err := openfeature.SetNamedProviderAndWait("configured-hooks", provider); err != nil {
+		t.Fatalf("Failed to set provider: %v", err)
+	}
+	defer provider.Shutdown()
+
+	client := openfeature.NewClient("configured-hooks")
+	client.BooleanValue(context.Background(), "my-flag", false, openfeature.NewEvaluationContext("user-1", nil))
+
+	if captured == nil || captured.Fields["injected"].GetStringValue() != "by-hook" {
+		t.Errorf("Expected hook to inject context attribute, got: %v", captured)
+	}
+	// The mock resolver returns no flags, so evaluation ends in FLAG_NOT_FOUND
+	expected := []string{"before", "error", "finally"}
+	if len(hook.stages) != len(expected) {
+		t.Fatalf("Expected hook stages %v, got %v", expected, hook.stages)
+	}
+	for i, stage := range expected {
+		if hook.stages[i] != stage {
+			t.Errorf("Expected hook stages %v, got %v", expected, hook.stages)
+			break
+		}
+	}
+}
+
+func TestLocalResolverProvider_FlushObserver(t *testing.T) {
+	capturingLogger := fl.NewCapturingFlagLogger()
+	provider := NewLocalResolverProvider(nil, nil, capturingLogger, "secret", nil)
+
+	var summaries []FlushSummary
+	provider.flushObserver = func(summary FlushSummary, request *resolverv1.WriteFlagLogsRequest) {
+		summaries = append(summaries, summary)
+		// Keep only the first exposure
+		request.FlagAssigned = request.FlagAssigned[:1]
+	}
+
+	provider.writeLogs(&resolverv1.WriteFlagLogsRequest{
+		FlagAssigned: []*resolverevents.FlagAssigned{
+			{ResolveId: "resolve-1"},
+			{ResolveId: "resolve-2"},
+		},
+	})
+
+	if len(summaries) != 1 {
+		t.Fatalf("Expected observer to be called once, got %d", len(summaries))
+	}
+	if summaries[0] != (FlushSummary{FlagAssigned: 2}) {
+		t.Errorf("Expected summary with 2 flag assigned, got: %+v", summaries[0])
+	}
+	requests := capturingLogger.GetCapturedRequests()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 written request, got %d", len(requests))
+	}
+	if len(requests[0].FlagAssigned) != 1 || requests[0].FlagAssigned[0].ResolveId != "resolve-1" {
+		t.Errorf("Expected observer modifications to be written, got: %v", requests[0].FlagAssigned)
+	}
+}
+
+func TestLocalResolverProvider_SetLogger(t *testing.T) {
+	provider := NewLocalResolverProvider(nil, nil, nil, "secret", nil)
+	if provider.log() == nil {
+		t.Fatal("Expected a default logger")
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	provider.SetLogger(logger)
+	provider.SetLogger(nil)
+	if provider.log() != logger {
+		t.Fatal("Expected logger to be replaced and nil to be ignored")
+	}
+
+	provider.Shutdown()
+	if !strings.Contains(buf.String(), "Shutting down provider") {
+		t.Errorf("Expected provider to log to the new logger, got: %s", buf.String())
+	}
+}
+
+func TestLocalResolverProvider_StateFetchInfo(t *testing.T) {
+	stateProvider := &stallableStateProvider{}
+	provider := NewLocalResolverProvider(
+		mockResolverSupplier,
+		stateProvider,
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+	provider.pollInterval = 20 * time.Millisecond
+
+	if !provider.NextStateFetch().IsZero() {
+		t.Error("Expected no next fetch before Init")
+	}
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	next := provider.NextStateFetch()
+	maxDelay := time.Duration(float64(provider.pollInterval) * (1 + provider.pollJitter))
+	if next.IsZero() || next.After(time.Now().Add(maxDelay)) {
+		t.Errorf("Expected next fetch within the jittered poll interval, got %v", next)
+	}
+
+	stateProvider.stalled.Store(true)
+	deadline := time.Now().Add(2 * time.Second)
+	for provider.LastStateFetchError() == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := provider.LastStateFetchError(); err == nil || !strings.Contains(err.Error(), "state fetch stalled") {
+		t.Errorf("Expected last fetch error to report the stalled fetch, got: %v", err)
+	}
+
+	stateProvider.stalled.Store(false)
+	deadline = time.Now().Add(2 * time.Second)
+	for provider.LastStateFetchError() != nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := provider.LastStateFetchError(); err != nil {
+		t.Errorf("Expected last fetch error to clear after a successful poll, got: %v", err)
+	}
+
+	provider.Shutdown()
+	if !provider.NextStateFetch().IsZero() {
+		t.Error("Expected no next fetch after Shutdown")
+	}
+}
+
+// switchableStateProvider serves the stored state, or an error while failing is set
+type switchableStateProvider struct {
+	state   atomic.Value // stores []byte
+	failing atomic.Bool
+}
+
+func (s *switchableStateProvider) Provide(_ context.Context) ([]byte, string, error) {
+	if s.failing.Load() {
+		return nil, "", errors.New("state fetch failed")
+	}
+	return s.state.Load().([]byte), "test-account-123", nil
+}
+
+func TestLocalResolverProvider_StateUpdateCallbacks(t *testing.T) {
+	type update struct {
+		accountId  string
+		stateBytes int
+		changed    bool
+	}
+	updates := make(chan update, 100)
+	failures := make(chan error, 100)
+
+	stateProvider := &switchableStateProvider{}
+	stateProvider.state.Store([]byte("state-v1"))
+	provider := NewLocalResolverProvider(
+		mockResolverSupplier,
+		stateProvider,
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+	provider.pollInterval = 10 * time.Millisecond
+	provider.onStateUpdate = func(accountId string, stateBytes int, changed bool) {
+		updates <- update{accountId, stateBytes, changed}
+	}
+	provider.onStateUpdateErr = func(err error) { failures <- err }
+
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer provider.Shutdown()
+
+	awaitUpdate := func(changed bool) update {
+		t.Helper()
+		deadline := time.After(2 * time.Second)
+		for {
+			select {
+			case u := <-updates:
+				if u.changed == changed {
+					return u
+				}
+			case <-deadline:
+				t.Fatalf("Timed out waiting for an update with changed=%v", changed)
+			}
+		}
+	}
+
+	if got := awaitUpdate(true); got != (update{"test-account-123", len("state-v1"), true}) {
+		t.Errorf("Expected the initial load to be reported as changed, got %+v", got)
+	}
+	if got := awaitUpdate(false); got.stateBytes != len("state-v1") {
+		t.Errorf("Expected an unchanged poll of the same state, got %+v", got)
+	}
+
+	stateProvider.state.Store([]byte("state-v2-longer"))
+	if got := awaitUpdate(true); got.stateBytes != len("state-v2-longer") {
+		t.Errorf("Expected the new state to be reported, got %+v", got)
+	}
+
+	stateProvider.failing.Store(true)
+	select {
+	case err := <-failures:
+		if !strings.Contains(err.Error(), "state fetch failed") {
+			t.Errorf("Expected the fetch error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the error callback")
+	}
+}
+
+func TestLocalResolverProvider_AssignFlushInterval(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		interval      time.Duration
+		expectFlushes bool
+	}{
+		{name: "periodic", interval: 5 * time.Millisecond, expectFlushes: true},
+		{name: "disabled", interval: -1, expectFlushes: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var flushes atomic.Int32
+			mockResolver := &mockResolverAPIForInit{flushAssignLogs: func() { flushes.Add(1) }}
+			provider := NewLocalResolverProvider(
+				func(_ context.Context, _ lr.LogSink) lr.LocalResolver { return mockResolver },
+				&tu.StateProviderMock{State: []byte("state"), AccountID: "account"},
+				&tu.MockFlagLogger{},
+				"secret",
+				nil,
+			)
+			provider.pollInterval = time.Hour
+			provider.flushInterval = tc.interval
+			if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			time.Sleep(100 * time.Millisecond)
+			provider.Shutdown()
+
+			if got := flushes.Load() > 0; got != tc.expectFlushes {
+				t.Errorf("Expected periodic flushes %v, got %d flushes", tc.expectFlushes, flushes.Load())
+			}
+		})
+	}
+}
+
+func TestLocalResolverProvider_Ready(t *testing.T) {
+	stateProvider := &tu.StateProviderMock{State: []byte("state"), AccountID: "account"}
+	provider := NewLocalResolverProvider(mockResolverSupplier, stateProvider, &tu.MockFlagLogger{}, "secret", nil)
+
+	select {
+	case <-provider.Ready():
+		t.Fatal("Expected provider to not be ready before Init")
+	default:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := provider.WaitUntilReady(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the wait to time out before Init, got %v", err)
+	}
+
+	// A failed Init leaves the provider not ready
+	stateProvider.Err = errors.New("fetch failed")
+	if err := provider.Init(openfeature.EvaluationContext{}); err == nil {
+		t.Fatal("Expected Init to fail")
+	}
+	select {
+	case <-provider.Ready():
+		t.Fatal("Expected provider to not be ready after a failed Init")
+	default:
+	}
+
+	stateProvider.Err = nil
+	waited := make(chan error, 1)
+	go func() { waited <- provider.WaitUntilReady(context.Background()) }()
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer provider.Shutdown()
+
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Errorf("Expected WaitUntilReady to succeed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected WaitUntilReady to return after Init")
+	}
+
+	// Reinitializing keeps the provider ready
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	<-provider.Ready()
+}
@@ -0,0 +1,72 @@
+package confidence
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+)
+
+// RateLimitedReason is returned, together with the default value, for evaluations
+// rejected by the resolve rate limiter
+const RateLimitedReason openfeature.Reason = "RATE_LIMITED"
+
+// rateLimitChargedKey marks a context whose resolves were already charged to the rate
+// limiter, e.g. the resolves making up one ResolveWithToken call
+type rateLimitChargedKey struct{}
+
+// allowResolve charges a resolve to the rate limiter, unless there is none or ctx was
+// already charged, and reports whether the resolve may proceed
+func (p *LocalResolverProvider) allowResolve(ctx context.Context) bool {
+	if p.rateLimiter == nil {
+		return true
+	}
+	if charged, _ := ctx.Value(rateLimitChargedKey{}).(bool); charged {
+		return true
+	}
+	return p.rateLimiter.allow()
+}
+
+// tokenBucket is a token bucket rate limiter refilled at qps tokens per second up to burst
+type tokenBucket struct {
+	mu     sync.Mutex
+	qps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(qps float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	b := &tokenBucket{
+		qps:   qps,
+		burst: float64(burst),
+		now:   time.Now,
+	}
+	b.tokens = b.burst
+	b.last = b.now()
+	return b
+}
+
+// allow takes a token if one is available
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.qps
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,65 @@
+package confidence
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestTokenBucket_Allow(t *testing.T) {
+	now := time.Unix(0, 0)
+	bucket := newTokenBucket(10, 2)
+	bucket.now = func() time.Time { return now }
+	bucket.last = now
+
+	if !bucket.allow() || !bucket.allow() {
+		t.Fatal("Expected burst of 2 to be allowed")
+	}
+	if bucket.allow() {
+		t.Error("Expected third call to be rejected once the burst is used up")
+	}
+
+	// 10 qps refills one token every 100ms
+	now = now.Add(100 * time.Millisecond)
+	if !bucket.allow() {
+		t.Error("Expected a token after 100ms")
+	}
+	if bucket.allow() {
+		t.Error("Expected only one token after 100ms")
+	}
+
+	// Refill never exceeds the burst
+	now = now.Add(time.Hour)
+	allowed := 0
+	for bucket.allow() {
+		allowed++
+	}
+	if allowed != 2 {
+		t.Errorf("Expected refill to be capped at burst 2, got %d", allowed)
+	}
+}
+
+func TestLocalResolverProvider_RateLimited(t *testing.T) {
+	var captured []*structpb.Struct
+	provider := newCapturingProvider(t, &captured)
+	provider.rateLimiter = newTokenBucket(0.001, 1)
+
+	provider.ObjectEvaluation(context.Background(), "my-flag", "default", openfeature.FlattenedContext{})
+	result := provider.ObjectEvaluation(context.Background(), "my-flag", "default", openfeature.FlattenedContext{})
+
+	if len(captured) != 1 {
+		t.Errorf("Expected only the first evaluation to reach the resolver, got %d", len(captured))
+	}
+	if result.Value != "default" {
+		t.Errorf("Expected default value, got: %v", result.Value)
+	}
+	if result.Reason != RateLimitedReason {
+		t.Errorf("Expected reason %s, got: %s", RateLimitedReason, result.Reason)
+	}
+	if result.ResolutionDetail().ErrorCode != openfeature.GeneralCode {
+		t.Errorf("Expected GENERAL error code, got: %s", result.ResolutionDetail().ErrorCode)
+	}
+}
@@ -0,0 +1,69 @@
+package confidence
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
+)
+
+// RedactionMode selects how values of sensitive context keys are removed from flag logs
+type RedactionMode int
+
+const (
+	// RedactHash replaces sensitive values with their hex-encoded SHA-256
+	RedactHash RedactionMode = iota
+	// RedactDrop removes sensitive values
+	RedactDrop
+)
+
+// contextRedactor removes values of sensitive context keys from flag logs.
+//
+// The evaluation context passed to the resolver is left untouched so the keys can still be
+// used for targeting. The only context values the resolver writes to flag logs are targeting
+// keys, so those are redacted when their selector is one of the sensitive keys.
+type contextRedactor struct {
+	keys map[string]bool
+	mode RedactionMode
+}
+
+func newContextRedactor(keys []string, mode RedactionMode) *contextRedactor {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[key] = true
+	}
+	return &contextRedactor{keys: set, mode: mode}
+}
+
+// redactFlagLogs redacts sensitive targeting keys in place
+func (r *contextRedactor) redactFlagLogs(request *resolverv1.WriteFlagLogsRequest) {
+	for _, assigned := range request.FlagAssigned {
+		for _, flag := range assigned.Flags {
+			if r.keys[flag.TargetingKeySelector] {
+				flag.TargetingKey = r.redact(flag.TargetingKey)
+			}
+			for _, assignment := range flag.FallthroughAssignments {
+				if r.keys[assignment.TargetingKeySelector] {
+					assignment.TargetingKey = r.redact(assignment.TargetingKey)
+				}
+			}
+		}
+	}
+}
+
+// redactTargetingKey redacts the OpenFeature targeting key if it is listed as sensitive,
+// either as "targetingKey" or as the resolver's "targeting_key"
+func (r *contextRedactor) redactTargetingKey(value string) string {
+	if r.keys["targetingKey"] || r.keys["targeting_key"] {
+		return r.redact(value)
+	}
+	return value
+}
+
+func (r *contextRedactor) redact(value string) string {
+	if r.mode == RedactDrop || value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
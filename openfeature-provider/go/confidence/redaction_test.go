@@ -0,0 +1,75 @@
+package confidence
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	fl "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/flag_logger"
+	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
+	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLocalResolverProvider_SensitiveContextKeys(t *testing.T) {
+	const visitor = "tutorial_visitor"
+	hashed := sha256.Sum256([]byte(visitor))
+
+	testCases := []struct {
+		name        string
+		mode        RedactionMode
+		expectedKey string
+	}{
+		{name: "Hash", mode: RedactHash, expectedKey: hex.EncodeToString(hashed[:])},
+		{name: "Drop", mode: RedactDrop, expectedKey: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stateProvider := &tu.StateProviderMock{
+				State:     tu.LoadTestResolverState(t),
+				AccountID: tu.LoadTestAccountID(t),
+			}
+			flagLogger := fl.NewCapturingFlagLogger()
+			provider := NewLocalResolverProvider(lr.NewLocalResolver, stateProvider, flagLogger, "mkjJruAATQWjeY7foFIWfVAcBWnci2YF", slog.New(slog.NewTextHandler(os.Stderr, nil)))
+			provider.redactor = newContextRedactor([]string{"visitor_id"}, tc.mode)
+			if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+				t.Fatalf("Failed to init provider: %v", err)
+			}
+
+			// Targeting on the sensitive key still works
+			result := provider.ObjectEvaluation(context.Background(), "tutorial-feature", nil, openfeature.FlattenedContext{"visitor_id": visitor})
+			if result.Reason != openfeature.TargetingMatchReason {
+				t.Fatalf("Expected TargetingMatchReason, got %v (%v)", result.Reason, result.Error())
+			}
+			provider.Shutdown()
+
+			exposures := 0
+			for _, request := range flagLogger.GetCapturedRequests() {
+				data, err := proto.Marshal(request)
+				if err != nil {
+					t.Fatalf("Failed to marshal flag logs: %v", err)
+				}
+				if bytes.Contains(data, []byte(visitor)) {
+					t.Errorf("Expected %q to be redacted from flushed logs", visitor)
+				}
+				for _, assigned := range request.FlagAssigned {
+					for _, flag := range assigned.Flags {
+						exposures++
+						if flag.TargetingKey != tc.expectedKey {
+							t.Errorf("Expected targeting key %q, got %q", tc.expectedKey, flag.TargetingKey)
+						}
+					}
+				}
+			}
+			if exposures == 0 {
+				t.Error("Expected the exposure to be flushed")
+			}
+		})
+	}
+}
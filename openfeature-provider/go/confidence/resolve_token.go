@@ -0,0 +1,205 @@
+package confidence
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
+	"google.golang.org/protobuf/proto"
+)
+
+// ResolveWithToken evaluates a flag for a resolve token previously issued by a local resolver
+// (ResolveFlagsResponse.ResolveToken), e.g. when one service resolves and another applies.
+// The flag is re-resolved against the evaluation context stored in the token and the exposure
+// is only logged if the result matches the assignment recorded in the token. If the resolver
+// state has changed so that the assignment differs, an error is returned and nothing is logged,
+// unless the state is swapped between the check and the applied resolve.
+//
+// Successful results are cached per token and flag for a short time, so retried applies
+// of the same token return the cached result without resolving or logging the exposure again.
+// The cache is dropped when the resolver state changes and on Shutdown.
+// A call counts as one resolve towards RateLimitQPS.
+func (p *LocalResolverProvider) ResolveWithToken(ctx context.Context, flag string, token []byte) openfeature.InterfaceResolutionDetail {
+	var result openfeature.InterfaceResolutionDetail
+	p.measureResolve(func() openfeature.Reason {
+		result = p.resolveWithToken(ctx, flag, token)
+		return result.Reason
+	})
+	return result
+}
+
+func (p *LocalResolverProvider) resolveWithToken(ctx context.Context, flag string, token []byte) openfeature.InterfaceResolutionDetail {
+	cacheKey := p.tokenCacheKey(flag, token)
+	if detail, ok := p.tokenResults.get(cacheKey); ok {
+		return detail
+	}
+	if !p.allowResolve(ctx) {
+		return openfeature.InterfaceResolutionDetail{
+			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
+				Reason:          RateLimitedReason,
+				ResolutionError: openfeature.NewGeneralResolutionError("resolve rate limit exceeded"),
+			},
+		}
+	}
+	ctx = context.WithValue(ctx, rateLimitChargedKey{}, true)
+
+	tokenV1, err := decodeResolveToken(token)
+	if err != nil {
+		return openfeature.InterfaceResolutionDetail{
+			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
+				Reason:          openfeature.ErrorReason,
+				ResolutionError: openfeature.NewGeneralResolutionError(err.Error()),
+			},
+		}
+	}
+
+	flagPath, _ := parseFlagPath(flag)
+	assigned, ok := tokenV1.GetAssignments()["flags/"+flagPath]
+	if !ok {
+		return openfeature.InterfaceResolutionDetail{
+			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
+				Reason:          openfeature.ErrorReason,
+				ResolutionError: openfeature.NewFlagNotFoundResolutionError(fmt.Sprintf("flag '%s' not found in resolve token", flagPath)),
+			},
+		}
+	}
+
+	// Check the assignment before applying, so a diverged resolve is never logged as an exposure
+	detail := p.resolve(ctx, flag, nil, tokenV1.GetEvaluationContext(), false)
+	if detail.Error() != nil {
+		return detail
+	}
+	if detail.Variant != assigned.GetVariant() {
+		return p.tokenMismatch(flagPath, assigned.GetVariant(), detail.Variant)
+	}
+
+	// The state may have been swapped since the check, so the applied result is checked again.
+	// Its exposure has been logged by then, but the caller gets an error rather than a value
+	// that disagrees with the token.
+	detail = p.resolve(ctx, flag, nil, tokenV1.GetEvaluationContext(), true)
+	if detail.Error() != nil {
+		return detail
+	}
+	if detail.Variant != assigned.GetVariant() {
+		return p.tokenMismatch(flagPath, assigned.GetVariant(), detail.Variant)
+	}
+	p.tokenResults.put(cacheKey, detail)
+	return detail
+}
+
+// tokenMismatch reports a resolve whose variant differs from the one assigned in the token
+func (p *LocalResolverProvider) tokenMismatch(flagPath, tokenVariant, resolvedVariant string) openfeature.InterfaceResolutionDetail {
+	p.log().Warn("Resolve token assignment no longer matches resolver state",
+		"flag", flagPath, "token_variant", tokenVariant, "resolved_variant", resolvedVariant)
+	return openfeature.InterfaceResolutionDetail{
+		ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
+			Reason:          openfeature.ErrorReason,
+			ResolutionError: openfeature.NewGeneralResolutionError(fmt.Sprintf("resolve token assignment for flag '%s' no longer matches resolver state", flagPath)),
+		},
+	}
+}
+
+// tokenCacheKey identifies a token resolve of a flag against the currently loaded state,
+// so that cached results never outlive the state they were resolved against
+func (p *LocalResolverProvider) tokenCacheKey(flag string, token []byte) string {
+	stateHash := p.StateHash()
+	var b strings.Builder
+	b.Grow(len(stateHash) + len(flag) + len(token) + 2)
+	b.WriteString(stateHash)
+	b.WriteByte(0)
+	b.WriteString(flag)
+	b.WriteByte(0)
+	b.Write(token)
+	return b.String()
+}
+
+// decodeResolveToken parses a token issued by the local resolver. The WASM guest does not
+// encrypt resolve tokens, so the token is the serialized ResolveToken message.
+func decodeResolveToken(token []byte) (*resolverv1.ResolveTokenV1, error) {
+	if len(token) == 0 {
+		return nil, fmt.Errorf("empty resolve token")
+	}
+	var decoded resolverv1.ResolveToken
+	if err := proto.Unmarshal(token, &decoded); err != nil {
+		return nil, fmt.Errorf("invalid resolve token: %w", err)
+	}
+	tokenV1 := decoded.GetTokenV1()
+	if tokenV1 == nil {
+		return nil, fmt.Errorf("unsupported resolve token version")
+	}
+	return tokenV1, nil
+}
+
+const (
+	// tokenResultTTL is how long a token resolve result is reused for retried applies
+	tokenResultTTL = 30 * time.Second
+	// maxTokenResults bounds the token result cache
+	maxTokenResults = 10000
+)
+
+// tokenResultCache caches successful token resolve results for a short TTL
+type tokenResultCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	now     func() time.Time
+	entries map[string]tokenResult
+}
+
+type tokenResult struct {
+	detail  openfeature.InterfaceResolutionDetail
+	expires time.Time
+}
+
+func newTokenResultCache(ttl time.Duration, max int) *tokenResultCache {
+	return &tokenResultCache{
+		ttl:     ttl,
+		max:     max,
+		now:     time.Now,
+		entries: make(map[string]tokenResult),
+	}
+}
+
+func (c *tokenResultCache) get(key string) (openfeature.InterfaceResolutionDetail, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return openfeature.InterfaceResolutionDetail{}, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return openfeature.InterfaceResolutionDetail{}, false
+	}
+	return entry.detail, true
+}
+
+// clear drops all cached results
+func (c *tokenResultCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// put stores a result. When the cache is full, expired entries are evicted first and
+// the result is not cached if that doesn't free up room.
+func (c *tokenResultCache) put(key string, detail openfeature.InterfaceResolutionDetail) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.max {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.max {
+			return
+		}
+	}
+	c.entries[key] = tokenResult{detail: detail, expires: now.Add(c.ttl)}
+}
@@ -0,0 +1,241 @@
+package confidence
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
+	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
+	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/resolver"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newResolveToken(t *testing.T, evalCtx map[string]interface{}, flag, variant string) []byte {
+	protoCtx, err := structpb.NewStruct(evalCtx)
+	if err != nil {
+		t.Fatalf("Failed to build context: %v", err)
+	}
+	token, err := proto.Marshal(&resolverv1.ResolveToken{
+		ResolveToken: &resolverv1.ResolveToken_TokenV1{
+			TokenV1: &resolverv1.ResolveTokenV1{
+				EvaluationContext: protoCtx,
+				Assignments: map[string]*resolverv1.ResolveTokenV1_AssignedFlag{
+					flag: {Flag: flag, Variant: variant},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal token: %v", err)
+	}
+	return token
+}
+
+func TestLocalResolverProvider_ResolveWithToken(t *testing.T) {
+	var applies []bool
+	var contexts []*structpb.Struct
+	mockResolver := &mockResolverAPIForInit{
+		resolveWithSticky: func(request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+			applies = append(applies, request.ResolveRequest.Apply)
+			contexts = append(contexts, request.ResolveRequest.EvaluationContext)
+			return &resolver.ResolveWithStickyResponse{
+				ResolveResult: &resolver.ResolveWithStickyResponse_Success_{
+					Success: &resolver.ResolveWithStickyResponse_Success{
+						Response: &resolver.ResolveFlagsResponse{
+							ResolvedFlags: []*resolver.ResolvedFlag{{
+								Flag:    "flags/my-flag",
+								Variant: "flags/my-flag/variants/on",
+								Value:   &structpb.Struct{Fields: map[string]*structpb.Value{"enabled": structpb.NewBoolValue(true)}},
+							}},
+						},
+					},
+				},
+			}, nil
+		},
+	}
+	provider := NewLocalResolverProvider(
+		func(_ context.Context, _ lr.LogSink) lr.LocalResolver { return mockResolver },
+		&tu.StateProviderMock{State: []byte("state"), AccountID: "account"},
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+	defer provider.Shutdown()
+
+	t.Run("matching assignment is applied", func(t *testing.T) {
+		applies, contexts = nil, nil
+		token := newResolveToken(t, map[string]interface{}{"targeting_key": "user-1"}, "flags/my-flag", "flags/my-flag/variants/on")
+
+		detail := provider.ResolveWithToken(context.Background(), "my-flag.enabled", token)
+		if detail.Error() != nil {
+			t.Fatalf("Expected no error, got %v", detail.Error())
+		}
+		if detail.Value != true {
+			t.Errorf("Expected value true, got %v", detail.Value)
+		}
+		if detail.Variant != "flags/my-flag/variants/on" {
+			t.Errorf("Expected variant from token, got %q", detail.Variant)
+		}
+		if len(applies) != 2 || applies[0] || !applies[1] {
+			t.Errorf("Expected a check resolve followed by an applied resolve, got %v", applies)
+		}
+		if got := contexts[0].Fields["targeting_key"].GetStringValue(); got != "user-1" {
+			t.Errorf("Expected the token's evaluation context, got targeting_key %q", got)
+		}
+	})
+
+	t.Run("retried token is served from cache", func(t *testing.T) {
+		applies, contexts = nil, nil
+		token := newResolveToken(t, map[string]interface{}{"targeting_key": "user-2"}, "flags/my-flag", "flags/my-flag/variants/on")
+
+		first := provider.ResolveWithToken(context.Background(), "my-flag", token)
+		second := provider.ResolveWithToken(context.Background(), "my-flag", token)
+		if second.Error() != nil || second.Variant != first.Variant {
+			t.Errorf("Expected the cached result, got %+v", second)
+		}
+		if len(applies) != 2 {
+			t.Errorf("Expected only the first call to resolve, got %d resolves", len(applies))
+		}
+	})
+
+	t.Run("state swap drops cached results", func(t *testing.T) {
+		applies, contexts = nil, nil
+		token := newResolveToken(t, map[string]interface{}{"targeting_key": "user-5"}, "flags/my-flag", "flags/my-flag/variants/on")
+
+		provider.ResolveWithToken(context.Background(), "my-flag", token)
+		provider.swapMu.Lock()
+		err := provider.updateStateLocked(context.Background(), []byte("new state"), "account", "")
+		provider.swapMu.Unlock()
+		if err != nil {
+			t.Fatalf("Failed to update state: %v", err)
+		}
+		provider.ResolveWithToken(context.Background(), "my-flag", token)
+		if len(applies) != 4 {
+			t.Errorf("Expected the token to be resolved again against the new state, got %d resolves", len(applies))
+		}
+	})
+
+	t.Run("diverged assignment is not applied", func(t *testing.T) {
+		applies, contexts = nil, nil
+		token := newResolveToken(t, map[string]interface{}{"targeting_key": "user-1"}, "flags/my-flag", "flags/my-flag/variants/off")
+
+		detail := provider.ResolveWithToken(context.Background(), "my-flag", token)
+		if detail.ResolutionError.Error() == "" || detail.Reason != openfeature.ErrorReason {
+			t.Errorf("Expected an error for a diverged assignment, got %+v", detail)
+		}
+		if len(applies) != 1 || applies[0] {
+			t.Errorf("Expected only the unapplied check resolve, got %v", applies)
+		}
+	})
+
+	t.Run("rate limiter is charged once", func(t *testing.T) {
+		applies, contexts = nil, nil
+		provider.rateLimiter = newTokenBucket(0.001, 1)
+		defer func() { provider.rateLimiter = nil }()
+		token := newResolveToken(t, map[string]interface{}{"targeting_key": "user-3"}, "flags/my-flag", "flags/my-flag/variants/on")
+
+		if detail := provider.ResolveWithToken(context.Background(), "my-flag", token); detail.Error() != nil {
+			t.Fatalf("Expected a single token to cover both resolves, got %v", detail.Error())
+		}
+		other := newResolveToken(t, map[string]interface{}{"targeting_key": "user-4"}, "flags/my-flag", "flags/my-flag/variants/on")
+		if detail := provider.ResolveWithToken(context.Background(), "my-flag", other); detail.Reason != RateLimitedReason {
+			t.Errorf("Expected the next call to be rate limited, got %+v", detail)
+		}
+	})
+
+	t.Run("flag missing from token", func(t *testing.T) {
+		token := newResolveToken(t, map[string]interface{}{}, "flags/other-flag", "flags/other-flag/variants/on")
+
+		detail := provider.ResolveWithToken(context.Background(), "my-flag", token)
+		if detail.ResolutionError.Error() != "FLAG_NOT_FOUND: flag 'my-flag' not found in resolve token" {
+			t.Errorf("Expected FLAG_NOT_FOUND, got %q", detail.ResolutionError.Error())
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		detail := provider.ResolveWithToken(context.Background(), "my-flag", []byte("not a token"))
+		if detail.Reason != openfeature.ErrorReason {
+			t.Errorf("Expected ErrorReason for an invalid token, got %v", detail.Reason)
+		}
+	})
+}
+
+func TestLocalResolverProvider_ResolveWithToken_StateSwappedBeforeApply(t *testing.T) {
+	// The check resolve matches the token, the applied one sees a swapped state
+	mockResolver := &mockResolverAPIForInit{
+		resolveWithSticky: func(request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+			variant := "flags/my-flag/variants/on"
+			if request.ResolveRequest.Apply {
+				variant = "flags/my-flag/variants/off"
+			}
+			return &resolver.ResolveWithStickyResponse{
+				ResolveResult: &resolver.ResolveWithStickyResponse_Success_{
+					Success: &resolver.ResolveWithStickyResponse_Success{
+						Response: &resolver.ResolveFlagsResponse{
+							ResolvedFlags: []*resolver.ResolvedFlag{{Flag: "flags/my-flag", Variant: variant}},
+						},
+					},
+				},
+			}, nil
+		},
+	}
+	provider := NewLocalResolverProvider(
+		func(_ context.Context, _ lr.LogSink) lr.LocalResolver { return mockResolver },
+		&tu.StateProviderMock{State: []byte("state"), AccountID: "account"},
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+	defer provider.Shutdown()
+
+	token := newResolveToken(t, map[string]interface{}{"targeting_key": "user-1"}, "flags/my-flag", "flags/my-flag/variants/on")
+	detail := provider.ResolveWithToken(context.Background(), "my-flag", token)
+	if detail.Reason != openfeature.ErrorReason {
+		t.Errorf("Expected an error when the applied variant differs from the token, got %+v", detail)
+	}
+	if _, ok := provider.tokenResults.get(provider.tokenCacheKey("my-flag", token)); ok {
+		t.Error("Expected the mismatched result not to be cached")
+	}
+}
+
+func TestTokenResultCache(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := newTokenResultCache(10*time.Second, 2)
+	cache.now = func() time.Time { return now }
+	detail := openfeature.InterfaceResolutionDetail{Value: "cached"}
+
+	cache.put("a", detail)
+	if got, ok := cache.get("a"); !ok || got.Value != "cached" {
+		t.Fatalf("Expected a cache hit, got %v %v", got, ok)
+	}
+
+	cache.put("b", detail)
+	cache.put("c", detail)
+	if _, ok := cache.get("c"); ok {
+		t.Error("Expected a full cache to not store new results")
+	}
+
+	now = now.Add(10 * time.Second)
+	cache.put("c", detail)
+	if _, ok := cache.get("c"); !ok {
+		t.Error("Expected expired results to be evicted to make room")
+	}
+	if _, ok := cache.get("a"); ok {
+		t.Error("Expected the result to expire after the TTL")
+	}
+
+	cache.clear()
+	if _, ok := cache.get("c"); ok {
+		t.Error("Expected clear to drop all results")
+	}
+}
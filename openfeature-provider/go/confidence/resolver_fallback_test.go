@@ -0,0 +1,222 @@
+package confidence
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
+	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/resolver"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakeClientConn records unary calls and answers them with invoke
+type fakeClientConn struct {
+	grpc.ClientConnInterface
+	method string
+	invoke func(ctx context.Context, args, reply any) error
+}
+
+func (c *fakeClientConn) Invoke(ctx context.Context, method string, args, reply any, _ ...grpc.CallOption) error {
+	c.method = method
+	return c.invoke(ctx, args, reply)
+}
+
+type resolverFallbackFunc func(ctx context.Context, request *resolver.ResolveFlagsRequest) (*resolver.ResolveFlagsResponse, error)
+
+func (f resolverFallbackFunc) ResolveFlags(ctx context.Context, request *resolver.ResolveFlagsRequest) (*resolver.ResolveFlagsResponse, error) {
+	return f(ctx, request)
+}
+
+func TestGrpcResolverFallback(t *testing.T) {
+	var received *resolver.ResolveFlagsRequest
+	var hasDeadline bool
+	conn := &fakeClientConn{invoke: func(ctx context.Context, args, reply any) error {
+		received = args.(*resolver.ResolveFlagsRequest)
+		_, hasDeadline = ctx.Deadline()
+		proto.Merge(reply.(proto.Message), &resolver.ResolveFlagsResponse{
+			ResolvedFlags: []*resolver.ResolvedFlag{{Flag: "flags/my-flag"}},
+		})
+		return nil
+	}}
+	fallback := NewGrpcResolverFallback(conn, "fallback-secret")
+
+	request := &resolver.ResolveFlagsRequest{Flags: []string{"flags/my-flag"}}
+	response, err := fallback.ResolveFlags(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if conn.method != "/confidence.flags.resolver.v1.FlagResolverService/ResolveFlags" {
+		t.Errorf("Unexpected method %q", conn.method)
+	}
+	if len(response.ResolvedFlags) != 1 {
+		t.Errorf("Expected the remote response, got %v", response)
+	}
+	if received.ClientSecret != "fallback-secret" {
+		t.Errorf("Expected the fallback client secret, got %q", received.ClientSecret)
+	}
+	if request.ClientSecret != "" {
+		t.Error("Expected the caller's request to be left untouched")
+	}
+	if !hasDeadline {
+		t.Error("Expected the remote call to have a deadline")
+	}
+
+	backendErr := errors.New("unavailable")
+	conn.invoke = func(context.Context, any, any) error { return backendErr }
+	if _, err := fallback.ResolveFlags(context.Background(), request); !errors.Is(err, backendErr) {
+		t.Errorf("Expected the backend error to be wrapped, got: %v", err)
+	}
+}
+
+func TestLocalResolverProvider_ResolverFallback(t *testing.T) {
+	mockResolver := &mockResolverAPIForInit{
+		resolveWithSticky: func(request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+			return &resolver.ResolveWithStickyResponse{
+				ResolveResult: &resolver.ResolveWithStickyResponse_MissingMaterializations_{
+					MissingMaterializations: &resolver.ResolveWithStickyResponse_MissingMaterializations{},
+				},
+			}, nil
+		},
+	}
+	provider := NewLocalResolverProvider(
+		func(_ context.Context, _ lr.LogSink) lr.LocalResolver { return mockResolver },
+		&tu.StateProviderMock{State: []byte("state"), AccountID: "account"},
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+	defer provider.Shutdown()
+
+	result := provider.ObjectEvaluation(context.Background(), "my-flag", nil, openfeature.FlattenedContext{})
+	if result.Error() == nil || result.ResolutionError.Error() != "GENERAL: missing materializations" {
+		t.Errorf("Expected missing materializations without a fallback, got %v", result.ResolutionError)
+	}
+
+	var fallbackRequest *resolver.ResolveFlagsRequest
+	provider.resolverFallback = resolverFallbackFunc(func(_ context.Context, request *resolver.ResolveFlagsRequest) (*resolver.ResolveFlagsResponse, error) {
+		fallbackRequest = request
+		return &resolver.ResolveFlagsResponse{
+			ResolvedFlags: []*resolver.ResolvedFlag{{
+				Flag:    "flags/my-flag",
+				Variant: "flags/my-flag/variants/remote",
+				Value:   &structpb.Struct{},
+			}},
+		}, nil
+	})
+	result = provider.ObjectEvaluation(context.Background(), "my-flag", nil, openfeature.FlattenedContext{})
+	if result.Error() != nil {
+		t.Fatalf("Expected the fallback to resolve the flag, got %v", result.Error())
+	}
+	if result.Variant != "flags/my-flag/variants/remote" {
+		t.Errorf("Expected the remote variant, got %q", result.Variant)
+	}
+	if fallbackRequest.ClientSecret != "secret" || len(fallbackRequest.Flags) != 1 || !fallbackRequest.Apply {
+		t.Errorf("Expected the local resolve request to be forwarded, got %v", fallbackRequest)
+	}
+
+	provider.resolverFallback = resolverFallbackFunc(func(context.Context, *resolver.ResolveFlagsRequest) (*resolver.ResolveFlagsResponse, error) {
+		return nil, errors.New("remote resolve failed: deadline exceeded")
+	})
+	result = provider.ObjectEvaluation(context.Background(), "my-flag", nil, openfeature.FlattenedContext{})
+	if result.Reason != openfeature.ErrorReason || result.ResolutionError.Error() != "GENERAL: remote resolve failed: deadline exceeded" {
+		t.Errorf("Expected the fallback failure to be surfaced, got %v", result.ResolutionError)
+	}
+}
+
+func TestLocalResolverProvider_StickySkip(t *testing.T) {
+	var first, retried *resolver.ResolveWithStickyRequest
+	mockResolver := &mockResolverAPIForInit{
+		resolveWithSticky: func(request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+			if !request.NotProcessSticky {
+				first = request
+				// Only the sticky flag has a rule reading a materialization
+				var items []*resolver.ResolveWithStickyResponse_MissingMaterializationItem
+				if request.ResolveRequest.Flags[0] == "flags/sticky-flag" {
+					items = append(items, &resolver.ResolveWithStickyResponse_MissingMaterializationItem{
+						Unit: "user", Rule: "flags/sticky-flag/rules/sticky", ReadMaterialization: "experiment",
+					})
+				}
+				return &resolver.ResolveWithStickyResponse{
+					ResolveResult: &resolver.ResolveWithStickyResponse_MissingMaterializations_{
+						MissingMaterializations: &resolver.ResolveWithStickyResponse_MissingMaterializations{Items: items},
+					},
+				}, nil
+			}
+			retried = request
+			return &resolver.ResolveWithStickyResponse{
+				ResolveResult: &resolver.ResolveWithStickyResponse_Success_{
+					Success: &resolver.ResolveWithStickyResponse_Success{
+						Response: &resolver.ResolveFlagsResponse{},
+					},
+				},
+			}, nil
+		},
+	}
+	provider := NewLocalResolverProvider(
+		func(_ context.Context, _ lr.LogSink) lr.LocalResolver { return mockResolver },
+		&tu.StateProviderMock{State: []byte("state"), AccountID: "account"},
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+	provider.stickyMode = StickySkip
+	provider.resolverFallback = resolverFallbackFunc(func(context.Context, *resolver.ResolveFlagsRequest) (*resolver.ResolveFlagsResponse, error) {
+		t.Error("Expected the fallback not to be used when skipping sticky rules")
+		return nil, errors.New("unexpected fallback resolve")
+	})
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+	defer provider.Shutdown()
+
+	result := provider.BooleanEvaluation(context.Background(), "sticky-flag", true, openfeature.FlattenedContext{})
+	if result.Error() != nil {
+		t.Fatalf("Expected no error for a skipped sticky flag, got %v", result.Error())
+	}
+	if result.Reason != openfeature.DefaultReason || !result.Value {
+		t.Errorf("Expected the default value with DefaultReason, got %v (%s)", result.Value, result.Reason)
+	}
+	if first.FailFastOnSticky {
+		t.Error("Expected the missing materializations to be collected when skipping sticky rules")
+	}
+	if retried == nil || len(retried.ResolveRequest.GetFlags()) != 1 {
+		t.Errorf("Expected the flags to be resolved again without sticky rules, got %v", retried)
+	}
+
+	result = provider.BooleanEvaluation(context.Background(), "other-flag", true, openfeature.FlattenedContext{})
+	if result.ResolutionDetail().ErrorCode != openfeature.FlagNotFoundCode {
+		t.Errorf("Expected flags without missing materializations to stay not found, got %v", result.ResolutionError)
+	}
+}
+
+func TestLocalResolverProvider_StickySkip_Wasm(t *testing.T) {
+	stateProvider := &tu.StateProviderMock{State: tu.CreateStateWithStickyFlag(), AccountID: "test-account"}
+	provider := NewLocalResolverProvider(lr.NewLocalResolver, stateProvider, &tu.MockFlagLogger{}, "test-secret", nil)
+	provider.stickyMode = StickySkip
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+	defer provider.Shutdown()
+
+	evalCtx := openfeature.FlattenedContext{"user_id": "test-user-123"}
+	result := provider.BooleanEvaluation(context.Background(), "sticky-test-flag.enabled", true, evalCtx)
+	if result.Error() != nil {
+		t.Fatalf("Expected no error for a skipped sticky flag, got %v", result.Error())
+	}
+	if result.Reason != openfeature.DefaultReason || !result.Value {
+		t.Errorf("Expected the default value with DefaultReason, got %v (%s)", result.Value, result.Reason)
+	}
+
+	result = provider.BooleanEvaluation(context.Background(), "missing-flag.enabled", true, evalCtx)
+	if result.Reason != openfeature.ErrorReason || result.ResolutionDetail().ErrorCode != openfeature.FlagNotFoundCode {
+		t.Errorf("Expected a missing flag to be reported as not found, got %v (%s)", result.ResolutionError, result.Reason)
+	}
+}
@@ -0,0 +1,86 @@
+package confidence
+
+import (
+	"context"
+	"strings"
+
+	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
+	messages "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/resolver"
+)
+
+// NewScriptedResolver returns a LocalResolverSupplier for tests whose resolver answers from
+// scripted responses instead of running the WASM resolver. Responses are keyed by flag name,
+// with or without the "flags/" prefix; a resolve returns the ResolvedFlags of the response
+// scripted for each requested flag, so unscripted flags are reported as not found. Resolving
+// all flags returns every scripted flag. State updates are accepted and ignored and no flag
+// logs are produced, so any StateProvider returning a non-empty state works with it.
+//
+//	supplier := confidence.NewScriptedResolver(map[string]*resolver.ResolveFlagsResponse{
+//		"my-flag": {ResolvedFlags: []*resolver.ResolvedFlag{{
+//			Flag:    "flags/my-flag",
+//			Variant: "flags/my-flag/variants/on",
+//			Value:   value,
+//			Reason:  resolvertypes.ResolveReason_RESOLVE_REASON_MATCH,
+//		}}},
+//	})
+//	provider := confidence.NewLocalResolverProvider(supplier, stateProvider, flagLogger, "secret", nil)
+func NewScriptedResolver(responses map[string]*resolver.ResolveFlagsResponse) LocalResolverSupplier {
+	scripted := make(map[string]*resolver.ResolveFlagsResponse, len(responses))
+	for flag, response := range responses {
+		if !strings.HasPrefix(flag, "flags/") {
+			flag = "flags/" + flag
+		}
+		scripted[flag] = response
+	}
+	return func(context.Context, lr.LogSink) lr.LocalResolver {
+		return &scriptedResolver{responses: scripted}
+	}
+}
+
+// scriptedResolver is the LocalResolver created by NewScriptedResolver. Responses are only
+// read, so it is safe for concurrent use.
+type scriptedResolver struct {
+	responses map[string]*resolver.ResolveFlagsResponse
+}
+
+var _ lr.LocalResolver = (*scriptedResolver)(nil)
+
+func (s *scriptedResolver) SetResolverState(context.Context, *messages.SetResolverStateRequest) error {
+	return nil
+}
+
+func (s *scriptedResolver) ResolveWithSticky(ctx context.Context, request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	flags := request.GetResolveRequest().GetFlags()
+	response := &resolver.ResolveFlagsResponse{}
+	if len(flags) == 0 {
+		for _, scripted := range s.responses {
+			response.ResolvedFlags = append(response.ResolvedFlags, scripted.ResolvedFlags...)
+		}
+	}
+	for _, flag := range flags {
+		if scripted, ok := s.responses[flag]; ok {
+			response.ResolvedFlags = append(response.ResolvedFlags, scripted.ResolvedFlags...)
+		}
+	}
+	return &resolver.ResolveWithStickyResponse{
+		ResolveResult: &resolver.ResolveWithStickyResponse_Success_{
+			Success: &resolver.ResolveWithStickyResponse_Success{Response: response},
+		},
+	}, nil
+}
+
+func (s *scriptedResolver) FlushAllLogs(context.Context) error {
+	return nil
+}
+
+func (s *scriptedResolver) FlushAssignLogs(context.Context) error {
+	return nil
+}
+
+func (s *scriptedResolver) Close(context.Context) error {
+	return nil
+}
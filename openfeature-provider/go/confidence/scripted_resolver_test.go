@@ -0,0 +1,53 @@
+package confidence
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
+	resolvertypes "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolvertypes"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/resolver"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestNewScriptedResolver(t *testing.T) {
+	supplier := NewScriptedResolver(map[string]*resolver.ResolveFlagsResponse{
+		"my-flag": {ResolvedFlags: []*resolver.ResolvedFlag{{
+			Flag:    "flags/my-flag",
+			Variant: "flags/my-flag/variants/on",
+			Value:   &structpb.Struct{Fields: map[string]*structpb.Value{"enabled": structpb.NewBoolValue(true)}},
+			Reason:  resolvertypes.ResolveReason_RESOLVE_REASON_MATCH,
+		}}},
+		"flags/other-flag": {ResolvedFlags: []*resolver.ResolvedFlag{{
+			Flag:   "flags/other-flag",
+			Reason: resolvertypes.ResolveReason_RESOLVE_REASON_NO_SEGMENT_MATCH,
+		}}},
+	})
+	provider := NewLocalResolverProvider(supplier, &tu.StateProviderMock{State: []byte("state"), AccountID: "account"}, &tu.MockFlagLogger{}, "secret", nil)
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+	defer provider.Shutdown()
+
+	enabled := provider.BooleanEvaluation(context.Background(), "my-flag.enabled", false, openfeature.FlattenedContext{})
+	if enabled.Value != true || enabled.Reason != openfeature.TargetingMatchReason {
+		t.Errorf("Expected the scripted value, got %+v", enabled)
+	}
+	other := provider.BooleanEvaluation(context.Background(), "other-flag.enabled", false, openfeature.FlattenedContext{})
+	if other.Value != false || other.Reason != openfeature.DefaultReason {
+		t.Errorf("Expected the default value, got %+v", other)
+	}
+	missing := provider.BooleanEvaluation(context.Background(), "missing-flag.enabled", false, openfeature.FlattenedContext{})
+	if missing.ResolutionError.Error() != "FLAG_NOT_FOUND: flag 'missing-flag' not found" {
+		t.Errorf("Expected FLAG_NOT_FOUND for an unscripted flag, got %q", missing.ResolutionError.Error())
+	}
+
+	all, err := provider.Resolve(context.Background(), nil, openfeature.FlattenedContext{}, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(all.ResolvedFlags) != 2 {
+		t.Errorf("Expected every scripted flag, got %d", len(all.ResolvedFlags))
+	}
+}
@@ -0,0 +1,118 @@
+package confidence
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Session resolves flags for a fixed base evaluation context, e.g. one user in a UI.
+// The base context is converted once; each resolve only converts its overrides.
+// A Session is safe for concurrent use.
+type Session struct {
+	provider *LocalResolverProvider
+	base     *structpb.Struct
+}
+
+// NewSession creates a Session for the given base evaluation context
+func (p *LocalResolverProvider) NewSession(evalCtx openfeature.FlattenedContext) (*Session, error) {
+	base, err := flattenedContextToProto(processTargetingKey(p.encodeLargeInts(evalCtx)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert context: %w", err)
+	}
+	return &Session{
+		provider: p,
+		base:     base,
+	}, nil
+}
+
+// Resolve evaluates a flag against the session context with overrides applied on top.
+// Override keys replace base keys of the same name; nil or empty overrides use the base context as is.
+func (s *Session) Resolve(
+	ctx context.Context,
+	flag string,
+	defaultValue interface{},
+	overrides openfeature.FlattenedContext,
+) openfeature.InterfaceResolutionDetail {
+	merged, err := s.withOverrides(overrides)
+	if err != nil {
+		s.provider.log().Error("Failed to convert evaluation context to proto", "error", err)
+		return openfeature.InterfaceResolutionDetail{
+			Value: defaultValue,
+			ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
+				Reason:          openfeature.ErrorReason,
+				ResolutionError: openfeature.NewGeneralResolutionError(fmt.Sprintf("failed to convert context: %v", err)),
+			},
+		}
+	}
+	return s.provider.resolveWithProtoContext(ctx, flag, defaultValue, merged)
+}
+
+// Snapshot freezes the session context with overrides applied, see LocalResolverProvider.Snapshot
+func (s *Session) Snapshot(overrides openfeature.FlattenedContext) (*Snapshot, error) {
+	merged, err := s.withOverrides(overrides)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert context: %w", err)
+	}
+	return &Snapshot{
+		provider: s.provider,
+		context:  merged,
+		time:     s.provider.now(),
+	}, nil
+}
+
+// withOverrides returns the base context with overrides applied on top
+func (s *Session) withOverrides(overrides openfeature.FlattenedContext) (*structpb.Struct, error) {
+	if len(overrides) == 0 {
+		return s.base, nil
+	}
+
+	overrideCtx, err := flattenedContextToProto(processTargetingKey(s.provider.encodeLargeInts(overrides)))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]*structpb.Value, len(s.base.Fields)+len(overrideCtx.Fields))
+	for k, v := range s.base.Fields {
+		fields[k] = v
+	}
+	for k, v := range overrideCtx.Fields {
+		fields[k] = v
+	}
+	return &structpb.Struct{Fields: fields}, nil
+}
+
+// Snapshot resolves flags against an evaluation context and a time frozen at creation, so
+// that several flags resolved for e.g. one page render all see the same context even if
+// the caller's context map changes in between, and time-based rules are evaluated at the
+// same instant. Flags resolved by a ResolverFallback use the remote resolver's time.
+// A Snapshot is safe for concurrent use.
+type Snapshot struct {
+	provider *LocalResolverProvider
+	context  *structpb.Struct
+	time     time.Time
+}
+
+// Snapshot captures the evaluation context and the current time for consistent resolves
+func (p *LocalResolverProvider) Snapshot(evalCtx openfeature.FlattenedContext) (*Snapshot, error) {
+	session, err := p.NewSession(evalCtx)
+	if err != nil {
+		return nil, err
+	}
+	return session.Snapshot(nil)
+}
+
+// Time returns when the snapshot was taken, as read from the provider's Clock. Resolves
+// made through the snapshot evaluate time-based rules and stamp exposures at this time.
+func (s *Snapshot) Time() time.Time {
+	return s.time
+}
+
+// Resolve evaluates a flag against the snapshot context at the snapshot time
+func (s *Snapshot) Resolve(ctx context.Context, flag string, defaultValue interface{}) openfeature.InterfaceResolutionDetail {
+	return s.provider.resolveWithProtoContext(lr.WithNow(ctx, s.time), flag, defaultValue, s.context)
+}
@@ -0,0 +1,204 @@
+package confidence
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	fl "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/flag_logger"
+	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
+	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/resolver"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// newCapturingProvider returns an initialized provider whose resolver records the evaluation context of each resolve
+func newCapturingProvider(t *testing.T, captured *[]*structpb.Struct) *LocalResolverProvider {
+	mockResolver := &mockResolverAPIForInit{
+		resolveWithSticky: func(request *resolver.ResolveWithStickyRequest) (*resolver.ResolveWithStickyResponse, error) {
+			*captured = append(*captured, request.ResolveRequest.EvaluationContext)
+			return &resolver.ResolveWithStickyResponse{
+				ResolveResult: &resolver.ResolveWithStickyResponse_Success_{
+					Success: &resolver.ResolveWithStickyResponse_Success{
+						Response: &resolver.ResolveFlagsResponse{},
+					},
+				},
+			}, nil
+		},
+	}
+	provider := NewLocalResolverProvider(
+		func(_ context.Context, _ lr.LogSink) lr.LocalResolver { return mockResolver },
+		&tu.StateProviderMock{State: []byte("state"), AccountID: "account"},
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+	t.Cleanup(provider.Shutdown)
+	return provider
+}
+
+func TestSession_Resolve(t *testing.T) {
+	var captured []*structpb.Struct
+	provider := newCapturingProvider(t, &captured)
+
+	session, err := provider.NewSession(openfeature.FlattenedContext{
+		"targetingKey": "user-1",
+		"country":      "SE",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	session.Resolve(context.Background(), "flag-a", nil, nil)
+	session.Resolve(context.Background(), "flag-b", nil, openfeature.FlattenedContext{
+		"country": "US",
+		"page":    "home",
+	})
+	session.Resolve(context.Background(), "flag-c", nil, nil)
+
+	if len(captured) != 3 {
+		t.Fatalf("Expected 3 resolves, got %d", len(captured))
+	}
+
+	base := captured[0].AsMap()
+	if base["targeting_key"] != "user-1" || base["country"] != "SE" {
+		t.Errorf("Expected base context, got %v", base)
+	}
+
+	merged := captured[1].AsMap()
+	if merged["targeting_key"] != "user-1" || merged["country"] != "US" || merged["page"] != "home" {
+		t.Errorf("Expected overrides merged over base context, got %v", merged)
+	}
+
+	after := captured[2].AsMap()
+	if after["country"] != "SE" || after["page"] != nil {
+		t.Errorf("Expected overrides not to leak into the session, got %v", after)
+	}
+}
+
+func TestSession_InvalidContext(t *testing.T) {
+	provider := NewLocalResolverProvider(nil, nil, nil, "secret", nil)
+
+	if _, err := provider.NewSession(openfeature.FlattenedContext{"invalid": make(chan int)}); err == nil {
+		t.Error("Expected error for invalid base context")
+	}
+
+	session, err := provider.NewSession(openfeature.FlattenedContext{})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	result := session.Resolve(context.Background(), "flag", "default", openfeature.FlattenedContext{"invalid": make(chan int)})
+	if result.Value != "default" || result.Reason != openfeature.ErrorReason {
+		t.Errorf("Expected default value with error reason, got %v / %s", result.Value, result.Reason)
+	}
+}
+
+func TestSnapshot_Resolve(t *testing.T) {
+	var captured []*structpb.Struct
+	provider := newCapturingProvider(t, &captured)
+
+	evalCtx := openfeature.FlattenedContext{
+		"targetingKey": "user-1",
+		"country":      "SE",
+	}
+	before := time.Now()
+	snapshot, err := provider.Snapshot(evalCtx)
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	if snapshot.Time().Before(before) || snapshot.Time().After(time.Now()) {
+		t.Errorf("Expected snapshot time to be taken at creation, got %v", snapshot.Time())
+	}
+
+	snapshot.Resolve(context.Background(), "flag-a", nil)
+	// Changes to the caller's context must not affect the snapshot
+	evalCtx["country"] = "US"
+	snapshot.Resolve(context.Background(), "flag-b", nil)
+
+	if len(captured) != 2 {
+		t.Fatalf("Expected 2 resolves, got %d", len(captured))
+	}
+	for i, resolved := range captured {
+		if resolved.AsMap()["country"] != "SE" {
+			t.Errorf("Resolve %d: expected frozen context, got %v", i, resolved.AsMap())
+		}
+	}
+}
+
+func TestSession_Snapshot(t *testing.T) {
+	var captured []*structpb.Struct
+	provider := newCapturingProvider(t, &captured)
+
+	session, err := provider.NewSession(openfeature.FlattenedContext{"targetingKey": "user-1"})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	snapshot, err := session.Snapshot(openfeature.FlattenedContext{"page": "home"})
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	snapshot.Resolve(context.Background(), "flag-a", nil)
+
+	resolved := captured[0].AsMap()
+	if resolved["targeting_key"] != "user-1" || resolved["page"] != "home" {
+		t.Errorf("Expected overrides merged over session context, got %v", resolved)
+	}
+
+	if _, err := session.Snapshot(openfeature.FlattenedContext{"invalid": make(chan int)}); err == nil {
+		t.Error("Expected error for invalid overrides")
+	}
+}
+
+// advancingClock reads the system clock, so the time moves on between snapshot and resolve
+type advancingClock struct{}
+
+func (advancingClock) Now() time.Time { return time.Now() }
+
+func TestSnapshot_ResolvesAtSnapshotTime(t *testing.T) {
+	flagLogger := fl.NewCapturingFlagLogger()
+	provider, err := NewProviderForTest(context.Background(), ProviderTestConfig{
+		StateProvider: &tu.StateProviderMock{
+			State:     tu.LoadTestResolverState(t),
+			AccountID: tu.LoadTestAccountID(t),
+		},
+		FlagLogger:   flagLogger,
+		ClientSecret: "mkjJruAATQWjeY7foFIWfVAcBWnci2YF",
+		Clock:        advancingClock{},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Failed to init provider: %v", err)
+	}
+
+	snapshot, err := provider.Snapshot(openfeature.FlattenedContext{"visitor_id": "tutorial_visitor"})
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if result := snapshot.Resolve(context.Background(), "tutorial-feature", nil); result.Error() != nil {
+		t.Fatalf("Expected no error, got %v", result.Error())
+	}
+	provider.Shutdown()
+
+	// The guest stamps exposures with the time it reads, which must be the snapshot's
+	exposures := 0
+	for _, request := range flagLogger.GetCapturedRequests() {
+		for _, assigned := range request.FlagAssigned {
+			for _, flag := range assigned.Flags {
+				exposures++
+				if got := flag.ApplyTime.AsTime(); !got.Equal(snapshot.Time()) {
+					t.Errorf("Expected apply time at the snapshot time %v, got %v", snapshot.Time(), got)
+				}
+			}
+		}
+	}
+	if exposures == 0 {
+		t.Error("Expected the exposure to be flushed")
+	}
+}
@@ -0,0 +1,100 @@
+package confidence
+
+import (
+	"context"
+	"fmt"
+
+	adminv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/admin/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// StateDelta is a change to the resolver state relative to the state currently loaded.
+//
+// The resolver guest has no incremental update entry point, so deltas are applied on
+// the host and the resulting state is loaded in full. A delta format therefore saves
+// transferring the full state, not the cost of loading it into the guest: loading dominates,
+// so BenchmarkLocalResolverProvider_ApplyStateDelta and _FullStateReload take about as long
+// per update.
+type StateDelta interface {
+	// Apply modifies state in place.
+	Apply(state *adminv1.ResolverState) error
+}
+
+// FlagsDelta upserts and deletes flags by name
+type FlagsDelta struct {
+	// Upsert replaces flags with the same name, or adds them if they are new. Each name
+	// may be upserted once.
+	Upsert []*adminv1.Flag
+	// Delete removes flags by name, e.g. "flags/my-flag".
+	Delete []string
+}
+
+var _ StateDelta = (*FlagsDelta)(nil)
+
+// Apply implements StateDelta
+func (d *FlagsDelta) Apply(state *adminv1.ResolverState) error {
+	deleted := make(map[string]bool, len(d.Delete))
+	for _, name := range d.Delete {
+		deleted[name] = true
+	}
+	upserts := make(map[string]*adminv1.Flag, len(d.Upsert))
+	for _, flag := range d.Upsert {
+		if deleted[flag.Name] {
+			return fmt.Errorf("flag %s is both upserted and deleted", flag.Name)
+		}
+		if _, ok := upserts[flag.Name]; ok {
+			return fmt.Errorf("flag %s is upserted more than once", flag.Name)
+		}
+		upserts[flag.Name] = flag
+	}
+
+	flags := state.Flags[:0]
+	for _, flag := range state.Flags {
+		if deleted[flag.Name] {
+			continue
+		}
+		if upsert, ok := upserts[flag.Name]; ok {
+			flag = upsert
+			delete(upserts, flag.Name)
+		}
+		flags = append(flags, flag)
+	}
+	// Append new flags in the order they were given
+	for _, flag := range d.Upsert {
+		if _, ok := upserts[flag.Name]; ok {
+			flags = append(flags, flag)
+		}
+	}
+	state.Flags = flags
+	return nil
+}
+
+// ApplyStateDelta applies delta to the state currently loaded into the resolver and
+// loads the result. The next regular state poll replaces it with the fetched state,
+// so deltas should mirror changes that are also published upstream.
+func (p *LocalResolverProvider) ApplyStateDelta(ctx context.Context, delta StateDelta) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.swapMu.Lock()
+	defer p.swapMu.Unlock()
+
+	loaded := p.getLastState()
+	if loaded == nil {
+		return fmt.Errorf("provider not initialized")
+	}
+
+	state := &adminv1.ResolverState{}
+	if err := proto.Unmarshal(loaded.request.State, state); err != nil {
+		return fmt.Errorf("failed to unmarshal current state: %w", err)
+	}
+	if err := delta.Apply(state); err != nil {
+		return fmt.Errorf("failed to apply state delta: %w", err)
+	}
+	updated, err := proto.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal updated state: %w", err)
+	}
+	return p.updateStateLocked(ctx, updated, loaded.request.AccountId, "")
+}
@@ -0,0 +1,193 @@
+package confidence
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
+	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
+	adminv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/admin/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func flagNames(state *adminv1.ResolverState) []string {
+	names := make([]string, len(state.Flags))
+	for i, flag := range state.Flags {
+		names[i] = flag.Name
+	}
+	return names
+}
+
+func TestFlagsDelta_Apply(t *testing.T) {
+	state := &adminv1.ResolverState{
+		Flags: []*adminv1.Flag{
+			{Name: "flags/a"},
+			{Name: "flags/b", State: adminv1.Flag_ACTIVE},
+			{Name: "flags/c"},
+		},
+	}
+	delta := &FlagsDelta{
+		Upsert: []*adminv1.Flag{
+			{Name: "flags/b", State: adminv1.Flag_ARCHIVED},
+			{Name: "flags/d"},
+		},
+		Delete: []string{"flags/a"},
+	}
+	if err := delta.Apply(state); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	names := flagNames(state)
+	expected := []string{"flags/b", "flags/c", "flags/d"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected flags %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("Expected flags %v, got %v", expected, names)
+		}
+	}
+	if state.Flags[0].State != adminv1.Flag_ARCHIVED {
+		t.Errorf("Expected flags/b to be replaced, got state %v", state.Flags[0].State)
+	}
+}
+
+func TestFlagsDelta_Apply_Conflict(t *testing.T) {
+	delta := &FlagsDelta{
+		Upsert: []*adminv1.Flag{{Name: "flags/a"}},
+		Delete: []string{"flags/a"},
+	}
+	if err := delta.Apply(&adminv1.ResolverState{}); err == nil {
+		t.Error("Expected error when a flag is both upserted and deleted")
+	}
+
+	state := &adminv1.ResolverState{Flags: []*adminv1.Flag{{Name: "flags/a"}}}
+	duplicate := &FlagsDelta{
+		Upsert: []*adminv1.Flag{{Name: "flags/b"}, {Name: "flags/b"}},
+	}
+	if err := duplicate.Apply(state); err == nil {
+		t.Error("Expected error when a flag is upserted twice")
+	}
+	if names := flagNames(state); len(names) != 1 {
+		t.Errorf("Expected the state to be left unchanged, got %v", names)
+	}
+}
+
+func TestLocalResolverProvider_ApplyStateDelta(t *testing.T) {
+	var loaded []byte
+	mockResolver := &mockResolverAPIForInit{
+		updateStateFunc: func(state []byte, _ string) error {
+			loaded = state
+			return nil
+		},
+	}
+	provider := NewLocalResolverProvider(
+		func(_ context.Context, _ lr.LogSink) lr.LocalResolver { return mockResolver },
+		&tu.StateProviderMock{State: tu.CreateMinimalResolverState(), AccountID: "account"},
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+	delta := &FlagsDelta{Upsert: []*adminv1.Flag{{Name: "flags/new-flag"}}}
+
+	if err := provider.ApplyStateDelta(context.Background(), delta); err == nil {
+		t.Error("Expected error applying a delta before Init")
+	}
+
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer provider.Shutdown()
+	initialHash := provider.StateHash()
+
+	if err := provider.ApplyStateDelta(context.Background(), delta); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	state := &adminv1.ResolverState{}
+	if err := proto.Unmarshal(loaded, state); err != nil {
+		t.Fatalf("Failed to unmarshal loaded state: %v", err)
+	}
+	if names := flagNames(state); len(names) != 1 || names[0] != "flags/new-flag" {
+		t.Errorf("Expected loaded state to contain the upserted flag, got %v", names)
+	}
+	if len(state.Clients) != 1 {
+		t.Errorf("Expected the rest of the state to be kept, got %d clients", len(state.Clients))
+	}
+	if provider.StateHash() == initialHash {
+		t.Error("Expected state hash to change after applying the delta")
+	}
+	if provider.AccountID() != "account" {
+		t.Errorf("Expected account ID to be kept, got: %s", provider.AccountID())
+	}
+}
+
+// flagsDeltaBenchState decodes the test state and returns it with a delta replacing one of its flags
+func flagsDeltaBenchState(b *testing.B) (*adminv1.ResolverState, *FlagsDelta) {
+	state := &adminv1.ResolverState{}
+	if err := proto.Unmarshal(tu.LoadTestResolverState(b), state); err != nil {
+		b.Fatalf("Failed to unmarshal test state: %v", err)
+	}
+	if len(state.Flags) == 0 {
+		b.Skip("Test state has no flags")
+	}
+	return state, &FlagsDelta{Upsert: []*adminv1.Flag{proto.Clone(state.Flags[0]).(*adminv1.Flag)}}
+}
+
+// newBenchProvider returns a provider initialized with the test state on a single guest instance
+func newBenchProvider(b *testing.B) *LocalResolverProvider {
+	provider := NewLocalResolverProvider(
+		lr.NewLocalResolverWithOptions(lr.Options{Instances: 1}),
+		&tu.StateProviderMock{State: tu.LoadTestResolverState(b), AccountID: tu.LoadTestAccountID(b)},
+		&tu.MockFlagLogger{},
+		"secret",
+		nil,
+	)
+	if err := provider.Init(openfeature.EvaluationContext{}); err != nil {
+		b.Fatalf("Failed to initialize provider: %v", err)
+	}
+	b.Cleanup(provider.Shutdown)
+	return provider
+}
+
+// BenchmarkFlagsDelta_Apply measures applying a one-flag delta to the decoded state on the host
+func BenchmarkFlagsDelta_Apply(b *testing.B) {
+	state, delta := flagsDeltaBenchState(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := delta.Apply(state); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLocalResolverProvider_ApplyStateDelta measures a one-flag delta end to end, including
+// loading the resulting state into the guest. Compare with BenchmarkLocalResolverProvider_FullStateReload.
+func BenchmarkLocalResolverProvider_ApplyStateDelta(b *testing.B) {
+	_, delta := flagsDeltaBenchState(b)
+	provider := newBenchProvider(b)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := provider.ApplyStateDelta(ctx, delta); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLocalResolverProvider_FullStateReload measures loading an already fetched full state,
+// the work a regular state poll does once the state has been downloaded
+func BenchmarkLocalResolverProvider_FullStateReload(b *testing.B) {
+	provider := newBenchProvider(b)
+	state, accountId := tu.LoadTestResolverState(b), tu.LoadTestAccountID(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		provider.swapMu.Lock()
+		err := provider.updateStateLocked(context.Background(), state, accountId, "")
+		provider.swapMu.Unlock()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package confidence
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
+	pb "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto"
+	resolverv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/resolverinternal"
+	"github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/resolver"
+	"google.golang.org/protobuf/proto"
+)
+
+// Diff is a flag that resolves differently under two states for one evaluation context
+type Diff struct {
+	// Flag is the flag key, e.g. "my-flag"
+	Flag string
+	// Context is the evaluation context the flag was resolved for
+	Context openfeature.FlattenedContext
+	// Old and New are the flag resolved with each state, nil when the state didn't resolve it,
+	// e.g. because the flag doesn't exist in it
+	Old, New *resolver.ResolvedFlag
+}
+
+// StateDiff is what DiffStates found between two states
+type StateDiff struct {
+	// Diffs are the flags whose variant, value or reason differ
+	Diffs []Diff
+	// Skipped are the flags that couldn't be compared for a context because a sticky rule
+	// needs materializations, which aren't available when diffing. Old or New is nil for
+	// the state that skipped the flag.
+	Skipped []Diff
+}
+
+// DiffStates resolves flags with both oldState and newState for each of the evaluation
+// contexts and reports the flags whose variant, value or reason differ, e.g. to check what a
+// state about to be deployed would change. States may be raw ResolverStates, which resolve
+// for accountId, or SetResolverStateRequest envelopes. Empty flags compares every flag of
+// the client clientSecret belongs to. Nothing is applied or logged. No materializations are
+// available, so flags whose sticky rules need them are reported as skipped instead.
+func DiffStates(
+	ctx context.Context,
+	oldState, newState []byte,
+	accountId, clientSecret string,
+	contexts []openfeature.FlattenedContext,
+	flags []string,
+) (*StateDiff, error) {
+	flagNames := make([]string, len(flags))
+	for i, flag := range flags {
+		flagNames[i] = "flags/" + flag
+	}
+
+	oldResolved, err := resolveForDiff(ctx, oldState, accountId, clientSecret, contexts, flagNames)
+	if err != nil {
+		return nil, fmt.Errorf("old state: %w", err)
+	}
+	newResolved, err := resolveForDiff(ctx, newState, accountId, clientSecret, contexts, flagNames)
+	if err != nil {
+		return nil, fmt.Errorf("new state: %w", err)
+	}
+
+	result := &StateDiff{}
+	for i, evalCtx := range contexts {
+		before, after := oldResolved[i], newResolved[i]
+		// Flags resolved or skipped by either state, sorted for a stable diff order
+		seen := make(map[string]bool)
+		var names []string
+		add := func(name string) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		for _, resolution := range []diffResolution{before, after} {
+			for name := range resolution.flags {
+				add(name)
+			}
+			for name := range resolution.skipped {
+				add(name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			diff := Diff{
+				Flag:    strings.TrimPrefix(name, "flags/"),
+				Context: evalCtx,
+				Old:     before.flags[name],
+				New:     after.flags[name],
+			}
+			switch {
+			case before.skipped[name] || after.skipped[name]:
+				result.Skipped = append(result.Skipped, diff)
+			case !resolvedEqual(diff.Old, diff.New):
+				result.Diffs = append(result.Diffs, diff)
+			}
+		}
+	}
+	return result, nil
+}
+
+// diffResolution is what one state resolved for one evaluation context
+type diffResolution struct {
+	// flags are the resolved flags by name
+	flags map[string]*resolver.ResolvedFlag
+	// skipped are the flags left unresolved because a sticky rule needs materializations
+	skipped map[string]bool
+}
+
+// resolveForDiff loads state into a resolver of its own and resolves flagNames for each
+// context
+func resolveForDiff(
+	ctx context.Context,
+	state []byte,
+	accountId, clientSecret string,
+	contexts []openfeature.FlattenedContext,
+	flagNames []string,
+) ([]diffResolution, error) {
+	stateBytes, stateAccount, err := ParseStatePayload(state, accountId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+	if stateAccount == "" {
+		return nil, fmt.Errorf("AccountID is empty, pass one for raw states")
+	}
+
+	discard := func(*resolverv1.WriteFlagLogsRequest) {}
+	localResolver := lr.NewLocalResolverWithOptions(lr.Options{Instances: 1})(ctx, discard)
+	defer localResolver.Close(ctx)
+	if err := localResolver.SetResolverState(ctx, &pb.SetResolverStateRequest{
+		State:     stateBytes,
+		AccountId: stateAccount,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	results := make([]diffResolution, len(contexts))
+	for i, evalCtx := range contexts {
+		protoCtx, err := flattenedContextToProto(processTargetingKey(evalCtx))
+		if err != nil {
+			return nil, fmt.Errorf("invalid evaluation context %d: %w", i, err)
+		}
+		request := &resolver.ResolveFlagsRequest{
+			Flags:             flagNames,
+			ClientSecret:      clientSecret,
+			EvaluationContext: protoCtx,
+			Sdk:               defaultSdk,
+		}
+		// Resolve with sticky rules first to learn which flags need materializations
+		response, err := localResolver.ResolveWithSticky(ctx, &resolver.ResolveWithStickyRequest{
+			ResolveRequest:          request,
+			MaterializationsPerUnit: make(map[string]*resolver.MaterializationMap),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve evaluation context %d: %w", i, err)
+		}
+		missing := response.GetMissingMaterializations().GetItems()
+		if response.GetMissingMaterializations() != nil {
+			response, err = localResolver.ResolveWithSticky(ctx, &resolver.ResolveWithStickyRequest{
+				ResolveRequest:          request,
+				MaterializationsPerUnit: make(map[string]*resolver.MaterializationMap),
+				NotProcessSticky:        true,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve evaluation context %d: %w", i, err)
+			}
+		}
+		resolved := response.GetSuccess().GetResponse()
+		results[i] = diffResolution{
+			flags:   make(map[string]*resolver.ResolvedFlag),
+			skipped: make(map[string]bool),
+		}
+		for _, flag := range resolved.GetResolvedFlags() {
+			results[i].flags[flag.Flag] = flag
+		}
+		for _, flag := range skippedStickyFlags(missing, resolved) {
+			results[i].skipped[flag] = true
+		}
+	}
+	return results, nil
+}
+
+// resolvedEqual reports whether two resolutions of a flag agree on variant, value and reason
+func resolvedEqual(a, b *resolver.ResolvedFlag) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Variant == b.Variant && a.Reason == b.Reason && proto.Equal(a.Value, b.Value)
+}
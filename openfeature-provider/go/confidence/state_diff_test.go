@@ -0,0 +1,77 @@
+package confidence
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
+	adminv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/admin/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDiffStates(t *testing.T) {
+	ctx := context.Background()
+	oldState := tu.LoadTestResolverState(t)
+	accountID := tu.LoadTestAccountID(t)
+	const clientSecret = "mkjJruAATQWjeY7foFIWfVAcBWnci2YF"
+	contexts := []openfeature.FlattenedContext{{"visitor_id": "tutorial_visitor"}}
+
+	result, err := DiffStates(ctx, oldState, oldState, accountID, clientSecret, contexts, []string{"tutorial-feature"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if diffs := result.Diffs; len(diffs) != 0 || len(result.Skipped) != 0 {
+		t.Errorf("Expected identical states not to differ, got %v", diffs)
+	}
+
+	state := &adminv1.ResolverState{}
+	if err := proto.Unmarshal(oldState, state); err != nil {
+		t.Fatalf("Failed to unmarshal test state: %v", err)
+	}
+	if err := (&FlagsDelta{Delete: []string{"flags/tutorial-feature"}}).Apply(state); err != nil {
+		t.Fatalf("Failed to apply delta: %v", err)
+	}
+	newState, err := proto.Marshal(state)
+	if err != nil {
+		t.Fatalf("Failed to marshal new state: %v", err)
+	}
+
+	result, err = DiffStates(ctx, oldState, newState, accountID, clientSecret, contexts, []string{"tutorial-feature"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	diffs := result.Diffs
+	if len(diffs) != 1 {
+		t.Fatalf("Expected the deleted flag to differ, got %v", diffs)
+	}
+	if diffs[0].Flag != "tutorial-feature" || diffs[0].Old == nil || diffs[0].New != nil {
+		t.Errorf("Expected the flag to resolve with the old state only, got %+v", diffs[0])
+	}
+	if diffs[0].Context["visitor_id"] != "tutorial_visitor" {
+		t.Errorf("Expected the diff to carry its evaluation context, got %v", diffs[0].Context)
+	}
+
+	if _, err := DiffStates(ctx, oldState, newState, "", clientSecret, contexts, nil); err == nil {
+		t.Error("Expected an error for raw states without an account ID")
+	}
+}
+
+func TestDiffStates_SkippedStickyFlags(t *testing.T) {
+	ctx := context.Background()
+	contexts := []openfeature.FlattenedContext{{"user_id": "test-user-123"}}
+
+	result, err := DiffStates(ctx, tu.CreateStateWithStickyFlag(), tu.CreateMinimalResolverState(), "test-account", "test-secret", contexts, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(result.Diffs) != 0 {
+		t.Errorf("Expected flags needing materializations not to be compared, got %v", result.Diffs)
+	}
+	if len(result.Skipped) != 1 {
+		t.Fatalf("Expected the sticky flag to be reported as skipped, got %v", result.Skipped)
+	}
+	if skipped := result.Skipped[0]; skipped.Flag != "sticky-test-flag" || skipped.Old != nil || skipped.New != nil {
+		t.Errorf("Expected the sticky flag to be skipped by the old state, got %+v", skipped)
+	}
+}
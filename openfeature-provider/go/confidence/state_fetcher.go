@@ -1,13 +1,18 @@
 package confidence
 
 import (
+	"compress/gzip"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -16,19 +21,68 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-// StateProvider is an interface for providing resolver state and account ID
+// StateProvider is an interface for providing resolver state and account ID.
+// Implementations holding resources like connections or file watchers can also implement
+// io.Closer; the provider closes them on Shutdown, after stopping the poll loop.
 type StateProvider interface {
 	Provide(ctx context.Context) ([]byte, string, error)
 }
 
+// DefaultStateBaseURL is the CDN serving resolver state
+const DefaultStateBaseURL = "https://confidence-resolver-state-cdn.spotifycdn.com/"
+
+// State payload format negotiation. The fetcher asks for the format it understands
+// through Accept and rejects responses declaring any other version. Responses
+// without a version header are treated as the current version.
+const (
+	stateFormatVersion = "1"
+	stateAccept        = "application/x-protobuf; version=" + stateFormatVersion
+	stateVersionHeader = "X-Confidence-State-Version"
+)
+
+// ErrUnsupportedStateVersion is returned when the CDN serves a state format this provider cannot decode
+var ErrUnsupportedStateVersion = errors.New("unsupported resolver state format version")
+
+// RetryPolicy controls how Reload retries a fetch that failed on every host with a
+// connection error or a 5xx status. 304 Not Modified and 4xx responses are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first. Values below 1 mean 1.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubled for each following retry
+	BaseDelay time.Duration
+	// Jitter randomly spreads each delay by up to this fraction of it, within [0, 1]
+	Jitter float64
+}
+
+// DefaultRetryPolicy makes three attempts with exponential backoff starting at 200ms
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	Jitter:      0.2,
+}
+
+// delay returns the backoff before the given retry, counting from 1
+func (r RetryPolicy) delay(retry int) time.Duration {
+	d := r.BaseDelay << (retry - 1)
+	if r.Jitter > 0 {
+		spread := (rand.Float64()*2 - 1) * min(r.Jitter, 1)
+		d = time.Duration(float64(d) * (1 + spread))
+	}
+	return d
+}
+
 // FlagsAdminStateFetcher fetches and updates the resolver state from the CDN
 type FlagsAdminStateFetcher struct {
 	clientSecret     string
-	etag             atomic.Value // stores string
+	baseURLs         []string
+	etags            sync.Map     // base URL -> ETag string
+	etag             atomic.Value // stores the ETag string of the last loaded state
 	rawResolverState atomic.Value // stores []byte
 	accountID        atomic.Value // stores string
 	HTTPClient       *http.Client // Exported for testing
-	logger           *slog.Logger
+	// RetryPolicy controls retries of failed fetches, DefaultRetryPolicy unless changed
+	RetryPolicy RetryPolicy
+	logger      *slog.Logger
 }
 
 // Compile-time interface conformance check
@@ -48,8 +102,25 @@ func NewFlagsAdminStateFetcherWithTransport(
 	logger *slog.Logger,
 	transport http.RoundTripper,
 ) *FlagsAdminStateFetcher {
+	return NewFlagsAdminStateFetcherWithBaseURLs(clientSecret, logger, transport, nil)
+}
+
+// NewFlagsAdminStateFetcherWithBaseURLs creates a new FlagsAdminStateFetcher fetching from the
+// given base URLs, primary first. Later URLs are only tried when the earlier ones fail with a
+// connection error or a 5xx status. An empty list uses DefaultStateBaseURL.
+func NewFlagsAdminStateFetcherWithBaseURLs(
+	clientSecret string,
+	logger *slog.Logger,
+	transport http.RoundTripper,
+	baseURLs []string,
+) *FlagsAdminStateFetcher {
+	if len(baseURLs) == 0 {
+		baseURLs = []string{DefaultStateBaseURL}
+	}
 	f := &FlagsAdminStateFetcher{
 		clientSecret: clientSecret,
+		baseURLs:     baseURLs,
+		RetryPolicy:  DefaultRetryPolicy,
 		logger:       logger,
 		HTTPClient: &http.Client{
 			Timeout:   30 * time.Second,
@@ -81,9 +152,35 @@ func (f *FlagsAdminStateFetcher) GetAccountID() string {
 	return ""
 }
 
-// Reload fetches and updates the state if it has changed
+// ETag returns the ETag of the last state loaded, whichever host served it, or an empty
+// string before the first successful fetch
+func (f *FlagsAdminStateFetcher) ETag() string {
+	if etag := f.etag.Load(); etag != nil {
+		return etag.(string)
+	}
+	return ""
+}
+
+// Reload fetches and updates the state if it has changed, retrying according to RetryPolicy
+// when every host fails with a connection error or a 5xx status
 func (f *FlagsAdminStateFetcher) Reload(ctx context.Context) error {
-	return f.fetchAndUpdateStateIfChanged(ctx)
+	attempts := max(f.RetryPolicy.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		err := f.fetchAndUpdateStateIfChanged(ctx)
+		var retryable *retryableFetchError
+		if !errors.As(err, &retryable) || attempt >= attempts || ctx.Err() != nil {
+			return err
+		}
+		delay := f.RetryPolicy.delay(attempt)
+		f.logger.Warn("State fetch failed, retrying", "attempt", attempt, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
 }
 
 // Provide implements the StateProvider interface
@@ -97,26 +194,64 @@ func (f *FlagsAdminStateFetcher) Provide(ctx context.Context) ([]byte, string, e
 	return f.GetRawState(), f.GetAccountID(), err
 }
 
-// fetchAndUpdateStateIfChanged fetches the state from the CDN if it has changed
+// Close closes the idle connections of the HTTP transport used to fetch state
+func (f *FlagsAdminStateFetcher) Close() error {
+	f.HTTPClient.CloseIdleConnections()
+	return nil
+}
+
+// fetchAndUpdateStateIfChanged fetches the state from the CDN if it has changed,
+// failing over to the next base URL on connection errors and 5xx responses. When every
+// host fails that way the joined error is wrapped in a retryableFetchError.
 func (f *FlagsAdminStateFetcher) fetchAndUpdateStateIfChanged(ctx context.Context) error {
+	var errs []error
+	for _, baseURL := range f.baseURLs {
+		err := f.fetchFrom(ctx, baseURL)
+		var retryable *retryableFetchError
+		if !errors.As(err, &retryable) {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", baseURL, retryable.err))
+		if ctx.Err() != nil {
+			break
+		}
+		f.logger.Warn("State fetch failed, trying next host", "host", baseURL, "error", retryable.err)
+	}
+	return &retryableFetchError{errors.Join(errs...)}
+}
+
+// retryableFetchError marks a failure that another host may not have
+type retryableFetchError struct {
+	err error
+}
+
+func (e *retryableFetchError) Error() string { return e.err.Error() }
+func (e *retryableFetchError) Unwrap() error { return e.err }
+
+// fetchFrom fetches the state from a single base URL, using that host's ETag
+func (f *FlagsAdminStateFetcher) fetchFrom(ctx context.Context, baseURL string) error {
 	// Build CDN URL using SHA256 hash of client secret
 	hash := sha256.Sum256([]byte(f.clientSecret))
 	hashHex := hex.EncodeToString(hash[:])
-	cdnURL := "https://confidence-resolver-state-cdn.spotifycdn.com/" + hashHex
+	cdnURL := strings.TrimSuffix(baseURL, "/") + "/" + hashHex
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cdnURL, nil)
 	if err != nil {
 		return err
 	}
 
-	// Add If-None-Match header if we have a previous ETag
-	if previousEtag := f.etag.Load(); previousEtag != nil {
+	req.Header.Set("Accept", stateAccept)
+	// Set explicitly, the transport only decompresses transparently when it adds the header itself
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	// Add If-None-Match header if we have a previous ETag from this host
+	if previousEtag, ok := f.etags.Load(baseURL); ok {
 		req.Header.Set("If-None-Match", previousEtag.(string))
 	}
 
 	resp, err := f.HTTPClient.Do(req)
 	if err != nil {
-		return err
+		return &retryableFetchError{err}
 	}
 	defer resp.Body.Close()
 
@@ -126,14 +261,30 @@ func (f *FlagsAdminStateFetcher) fetchAndUpdateStateIfChanged(ctx context.Contex
 		return nil
 	}
 
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return &retryableFetchError{fmt.Errorf("unexpected status code: %d", resp.StatusCode)}
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	// Read the new state
-	bytes, err := io.ReadAll(resp.Body)
+	if version := resp.Header.Get(stateVersionHeader); version != "" && version != stateFormatVersion {
+		return fmt.Errorf("%w: got %q, expected %q", ErrUnsupportedStateVersion, version, stateFormatVersion)
+	}
+
+	// Read the new state, decompressing it if the CDN gzipped it
+	var body io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return &retryableFetchError{fmt.Errorf("failed to decompress state: %w", err)}
+		}
+		defer gz.Close()
+		body = gz
+	}
+	bytes, err := io.ReadAll(body)
 	if err != nil {
-		return err
+		return &retryableFetchError{fmt.Errorf("failed to read state: %w", err)}
 	}
 
 	// Parse SetResolverStateRequest
@@ -145,14 +296,15 @@ func (f *FlagsAdminStateFetcher) fetchAndUpdateStateIfChanged(ctx context.Contex
 	// Extract account ID and state bytes
 	f.accountID.Store(stateRequest.AccountId)
 
-	// Get and store the new ETag
+	// Get and store the new ETag for this host
 	etag := resp.Header.Get("ETag")
+	f.etags.Store(baseURL, etag)
 	f.etag.Store(etag)
 
 	// Update the raw state (state is already in bytes format)
 	f.rawResolverState.Store(stateRequest.State)
 
-	f.logger.Debug("Loaded resolver state", "etag", etag, "account", stateRequest.AccountId)
+	f.logger.Debug("Loaded resolver state", "host", baseURL, "etag", etag, "account", stateRequest.AccountId)
 
 	return nil
 }
@@ -1,12 +1,17 @@
 package confidence
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -123,9 +128,12 @@ func TestFlagsAdminStateFetcher_Reload_Success(t *testing.T) {
 	}
 
 	// Verify ETag was stored
-	if etag := fetcher.etag.Load(); etag == nil || etag.(string) != "test-etag" {
+	if etag, ok := fetcher.etags.Load(DefaultStateBaseURL); !ok || etag.(string) != "test-etag" {
 		t.Error("Expected ETag to be stored")
 	}
+	if fetcher.ETag() != "test-etag" {
+		t.Errorf("Expected ETag() to return 'test-etag', got %q", fetcher.ETag())
+	}
 }
 
 // TestFlagsAdminStateFetcher_Reload_NotModified tests ETag-based caching
@@ -335,3 +343,309 @@ func TestFlagsAdminStateFetcher_HTTPTimeout(t *testing.T) {
 		t.Error("Expected timeout error")
 	}
 }
+
+// TestFlagsAdminStateFetcher_Reload_Failover tests that a fallback host serves state when the primary fails
+func TestFlagsAdminStateFetcher_Reload_Failover(t *testing.T) {
+	testStateBytes, _ := proto.Marshal(&adminv1.ResolverState{Flags: []*adminv1.Flag{{Name: "flags/test-flag"}}})
+	stateBytes, _ := proto.Marshal(&pb.SetResolverStateRequest{
+		State:     testStateBytes,
+		AccountId: "fallback-account",
+	})
+
+	primaryStatus := http.StatusServiceUnavailable
+	primaryRequests := 0
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryRequests++
+		w.WriteHeader(primaryStatus)
+	}))
+	defer primary.Close()
+
+	var fallbackIfNoneMatch string
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fallbackIfNoneMatch = r.Header.Get("If-None-Match")
+		w.Header().Set("ETag", "fallback-etag")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(stateBytes)
+	}))
+	defer fallback.Close()
+
+	// An unreachable host fails with a connection error and is skipped as well
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	fetcher := NewFlagsAdminStateFetcherWithBaseURLs(
+		"test-client-secret",
+		slog.New(slog.NewTextHandler(os.Stderr, nil)),
+		http.DefaultTransport,
+		[]string{primary.URL, unreachable.URL, fallback.URL},
+	)
+	ctx := context.Background()
+
+	if err := fetcher.Reload(ctx); err != nil {
+		t.Fatalf("Expected fallback to serve state, got %v", err)
+	}
+	if fetcher.GetAccountID() != "fallback-account" {
+		t.Errorf("Expected account ID from fallback, got %s", fetcher.GetAccountID())
+	}
+	if etag, ok := fetcher.etags.Load(fallback.URL); !ok || etag.(string) != "fallback-etag" {
+		t.Error("Expected ETag to be stored for the fallback host")
+	}
+	if _, ok := fetcher.etags.Load(primary.URL); ok {
+		t.Error("Expected no ETag for the failing primary host")
+	}
+
+	// The fallback ETag is only sent to the fallback host
+	if err := fetcher.Reload(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if fallbackIfNoneMatch != "fallback-etag" {
+		t.Errorf("Expected fallback ETag to be sent to the fallback host, got %q", fallbackIfNoneMatch)
+	}
+
+	// A 4xx from the primary is not retried on other hosts
+	primaryStatus = http.StatusNotFound
+	fallbackIfNoneMatch = "untouched"
+	if err := fetcher.Reload(ctx); err == nil {
+		t.Error("Expected error for 404 from primary")
+	}
+	if fallbackIfNoneMatch != "untouched" {
+		t.Error("Expected no failover on 4xx")
+	}
+}
+
+// TestFlagsAdminStateFetcher_Reload_AllHostsFail tests the error when every host fails
+func TestFlagsAdminStateFetcher_Reload_AllHostsFail(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	fetcher := NewFlagsAdminStateFetcherWithBaseURLs(
+		"test-client-secret",
+		slog.New(slog.NewTextHandler(os.Stderr, nil)),
+		http.DefaultTransport,
+		[]string{failing.URL, failing.URL + "/"},
+	)
+	err := fetcher.Reload(context.Background())
+	if err == nil {
+		t.Fatal("Expected error when all hosts fail")
+	}
+	if !strings.Contains(err.Error(), "unexpected status code: 502") {
+		t.Errorf("Expected status code in error, got %v", err)
+	}
+}
+
+// TestFlagsAdminStateFetcher_Reload_VersionNegotiation tests the Accept header and version check
+func TestFlagsAdminStateFetcher_Reload_VersionNegotiation(t *testing.T) {
+	testStateBytes, _ := proto.Marshal(&adminv1.ResolverState{})
+	stateBytes, _ := proto.Marshal(&pb.SetResolverStateRequest{
+		State:     testStateBytes,
+		AccountId: "test-account",
+	})
+
+	var accept string
+	version := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		if version != "" {
+			w.Header().Set(stateVersionHeader, version)
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(stateBytes)
+	}))
+	defer server.Close()
+
+	fetcher := NewFlagsAdminStateFetcherWithBaseURLs(
+		"test-client-secret",
+		slog.New(slog.NewTextHandler(os.Stderr, nil)),
+		http.DefaultTransport,
+		[]string{server.URL},
+	)
+	ctx := context.Background()
+
+	// No version header is treated as the current version
+	if err := fetcher.Reload(ctx); err != nil {
+		t.Fatalf("Expected no error without version header, got %v", err)
+	}
+	if accept != stateAccept {
+		t.Errorf("Expected Accept header %q, got %q", stateAccept, accept)
+	}
+
+	version = stateFormatVersion
+	if err := fetcher.Reload(ctx); err != nil {
+		t.Fatalf("Expected no error for the current version, got %v", err)
+	}
+
+	version = "2"
+	err := fetcher.Reload(ctx)
+	if !errors.Is(err, ErrUnsupportedStateVersion) {
+		t.Fatalf("Expected ErrUnsupportedStateVersion, got %v", err)
+	}
+	if fetcher.GetAccountID() != "test-account" {
+		t.Errorf("Expected previous state to be kept, got account %s", fetcher.GetAccountID())
+	}
+}
+
+// TestFlagsAdminStateFetcher_Reload_Retry tests that failed fetches are retried with backoff
+func TestFlagsAdminStateFetcher_Reload_Retry(t *testing.T) {
+	testStateBytes, _ := proto.Marshal(&adminv1.ResolverState{Flags: []*adminv1.Flag{{Name: "flags/test-flag"}}})
+	stateBytes, _ := proto.Marshal(&pb.SetResolverStateRequest{
+		State:     testStateBytes,
+		AccountId: "test-account",
+	})
+
+	testCases := []struct {
+		name          string
+		statuses      []int
+		expectedCalls int
+		expectError   bool
+	}{
+		{name: "5xx then success", statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK}, expectedCalls: 3},
+		{name: "gives up after max attempts", statuses: []int{http.StatusInternalServerError}, expectedCalls: 3, expectError: true},
+		{name: "4xx is not retried", statuses: []int{http.StatusForbidden}, expectedCalls: 1, expectError: true},
+		{name: "304 is not retried", statuses: []int{http.StatusNotModified}, expectedCalls: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tc.statuses[min(calls, len(tc.statuses)-1)]
+				calls++
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					_, _ = w.Write(stateBytes)
+				}
+			}))
+			defer server.Close()
+
+			fetcher := NewFlagsAdminStateFetcherWithBaseURLs("test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)), http.DefaultTransport, []string{server.URL})
+			fetcher.RetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Jitter: 0.5}
+
+			err := fetcher.Reload(context.Background())
+			if tc.expectError != (err != nil) {
+				t.Errorf("Expected error %v, got %v", tc.expectError, err)
+			}
+			if calls != tc.expectedCalls {
+				t.Errorf("Expected %d requests, got %d", tc.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+// TestFlagsAdminStateFetcher_Reload_RetryStopsOnCancel tests that the backoff wait honors the context
+func TestFlagsAdminStateFetcher_Reload_RetryStopsOnCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	fetcher := NewFlagsAdminStateFetcherWithBaseURLs("test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)), http.DefaultTransport, []string{server.URL})
+	fetcher.RetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := fetcher.Reload(ctx); err == nil {
+		t.Error("Expected error from reload")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected reload to stop when the context is done, took %v", elapsed)
+	}
+}
+
+// TestFlagsAdminStateFetcher_Provide_ContextDeadline tests that Provide gives up on a hanging
+// CDN once the context deadline passes, well before the HTTP client timeout
+func TestFlagsAdminStateFetcher_Provide_ContextDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	fetcher := NewFlagsAdminStateFetcherWithBaseURLs("test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)), http.DefaultTransport, []string{server.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := fetcher.Provide(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected Provide to stop at the context deadline, took %v", elapsed)
+	}
+}
+
+// TestFlagsAdminStateFetcher_Reload_Gzip tests that gzip-encoded state is decompressed and
+// that a corrupt body fails the reload without touching the loaded state
+func TestFlagsAdminStateFetcher_Reload_Gzip(t *testing.T) {
+	testStateBytes, _ := proto.Marshal(&adminv1.ResolverState{Flags: []*adminv1.Flag{{Name: "flags/test-flag"}}})
+	stateBytes, _ := proto.Marshal(&pb.SetResolverStateRequest{State: testStateBytes, AccountId: "test-account"})
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write(stateBytes)
+	_ = gz.Close()
+
+	var corrupt atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("Expected Accept-Encoding: gzip, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		if r.Header.Get("If-None-Match") == "gzip-etag" && !corrupt.Load() {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		if corrupt.Load() {
+			w.Header().Set("ETag", "corrupt-etag")
+			_, _ = w.Write(compressed.Bytes()[:compressed.Len()/2])
+			return
+		}
+		w.Header().Set("ETag", "gzip-etag")
+		_, _ = w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	fetcher := NewFlagsAdminStateFetcherWithBaseURLs("test-client-secret", slog.New(slog.NewTextHandler(os.Stderr, nil)), http.DefaultTransport, []string{server.URL})
+	fetcher.RetryPolicy = RetryPolicy{MaxAttempts: 1}
+	ctx := context.Background()
+
+	if err := fetcher.Reload(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !bytes.Equal(fetcher.GetRawState(), testStateBytes) || fetcher.GetAccountID() != "test-account" {
+		t.Fatal("Expected the decompressed state to be loaded")
+	}
+	if err := fetcher.Reload(ctx); err != nil {
+		t.Errorf("Expected no error on 304 Not Modified, got %v", err)
+	}
+
+	corrupt.Store(true)
+	if err := fetcher.Reload(ctx); err == nil {
+		t.Error("Expected an error for a corrupt gzip body")
+	}
+	if !bytes.Equal(fetcher.GetRawState(), testStateBytes) || fetcher.ETag() != "gzip-etag" {
+		t.Error("Expected a corrupt body to leave the loaded state and ETag unchanged")
+	}
+}
+
+// idleClosingTransport records whether its idle connections were closed
+type idleClosingTransport struct {
+	http.RoundTripper
+	closedIdle bool
+}
+
+func (t *idleClosingTransport) CloseIdleConnections() { t.closedIdle = true }
+
+func TestFlagsAdminStateFetcher_Close(t *testing.T) {
+	transport := &idleClosingTransport{RoundTripper: http.DefaultTransport}
+	fetcher := NewFlagsAdminStateFetcherWithTransport("test-secret", slog.Default(), transport)
+	if err := fetcher.Close(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !transport.closedIdle {
+		t.Error("Expected Close to close the transport's idle connections")
+	}
+}
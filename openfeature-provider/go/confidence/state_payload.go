@@ -0,0 +1,71 @@
+package confidence
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	pb "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto"
+	adminv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/admin/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// ParseStatePayload extracts the resolver state and account ID from a state payload.
+//
+// A raw adminv1.ResolverState does not carry an account ID. The account is only
+// present on the SetResolverStateRequest envelope served by the CDN, in its
+// account_id field (field 2). If data is such an envelope with a non-empty
+// account_id, the embedded state and account are returned. Otherwise data is
+// treated as a raw ResolverState and the explicit accountID is used.
+func ParseStatePayload(data []byte, accountID string) ([]byte, string, error) {
+	envelope := &pb.SetResolverStateRequest{}
+	if err := proto.Unmarshal(data, envelope); err == nil &&
+		envelope.AccountId != "" &&
+		len(envelope.ProtoReflect().GetUnknown()) == 0 &&
+		proto.Unmarshal(envelope.State, &adminv1.ResolverState{}) == nil {
+		return envelope.State, envelope.AccountId, nil
+	}
+
+	if err := proto.Unmarshal(data, &adminv1.ResolverState{}); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal ResolverState: %w", err)
+	}
+	if accountID == "" {
+		return nil, "", fmt.Errorf("state does not embed an account ID and none was supplied")
+	}
+	return data, accountID, nil
+}
+
+// HashResolverState returns a hex-encoded SHA-256 of the resolver state bytes as fetched,
+// so processes that loaded the same state published to the CDN report the same hash
+// regardless of how they were built. The state isn't re-encoded, which protobuf doesn't
+// guarantee to be stable across library versions.
+func HashResolverState(state []byte) string {
+	sum := sha256.Sum256(state)
+	return hex.EncodeToString(sum[:])
+}
+
+// countStateFlags returns the number of flags in a serialized resolver state
+func countStateFlags(state []byte) (int, error) {
+	msg := &adminv1.ResolverState{}
+	if err := proto.Unmarshal(state, msg); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal ResolverState: %w", err)
+	}
+	return len(msg.Flags), nil
+}
+
+// stateFlagKeys returns the names of the flags in a serialized resolver state, sorted and
+// without the "flags/" prefix
+func stateFlagKeys(state []byte) ([]string, error) {
+	msg := &adminv1.ResolverState{}
+	if err := proto.Unmarshal(state, msg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ResolverState: %w", err)
+	}
+	keys := make([]string, 0, len(msg.Flags))
+	for _, flag := range msg.Flags {
+		keys = append(keys, strings.TrimPrefix(flag.Name, "flags/"))
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
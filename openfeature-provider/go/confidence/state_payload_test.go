@@ -0,0 +1,78 @@
+package confidence
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
+	pb "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseStatePayload_Envelope(t *testing.T) {
+	state := tu.CreateMinimalResolverState()
+	data, _ := proto.Marshal(&pb.SetResolverStateRequest{
+		State:     state,
+		AccountId: "embedded-account",
+	})
+
+	gotState, gotAccount, err := ParseStatePayload(data, "explicit-account")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if gotAccount != "embedded-account" {
+		t.Errorf("Expected embedded account ID, got %s", gotAccount)
+	}
+	if !bytes.Equal(gotState, state) {
+		t.Error("Expected state to be unwrapped from envelope")
+	}
+}
+
+func TestParseStatePayload_RawState(t *testing.T) {
+	state := tu.CreateMinimalResolverState()
+
+	gotState, gotAccount, err := ParseStatePayload(state, "explicit-account")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if gotAccount != "explicit-account" {
+		t.Errorf("Expected explicit account ID, got %s", gotAccount)
+	}
+	if !bytes.Equal(gotState, state) {
+		t.Error("Expected raw state to be returned unchanged")
+	}
+}
+
+func TestParseStatePayload_RawStateWithoutAccount(t *testing.T) {
+	if _, _, err := ParseStatePayload(tu.CreateMinimalResolverState(), ""); err == nil {
+		t.Error("Expected error when no account ID is available")
+	}
+}
+
+func TestParseStatePayload_Invalid(t *testing.T) {
+	if _, _, err := ParseStatePayload([]byte{0xff, 0xff}, "account"); err == nil {
+		t.Error("Expected error for invalid payload")
+	}
+}
+
+func TestHashResolverState(t *testing.T) {
+	state := tu.CreateStateWithStickyFlag()
+
+	hash := HashResolverState(state)
+	if len(hash) != 64 {
+		t.Fatalf("Expected hex SHA-256, got %q", hash)
+	}
+	if HashResolverState(state) != hash {
+		t.Error("Expected hash to be stable for the same state")
+	}
+	if HashResolverState(tu.CreateMinimalResolverState()) == hash {
+		t.Error("Expected different states to hash differently")
+	}
+
+	sum := sha256.Sum256(state)
+	if hash != hex.EncodeToString(sum[:]) {
+		t.Error("Expected the hash of the state bytes as fetched")
+	}
+}
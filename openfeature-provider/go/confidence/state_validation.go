@@ -0,0 +1,123 @@
+package confidence
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	adminv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/admin/v1"
+)
+
+var flagNamePattern = regexp.MustCompile(`^flags/[^/]+$`)
+
+// StateIssue describes a single problem found in a resolver state
+type StateIssue struct {
+	// Subject is the resource name the issue relates to, or empty for state-wide issues
+	Subject string
+	Message string
+}
+
+func (i StateIssue) String() string {
+	if i.Subject == "" {
+		return i.Message
+	}
+	return i.Subject + ": " + i.Message
+}
+
+// ValidateResolverState checks a resolver state for problems that indicate a bad publish:
+// missing client credentials, malformed flag/variant/rule names, assignments referencing
+// unknown variants, and bucket ranges outside the bucket count or overlapping.
+// Returns nil if no problems were found.
+func ValidateResolverState(state *adminv1.ResolverState) []StateIssue {
+	var issues []StateIssue
+	issues = append(issues, validateClientCredentials(state)...)
+	for _, flag := range state.Flags {
+		issues = append(issues, validateFlag(flag)...)
+	}
+	return issues
+}
+
+func validateClientCredentials(state *adminv1.ResolverState) []StateIssue {
+	var issues []StateIssue
+	clients := make(map[string]bool, len(state.Clients))
+	for _, client := range state.Clients {
+		clients[client.Name] = true
+	}
+
+	secrets := 0
+	for _, credential := range state.ClientCredentials {
+		if credential.GetClientSecret().GetSecret() == "" {
+			issues = append(issues, StateIssue{credential.Name, "credential has no client secret"})
+		} else {
+			secrets++
+		}
+		// Credential names are nested under their client, e.g. clients/<id>/clientCredentials/<id>
+		if parts := strings.SplitN(credential.Name, "/", 3); len(parts) != 3 || !clients[parts[0]+"/"+parts[1]] {
+			issues = append(issues, StateIssue{credential.Name, "credential does not belong to a known client"})
+		}
+	}
+	if secrets == 0 {
+		issues = append(issues, StateIssue{"", "state contains no client secrets"})
+	}
+	return issues
+}
+
+func validateFlag(flag *adminv1.Flag) []StateIssue {
+	var issues []StateIssue
+	if !flagNamePattern.MatchString(flag.Name) {
+		issues = append(issues, StateIssue{flag.Name, "malformed flag name"})
+	}
+
+	variants := make(map[string]bool, len(flag.Variants))
+	for _, variant := range flag.Variants {
+		variants[variant.Name] = true
+		if !hasChildName(variant.Name, flag.Name, "variants") {
+			issues = append(issues, StateIssue{variant.Name, fmt.Sprintf("malformed variant name for flag %s", flag.Name)})
+		}
+	}
+
+	for _, rule := range flag.Rules {
+		if !hasChildName(rule.Name, flag.Name, "rules") {
+			issues = append(issues, StateIssue{rule.Name, fmt.Sprintf("malformed rule name for flag %s", flag.Name)})
+		}
+		issues = append(issues, validateAssignments(rule, variants)...)
+	}
+	return issues
+}
+
+func validateAssignments(rule *adminv1.Flag_Rule, variants map[string]bool) []StateIssue {
+	spec := rule.AssignmentSpec
+	if spec == nil {
+		return nil
+	}
+
+	var issues []StateIssue
+	var ranges []*adminv1.Flag_Rule_BucketRange
+	for _, assignment := range spec.Assignments {
+		if v := assignment.GetVariant(); v != nil && !variants[v.Variant] {
+			issues = append(issues, StateIssue{rule.Name, fmt.Sprintf("assignment %q references unknown variant %s", assignment.AssignmentId, v.Variant)})
+		}
+		for _, r := range assignment.BucketRanges {
+			if r.Lower < 0 || r.Upper > spec.BucketCount || r.Lower >= r.Upper {
+				issues = append(issues, StateIssue{rule.Name, fmt.Sprintf("assignment %q has invalid bucket range [%d, %d) for bucket count %d", assignment.AssignmentId, r.Lower, r.Upper, spec.BucketCount)})
+				continue
+			}
+			ranges = append(ranges, r)
+		}
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Lower < ranges[j].Lower })
+	for i := 1; i < len(ranges); i++ {
+		if ranges[i].Lower < ranges[i-1].Upper {
+			issues = append(issues, StateIssue{rule.Name, fmt.Sprintf("bucket ranges [%d, %d) and [%d, %d) overlap", ranges[i-1].Lower, ranges[i-1].Upper, ranges[i].Lower, ranges[i].Upper)})
+		}
+	}
+	return issues
+}
+
+// hasChildName reports whether name is of the form <parent>/<collection>/<id>
+func hasChildName(name string, parent string, collection string) bool {
+	id, ok := strings.CutPrefix(name, parent+"/"+collection+"/")
+	return ok && id != "" && !strings.Contains(id, "/")
+}
@@ -0,0 +1,94 @@
+package confidence
+
+import (
+	"strings"
+	"testing"
+
+	tu "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/testutil"
+	adminv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/flags/admin/v1"
+	iamv1 "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/proto/confidence/iam/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestValidateResolverState_ValidState(t *testing.T) {
+	state := &adminv1.ResolverState{}
+	if err := proto.Unmarshal(tu.CreateStateWithStickyFlag(), state); err != nil {
+		t.Fatalf("Failed to unmarshal state: %v", err)
+	}
+
+	if issues := ValidateResolverState(state); len(issues) != 0 {
+		t.Errorf("Expected no issues, got: %v", issues)
+	}
+}
+
+func TestValidateResolverState_RealState(t *testing.T) {
+	state := &adminv1.ResolverState{}
+	if err := proto.Unmarshal(tu.LoadTestResolverState(t), state); err != nil {
+		t.Fatalf("Failed to unmarshal state: %v", err)
+	}
+
+	if issues := ValidateResolverState(state); len(issues) != 0 {
+		t.Errorf("Expected no issues, got: %v", issues)
+	}
+}
+
+func TestValidateResolverState_Problems(t *testing.T) {
+	state := &adminv1.ResolverState{
+		Clients: []*iamv1.Client{{Name: "clients/test-client"}},
+		ClientCredentials: []*iamv1.ClientCredential{
+			{Name: "clients/other-client/credentials/test-credential"},
+		},
+		Flags: []*adminv1.Flag{
+			{
+				Name:     "my-flag",
+				Variants: []*adminv1.Flag_Variant{{Name: "flags/my-flag/on"}},
+				Rules: []*adminv1.Flag_Rule{
+					{
+						Name: "flags/my-flag/rule",
+						AssignmentSpec: &adminv1.Flag_Rule_AssignmentSpec{
+							BucketCount: 100,
+							Assignments: []*adminv1.Flag_Rule_Assignment{
+								{
+									AssignmentId: "a",
+									Assignment: &adminv1.Flag_Rule_Assignment_Variant{
+										Variant: &adminv1.Flag_Rule_Assignment_VariantAssignment{Variant: "flags/my-flag/variants/missing"},
+									},
+									BucketRanges: []*adminv1.Flag_Rule_BucketRange{{Lower: 0, Upper: 60}},
+								},
+								{
+									AssignmentId: "b",
+									BucketRanges: []*adminv1.Flag_Rule_BucketRange{{Lower: 50, Upper: 100}, {Lower: 90, Upper: 200}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	issues := ValidateResolverState(state)
+	expected := []string{
+		"no client secret",
+		"does not belong to a known client",
+		"no client secrets",
+		"malformed flag name",
+		"malformed variant name",
+		"malformed rule name",
+		"unknown variant",
+		"invalid bucket range",
+		"overlap",
+	}
+	for _, want := range expected {
+		found := false
+		for _, issue := range issues {
+			if strings.Contains(issue.String(), want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected an issue containing %q, got: %v", want, issues)
+		}
+	}
+}
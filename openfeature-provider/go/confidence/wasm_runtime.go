@@ -0,0 +1,24 @@
+package confidence
+
+import (
+	"context"
+
+	lr "github.com/spotify/confidence-resolver/openfeature-provider/go/confidence/internal/local_resolver"
+	"github.com/tetratelabs/wazero"
+)
+
+// RegisterHostFunctions registers the host functions the resolver guest imports on runtime,
+// so guests compiled on it can be shared between providers through ProviderConfig.WasmRuntime
+// and ProviderConfig.CompiledWasm. The guest reads the current time from clock, nil for the
+// system clock. Host functions must be registered exactly once per runtime; registering them
+// again fails.
+func RegisterHostFunctions(ctx context.Context, runtime wazero.Runtime, clock Clock) error {
+	return lr.RegisterHostFunctions(ctx, runtime, clock)
+}
+
+// CompileWasm compiles a resolver guest on runtime, the embedded guest when wasm is nil.
+// Returns an error if the bytes are not a valid WASM module or lack the functions the
+// provider calls.
+func CompileWasm(ctx context.Context, runtime wazero.Runtime, wasm []byte) (wazero.CompiledModule, error) {
+	return lr.CompileModule(ctx, runtime, wasm)
+}
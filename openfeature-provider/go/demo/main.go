@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -75,6 +78,22 @@ func main() {
 
 	// Shared counters for throughput calculation
 	var totalSuccess, totalErrors int64
+	errorsByCode := newErrorBreakdown()
+
+	// Print running stats every second
+	statsDone := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				log.Printf("Stats: errors by code: %s", errorsByCode)
+			case <-statsDone:
+				return
+			}
+		}
+	}()
 
 	for i := 0; i < numThreads; i++ {
 		wg.Add(1)
@@ -90,6 +109,7 @@ func main() {
 				result, err := client.ObjectValueDetails(ctx, "mattias-boolean-flag", map[string]interface{}{}, evalCtx)
 				if err != nil {
 					errorCount++
+					errorsByCode.add(result)
 					if iteration == 0 { // Only log first error per thread
 						log.Printf("Thread %d: Error: %v", threadID, err)
 					}
@@ -117,6 +137,7 @@ func main() {
 
 	// Wait for all threads to complete
 	wg.Wait()
+	close(statsDone)
 
 	duration := time.Since(startTime)
 	totalRequests := totalSuccess + totalErrors
@@ -127,12 +148,57 @@ func main() {
 	log.Printf("Total time: %v", duration)
 	log.Printf("Throughput: %.2f requests/second", throughputPerSecond)
 	log.Printf("Average latency: %.2f ms/request", duration.Seconds()*1000/float64(totalRequests))
+	log.Printf("Errors by code: %s", errorsByCode)
 	log.Println("Check logs above for per-thread statistics and state reload/flush messages")
 	log.Println("")
 
 	log.Println("At the end of main... shutting down...")
 }
 
+// errorBreakdown counts evaluation errors by error code, falling back to the reason
+type errorBreakdown struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func newErrorBreakdown() *errorBreakdown {
+	return &errorBreakdown{counts: make(map[string]int64)}
+}
+
+func (b *errorBreakdown) add(result openfeature.InterfaceEvaluationDetails) {
+	key := string(result.ErrorCode)
+	if key == "" {
+		key = string(result.Reason)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.counts[key]++
+}
+
+// String formats the counts like "FLAG_NOT_FOUND: 3, GENERAL: 1", most frequent first
+func (b *errorBreakdown) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.counts) == 0 {
+		return "none"
+	}
+	keys := make([]string, 0, len(b.counts))
+	for key := range b.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if b.counts[keys[i]] != b.counts[keys[j]] {
+			return b.counts[keys[i]] > b.counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s: %d", key, b.counts[key])
+	}
+	return strings.Join(parts, ", ")
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value